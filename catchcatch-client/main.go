@@ -1,37 +1,86 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"flag"
 	"log"
+	"net"
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 
+	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
 	"github.com/gorilla/websocket"
+	zconf "github.com/grandcat/zeroconf"
 	"github.com/perenecabuto/CatchCatch/catchcatch-server/model"
 	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
 )
 
-var addr = flag.String("addr", "localhost:5000", "http service address")
+var (
+	addr     = flag.String("addr", "localhost:5000", "http service address")
+	playerID = flag.String("player-id", "", "persistent player id sent on player:hello")
+	token    = flag.String("token", "", "player token issued by the server")
+	name     = flag.String("name", "", "player display name")
+	color    = flag.String("color", "", "player avatar color (#rrggbb)")
+	auth     = flag.String("auth-token", "", "token to authenticate on the server")
+	codec    = flag.String("codec", "protobuf", "wire codec: protobuf, json")
+	secure   = flag.Bool("tls", false, "connect with wss")
+	insecure = flag.Bool("tls-insecure", false, "accept any server certificate, eg: self signed")
+	discover = flag.Duration("discover", 0, "list the servers found by zeroconf for this long and exit, eg: 3s")
+)
 
 func main() {
 	flag.Parse()
 	log.SetFlags(0)
 
+	if *discover > 0 {
+		if err := discoverServers(*discover); err != nil {
+			log.Fatal("discover:", err)
+		}
+		return
+	}
+
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt)
 
 	u := url.URL{Scheme: "ws", Host: *addr, Path: "/ws"}
+	if *secure {
+		u.Scheme = "wss"
+	}
+	query := url.Values{"codec": []string{*codec}}
+	if *auth != "" {
+		query.Set("token", *auth)
+	}
+	u.RawQuery = query.Encode()
 	log.Printf("connecting to %s", u.String())
 
-	c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: *insecure}
+	c, _, err := dialer.Dial(u.String(), nil)
 	if err != nil {
 		log.Fatal("dial:", err)
 	}
 	defer c.Close()
 
+	hello := &protobuf.ProtocolHello{EventName: proto.String("protocol:hello"),
+		Version: proto.Uint32(1), Capabilities: []string{"errors", "list-end"}}
+	if err := send(c, hello); err != nil {
+		log.Fatal("protocol:hello:", err)
+	}
+
+	if *playerID != "" || *name != "" {
+		hello := &protobuf.PlayerHello{EventName: proto.String("player:hello"),
+			Id: playerID, Token: token, Name: name, Color: color}
+		if err := send(c, hello); err != nil {
+			log.Fatal("hello:", err)
+		}
+	}
+
 	done := make(chan struct{})
 	player := model.Player{}
 
@@ -46,7 +95,7 @@ func main() {
 			}
 
 			msg := &protobuf.Simple{}
-			if err := proto.Unmarshal(message, msg); err != nil {
+			if err := decode(message, msg); err != nil {
 				log.Println("readMessage(unmarshall): ", err.Error(), message)
 				continue
 			}
@@ -54,14 +103,22 @@ func main() {
 			switch *msg.EventName {
 			case "player:registered":
 				p := &protobuf.Player{}
-				if err := proto.Unmarshal(message, p); err != nil {
+				if err := decode(message, p); err != nil {
 					log.Println("error parsing player: ", err.Error(), p)
 					continue
 				}
 
 				log.Println("player: ", p)
+				if p.Token != nil {
+					log.Printf("use -player-id %s -token %s to reconnect as %s", p.GetId(), p.GetToken(), p.GetName())
+				}
 				player.ID, player.Lat, player.Lon = *p.Id, *p.Lat, *p.Lon
 				player.Lat, player.Lon = -30.03495, -51.21866
+			case "server:shutdown":
+				s := &protobuf.ServerShutdown{}
+				if err := decode(message, s); err == nil {
+					log.Printf("server shutting down, reconnect in %dms to %q", s.GetReconnectIn(), s.GetReconnectUrl())
+				}
 			}
 			log.Printf("recv: %s", msg)
 		}
@@ -77,8 +134,7 @@ func main() {
 		case <-ticker.C:
 			evt := "player:update"
 			msg := &protobuf.Player{EventName: &evt, Id: &player.ID, Lon: &player.Lon, Lat: &player.Lat}
-			payload, _ := proto.Marshal(msg)
-			err := c.WriteMessage(websocket.BinaryMessage, payload)
+			err := send(c, msg)
 			if err != nil {
 				log.Println("write:", err)
 				return
@@ -99,3 +155,65 @@ func main() {
 		}
 	}
 }
+
+// discoverServers prints the servers advertised by zeroconf until timeout
+func discoverServers(timeout time.Duration) error {
+	resolver, err := zconf.NewResolver()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	entries := make(chan *zconf.ServiceEntry)
+	if err := resolver.Browse(ctx, "_catchcatch._tcp", "local.", entries); err != nil {
+		return err
+	}
+	log.Printf("looking for servers for %s...", timeout)
+	found := 0
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("%d servers found", found)
+			return nil
+		case entry, ok := <-entries:
+			if !ok {
+				entries = nil
+				continue
+			}
+			host := entry.HostName
+			if len(entry.AddrIPv4) > 0 {
+				host = entry.AddrIPv4[0].String()
+			}
+			info := model.ParseServerInfo(entry.Text)
+			if info.Name == "" {
+				info.Name = entry.Instance
+			}
+			found++
+			log.Printf("%s %s protocol:v%d players:%d games:%d", info.Name,
+				info.URL(net.JoinHostPort(host, strconv.Itoa(entry.Port))), info.ProtocolVersion, info.Players, info.Games)
+		}
+	}
+}
+
+func send(c *websocket.Conn, msg proto.Message) error {
+	if *codec == "json" {
+		payload, err := (&jsonpb.Marshaler{}).MarshalToString(msg)
+		if err != nil {
+			return err
+		}
+		return c.WriteMessage(websocket.TextMessage, []byte(payload))
+	}
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(websocket.BinaryMessage, payload)
+}
+
+func decode(payload []byte, msg proto.Message) error {
+	if *codec == "json" {
+		u := jsonpb.Unmarshaler{AllowUnknownFields: true}
+		return u.Unmarshal(bytes.NewReader(payload), msg)
+	}
+	return proto.Unmarshal(payload, msg)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// MaxAuditEntrySize is the max size of an audit log line
+const MaxAuditEntrySize = 1024 * 1024
+
+// AuditOutcome is the result of an audited action
+type AuditOutcome string
+
+const (
+	// AuditOK the action was executed
+	AuditOK AuditOutcome = "ok"
+	// AuditDenied the connection was not allowed to execute the action
+	AuditDenied AuditOutcome = "denied"
+	// AuditFailed the action was allowed but failed
+	AuditFailed AuditOutcome = "failed"
+)
+
+// AuditEntry records who did what and when
+type AuditEntry struct {
+	Time    time.Time    `json:"time"`
+	ConnID  string       `json:"conn_id"`
+	Subject string       `json:"subject,omitempty"`
+	Role    ConnRole     `json:"role"`
+	Event   string       `json:"event"`
+	Payload string       `json:"payload,omitempty"`
+	Outcome AuditOutcome `json:"outcome"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// NewAuditEntry creates an entry for the connection event
+func NewAuditEntry(c *WSConnListener, event, payload string, err error) AuditEntry {
+	entry := AuditEntry{Time: time.Now(), ConnID: c.ID(), Subject: c.Subject, Role: c.Role,
+		Event: event, Payload: payload, Outcome: AuditOK}
+	if err == ErrUnauthorized {
+		entry.Outcome = AuditDenied
+	} else if err != nil {
+		entry.Outcome = AuditFailed
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// AuditLog is an append only log of admin actions
+type AuditLog interface {
+	Record(entry AuditEntry) error
+	// Entries returns the entries from the newest to the oldest and the total of entries
+	Entries(offset, limit int) ([]AuditEntry, int, error)
+}
+
+// FileAuditLog writes the audit log as JSON lines on a file rotated by size
+type FileAuditLog struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+	sync.Mutex
+}
+
+// NewFileAuditLog opens or creates the audit log file on path
+// when the file reaches maxSize bytes it is rotated keeping maxBackups old files
+func NewFileAuditLog(path string, maxSize int64, maxBackups int) (*FileAuditLog, error) {
+	l := &FileAuditLog{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Record implements AuditLog.Record
+func (l *FileAuditLog) Record(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.Lock()
+	defer l.Unlock()
+	if l.maxSize > 0 && l.size+int64(len(line)) > l.maxSize && l.size > 0 {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// Entries implements AuditLog.Entries
+// the files are opened under the lock and read after it, so Record isn't blocked while reading
+func (l *FileAuditLog) Entries(offset, limit int) ([]AuditEntry, int, error) {
+	files, err := l.openFiles()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	entries := make([]AuditEntry, 0)
+	for _, f := range files {
+		fileEntries, err := readAuditFile(f)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	total := len(entries)
+	page := make([]AuditEntry, 0, limit)
+	for i := total - 1 - offset; i >= 0 && len(page) < limit; i-- {
+		page = append(page, entries[i])
+	}
+	return page, total, nil
+}
+
+// openFiles opens the backups from the oldest to the current file
+// open files keep their content when they are rotated
+func (l *FileAuditLog) openFiles() ([]*os.File, error) {
+	l.Lock()
+	defer l.Unlock()
+	files := make([]*os.File, 0, l.maxBackups+1)
+	for i := l.maxBackups; i >= 0; i-- {
+		f, err := os.Open(l.backupPath(i))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			for _, opened := range files {
+				opened.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// Close the audit log file
+func (l *FileAuditLog) Close() error {
+	l.Lock()
+	defer l.Unlock()
+	return l.file.Close()
+}
+
+func (l *FileAuditLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+func (l *FileAuditLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	for i := l.maxBackups; i > 0; i-- {
+		if _, err := os.Stat(l.backupPath(i - 1)); err == nil {
+			if err := os.Rename(l.backupPath(i-1), l.backupPath(i)); err != nil {
+				return err
+			}
+		}
+	}
+	if l.maxBackups == 0 {
+		os.Remove(l.path)
+	}
+	return l.open()
+}
+
+func (l *FileAuditLog) backupPath(n int) string {
+	if n == 0 {
+		return l.path
+	}
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+func readAuditFile(file *os.File) ([]AuditEntry, error) {
+	entries := make([]AuditEntry, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxAuditEntrySize)
+	for scanner.Scan() {
+		entry := AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestFileAuditLogRotatesAndPagesFromNewest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	audit, err := NewFileAuditLog(path, 300, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+
+	for i := 0; i < 10; i++ {
+		entry := AuditEntry{ConnID: "conn", Role: RoleAdmin, Event: "admin:clear", Payload: strconv.Itoa(i), Outcome: AuditOK}
+		if err := audit.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatal("expected audit log to be rotated:", err)
+	}
+
+	entries, total, err := audit.Entries(0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 10 || len(entries) != 3 || entries[0].Payload != "9" || entries[2].Payload != "7" {
+		t.Fatalf("unexpected first page: total=%d entries=%+v", total, entries)
+	}
+
+	entries, _, _ = audit.Entries(8, 3)
+	if len(entries) != 2 || entries[0].Payload != "1" || entries[1].Payload != "0" {
+		t.Fatalf("unexpected last page: %+v", entries)
+	}
+}
+
+type memoryAuditLog struct {
+	entries []AuditEntry
+}
+
+func (l *memoryAuditLog) Record(entry AuditEntry) error {
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func (l *memoryAuditLog) Entries(offset, limit int) ([]AuditEntry, int, error) {
+	return l.entries, len(l.entries), nil
+}
+
+type failingClearService struct {
+	PlayerLocationService
+}
+
+func (failingClearService) Clear() error { return errors.New("tile38 down") }
+
+func TestAdminClearIsAuditedWithItsResult(t *testing.T) {
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	games := NewGameWatcher(idleEventStream{}, server, nil, GameLease{Locks: NewMemoryLock(), Owner: "a"}, DefaultGameRules, nil, nil)
+	audit := &memoryAuditLog{}
+	h := NewEventHandler(server, failingClearService{}, games, nil, NoAuthenticator{}, audit, nil, nil, nil)
+	conn := &fakeWSConn{}
+	admin := server.Add(conn)
+	admin.Role = RoleAdmin
+	admin.protocol.Store(NegotiateProtocol(CurrentProtocolVersion, []string{"errors"}))
+
+	h.onClear(admin)(&Request{EventName: "admin:clear"}, nil)
+	if len(audit.entries) != 1 || audit.entries[0].Outcome != AuditFailed || audit.entries[0].Error != "tile38 down" {
+		t.Fatalf("expected the failed clear to be audited, got: %+v", audit.entries)
+	}
+	if server.Get(admin.ID()) == nil || len(conn.sent) != 1 {
+		t.Fatal("expected the error to be replied without closing the connections")
+	}
+}
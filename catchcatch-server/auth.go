@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTokenRequired happens when a connection doesn't present any token
+	ErrTokenRequired = errors.New("authentication token required")
+	// ErrInvalidToken happens when the token is malformed or its signature doesn't match
+	ErrInvalidToken = errors.New("invalid authentication token")
+	// ErrTokenExpired happens when the token expiration time is in the past
+	ErrTokenExpired = errors.New("authentication token expired")
+	// ErrInvalidRole happens when the token has an unknown role
+	ErrInvalidRole = errors.New("invalid connection role")
+	// ErrUnauthorized happens when a connection role is not allowed to send an event
+	ErrUnauthorized = errors.New("not authorized")
+)
+
+// ConnRole is the authorization level of a connection
+type ConnRole string
+
+const (
+	// RolePlayer can play and see the other players
+	RolePlayer ConnRole = "player"
+	// RoleAdmin is a player which also can manage the map and the connections
+	RoleAdmin ConnRole = "admin"
+	// RoleObserver can only watch players and features
+	RoleObserver ConnRole = "observer"
+)
+
+// ParseConnRole validates role names, empty means player
+func ParseConnRole(role string) (ConnRole, error) {
+	switch r := ConnRole(role); r {
+	case "":
+		return RolePlayer, nil
+	case RolePlayer, RoleAdmin, RoleObserver:
+		return r, nil
+	}
+	return "", ErrInvalidRole
+}
+
+const (
+	// AuthTokenParam is the query param used to send the token on /ws upgrade
+	AuthTokenParam = "token"
+	// AuthTimeout is how long a connection can stay without sending auth:token
+	AuthTimeout = 10 * time.Second
+)
+
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// AuthClaims are the token assertions about the connection
+type AuthClaims struct {
+	Subject   string `json:"sub,omitempty"`
+	Role      string `json:"role,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// Authenticator validates connection credentials
+type Authenticator interface {
+	Required() bool
+	Authenticate(token string) (*AuthClaims, error)
+}
+
+// HMACAuthenticator validates JWT tokens signed with HS256 and a local secret
+type HMACAuthenticator struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewHMACAuthenticator creates an Authenticator which requires tokens signed with secret
+func NewHMACAuthenticator(secret string) *HMACAuthenticator {
+	return &HMACAuthenticator{[]byte(secret), time.Now}
+}
+
+// Required implements Authenticator.Required
+func (a *HMACAuthenticator) Required() bool {
+	return true
+}
+
+// Sign creates a token for claims
+func (a *HMACAuthenticator) Sign(claims AuthClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + a.signature(unsigned), nil
+}
+
+// Authenticate implements Authenticator.Authenticate
+func (a *HMACAuthenticator) Authenticate(token string) (*AuthClaims, error) {
+	if token == "" {
+		return nil, ErrTokenRequired
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, ErrInvalidToken
+	}
+	expected := a.signature(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims := &AuthClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.ExpiresAt != 0 && a.now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+func (a *HMACAuthenticator) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NoAuthenticator accepts every connection with the same role
+type NoAuthenticator struct {
+	Role ConnRole
+}
+
+// Required implements Authenticator.Required
+func (NoAuthenticator) Required() bool {
+	return false
+}
+
+// Authenticate implements Authenticator.Authenticate
+func (a NoAuthenticator) Authenticate(token string) (*AuthClaims, error) {
+	return &AuthClaims{Role: string(a.Role)}, nil
+}
+
+func authTokenFromRequest(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	return r.URL.Query().Get(AuthTokenParam)
+}
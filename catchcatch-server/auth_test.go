@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACAuthenticatorAcceptsSignedToken(t *testing.T) {
+	auth := NewHMACAuthenticator("secret")
+	token, err := auth.Sign(AuthClaims{Subject: "player1", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := auth.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "player1" {
+		t.Fatal("expected subject player1, got:", claims.Subject)
+	}
+}
+
+func TestHMACAuthenticatorRejectsInvalidTokens(t *testing.T) {
+	auth := NewHMACAuthenticator("secret")
+	token, _ := auth.Sign(AuthClaims{Subject: "player1"})
+	other, _ := NewHMACAuthenticator("other-secret").Sign(AuthClaims{Subject: "player1"})
+	parts := strings.Split(token, ".")
+	forged, _ := auth.Sign(AuthClaims{Subject: "admin"})
+	tampered := parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+
+	cases := map[string]error{
+		"":            ErrTokenRequired,
+		"invalid":     ErrInvalidToken,
+		other:         ErrInvalidToken,
+		tampered:      ErrInvalidToken,
+		token + "xyz": ErrInvalidToken,
+	}
+	for token, expected := range cases {
+		if _, err := auth.Authenticate(token); err != expected {
+			t.Errorf("token %q: expected %v, got %v", token, expected, err)
+		}
+	}
+}
+
+func TestHMACAuthenticatorRejectsExpiredToken(t *testing.T) {
+	auth := NewHMACAuthenticator("secret")
+	token, _ := auth.Sign(AuthClaims{Subject: "player1", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	auth.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if _, err := auth.Authenticate(token); err != ErrTokenExpired {
+		t.Fatal("expected ErrTokenExpired, got:", err)
+	}
+}
+
+func TestParseConnRole(t *testing.T) {
+	cases := map[string]ConnRole{"": RolePlayer, "player": RolePlayer, "admin": RoleAdmin, "observer": RoleObserver}
+	for name, expected := range cases {
+		if role, err := ParseConnRole(name); err != nil || role != expected {
+			t.Errorf("role %q: expected %v, got %v (%v)", name, expected, role, err)
+		}
+	}
+	if _, err := ParseConnRole("root"); err != ErrInvalidRole {
+		t.Fatal("expected ErrInvalidRole, got:", err)
+	}
+}
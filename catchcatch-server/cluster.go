@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+// JoinCluster makes Emit, BroadcastTo, Broadcast and rooms reach connections held by other nodes
+// it must be called before accepting connections, ForEach and RoomMembers stay local to this node
+func (wss *WSServer) JoinCluster(ctx context.Context, node string, bus MessageBus, directory ConnDirectory) error {
+	wss.node, wss.bus, wss.directory = node, bus, directory
+	if err := directory.Join(ctx, node); err != nil {
+		return err
+	}
+	wss.ForEach(func(c *WSConnListener) {
+		wss.register(c.ID())
+	})
+	return bus.Subscribe(ctx, node, wss.deliver)
+}
+
+// Node is the name of this server in the cluster
+func (wss *WSServer) Node() string {
+	return wss.node
+}
+
+func (wss *WSServer) register(id string) {
+	if wss.directory == nil {
+		return
+	}
+	if err := wss.directory.Register(id, wss.node); err != nil {
+		wss.logger.Error("error to register connection", LogConnID, id, "error", err)
+	}
+}
+
+func (wss *WSServer) unregister(id string) {
+	if wss.directory == nil {
+		return
+	}
+	if err := wss.directory.Unregister(id, wss.node); err != nil {
+		wss.logger.Error("error to unregister connection", LogConnID, id, "error", err)
+	}
+}
+
+// lookup returns the node holding a connection which is not on this server
+func (wss *WSServer) lookup(id string) (string, error) {
+	if wss.directory == nil {
+		return "", ErrConnNotFound
+	}
+	node, err := wss.directory.Lookup(id)
+	if err != nil {
+		return "", err
+	} else if node == wss.node {
+		// the connection was closed but not unregistered yet
+		return "", ErrConnNotFound
+	}
+	return node, nil
+}
+
+func (wss *WSServer) publish(node string, ids []string, message Message) error {
+	return wss.publishBus(node, &BusMessage{To: ids}, message)
+}
+
+// HandleBus calls fn with the messages other nodes send to name with PublishToNodes
+func (wss *WSServer) HandleBus(name string, fn func(Message)) {
+	wss.busHandlers.Store(name, fn)
+}
+
+// PublishToNodes sends message to the handler name of every other node
+func (wss *WSServer) PublishToNodes(name string, message Message) error {
+	if wss.bus == nil {
+		return nil
+	}
+	return wss.publishBus("", &BusMessage{Handler: name}, message)
+}
+
+// publishBus sends msg to node with message as its envelope, nil message sends no envelope
+func (wss *WSServer) publishBus(node string, msg *BusMessage, message Message) error {
+	msg.Origin = wss.node
+	if message != nil {
+		env, err := NewEnvelope(CurrentProtocolVersion, message)
+		if err != nil {
+			return err
+		}
+		if msg.Envelope, err = proto.Marshal(env); err != nil {
+			return err
+		}
+	}
+	return wss.bus.Publish(node, msg)
+}
+
+// deliver messages published by other nodes to the local connections
+func (wss *WSServer) deliver(msg *BusMessage) {
+	if msg.Origin == wss.node {
+		return
+	}
+	if msg.ConnOp != "" {
+		wss.deliverConnOp(msg)
+		return
+	} else if msg.RoomOp != "" {
+		wss.deliverRoomOp(msg)
+		return
+	}
+	env := &protobuf.Envelope{}
+	if err := proto.Unmarshal(msg.Envelope, env); err != nil {
+		wss.logger.Warn("invalid bus message", "origin", msg.Origin, "error", err)
+		return
+	}
+	message, ok := EnvelopePayload(env).(Message)
+	if !ok {
+		wss.logger.Warn("invalid bus message", "origin", msg.Origin, "error", "unknown payload")
+		return
+	}
+	if msg.Handler != "" {
+		if fn, exists := wss.busHandlers.Load(msg.Handler); exists {
+			fn.(func(Message))(message)
+		}
+		return
+	} else if msg.Room != "" {
+		wss.broadcastRoomLocal(msg.Room, message)
+		return
+	} else if len(msg.To) == 0 {
+		wss.broadcastLocal(message)
+		return
+	}
+	for _, id := range msg.To {
+		if c := wss.Get(id); c != nil {
+			c.Emit(message)
+		}
+	}
+}
+
+func (wss *WSServer) deliverConnOp(msg *BusMessage) {
+	if msg.ConnOp != ConnOpDisconnect {
+		return
+	} else if len(msg.To) == 0 {
+		wss.CloseAll()
+		return
+	}
+	for _, id := range msg.To {
+		wss.Remove(id)
+	}
+}
+
+func (wss *WSServer) deliverRoomOp(msg *BusMessage) {
+	if msg.RoomOp == RoomOpClose {
+		wss.rooms.close(msg.Room)
+		return
+	}
+	for _, id := range msg.To {
+		c := wss.Get(id)
+		if c == nil {
+			continue
+		}
+		switch msg.RoomOp {
+		case RoomOpJoin:
+			wss.rooms.join(msg.Room, c)
+		case RoomOpLeave:
+			wss.rooms.leave(msg.Room, c)
+		}
+	}
+}
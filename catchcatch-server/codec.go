@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// CodecParam is the query param used to choose the connection codec on /ws upgrade
+	CodecParam = "codec"
+	// SubprotocolPrefix prefixes the codec names on Sec-WebSocket-Protocol, eg: catchcatch.json
+	SubprotocolPrefix = "catchcatch."
+)
+
+// Codec encodes and decodes WS messages
+type Codec interface {
+	Name() string
+	// Text is true when the encoded payload must be sent on text frames
+	Text() bool
+	Marshal(message proto.Message) ([]byte, error)
+	Unmarshal(payload []byte, message proto.Message) error
+}
+
+// ProtobufCodec is the default binary codec
+type ProtobufCodec struct{}
+
+// Name implements Codec.Name
+func (ProtobufCodec) Name() string {
+	return "protobuf"
+}
+
+// Text implements Codec.Text
+func (ProtobufCodec) Text() bool {
+	return false
+}
+
+// Marshal implements Codec.Marshal
+func (ProtobufCodec) Marshal(message proto.Message) ([]byte, error) {
+	return proto.Marshal(message)
+}
+
+// Unmarshal implements Codec.Unmarshal
+func (ProtobufCodec) Unmarshal(payload []byte, message proto.Message) error {
+	return proto.Unmarshal(payload, message)
+}
+
+// JSONCodec encodes messages as JSON using the protobuf JSON mapping
+// field names are camel cased, eg: eventName, requestId
+type JSONCodec struct {
+	marshaler   jsonpb.Marshaler
+	unmarshaler jsonpb.Unmarshaler
+}
+
+// NewJSONCodec creates a JSONCodec
+func NewJSONCodec() *JSONCodec {
+	return &JSONCodec{unmarshaler: jsonpb.Unmarshaler{AllowUnknownFields: true}}
+}
+
+// Name implements Codec.Name
+func (*JSONCodec) Name() string {
+	return "json"
+}
+
+// Text implements Codec.Text
+func (*JSONCodec) Text() bool {
+	return true
+}
+
+// Marshal implements Codec.Marshal
+func (c *JSONCodec) Marshal(message proto.Message) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := c.marshaler.Marshal(buf, message); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal implements Codec.Unmarshal
+func (c *JSONCodec) Unmarshal(payload []byte, message proto.Message) error {
+	if err := c.unmarshaler.Unmarshal(bytes.NewReader(payload), message); err != nil {
+		return err
+	}
+	// jsonpb doesn't validate proto2 required fields as proto.Unmarshal does
+	_, err := proto.Marshal(message)
+	return err
+}
+
+var codecs = map[string]Codec{
+	"protobuf": ProtobufCodec{},
+	"json":     NewJSONCodec(),
+}
+
+// SelectCodec returns the codec requested by the query param or the subprotocol
+// protobuf is used when none is requested
+func SelectCodec(r *http.Request) Codec {
+	if r == nil {
+		return codecs["protobuf"]
+	}
+	if codec, exists := codecs[r.URL.Query().Get(CodecParam)]; exists {
+		return codec
+	}
+	if protocol := SelectSubprotocol(requestedSubprotocols(r)); protocol != "" {
+		return codecs[strings.TrimPrefix(protocol, SubprotocolPrefix)]
+	}
+	return codecs["protobuf"]
+}
+
+// SelectSubprotocol returns the first supported subprotocol or empty if none is supported
+func SelectSubprotocol(protocols []string) string {
+	for _, p := range protocols {
+		p = strings.TrimSpace(p)
+		if !strings.HasPrefix(p, SubprotocolPrefix) {
+			continue
+		}
+		if _, exists := codecs[strings.TrimPrefix(p, SubprotocolPrefix)]; exists {
+			return p
+		}
+	}
+	return ""
+}
+
+func requestedSubprotocols(r *http.Request) []string {
+	protocols := make([]string, 0)
+	for _, header := range r.Header["Sec-Websocket-Protocol"] {
+		protocols = append(protocols, strings.Split(header, ",")...)
+	}
+	return protocols
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+func TestJSONCodecUsesProtobufFieldNames(t *testing.T) {
+	codec := NewJSONCodec()
+	payload, err := codec.Marshal(&protobuf.Player{EventName: proto.String("player:update"),
+		Id: proto.String("p1"), Lat: proto.Float64(-30.1), Lon: proto.Float64(-51.2), RequestId: proto.String("r1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(payload), `"eventName":"player:update"`) || !strings.Contains(string(payload), `"requestId":"r1"`) {
+		t.Fatal("unexpected payload:", string(payload))
+	}
+
+	msg := &protobuf.Simple{}
+	if err := codec.Unmarshal(payload, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.GetEventName() != "player:update" || msg.GetId() != "p1" {
+		t.Fatal("unexpected message:", msg)
+	}
+
+	if err := codec.Unmarshal([]byte(`{"id":"p1"}`), &protobuf.Simple{}); err == nil {
+		t.Fatal("expected error for missing required event name")
+	}
+}
+
+func TestSelectCodec(t *testing.T) {
+	r := httptest.NewRequest("GET", "/ws", nil)
+	if codec := SelectCodec(r); codec.Name() != "protobuf" {
+		t.Fatal("expected protobuf as default codec, got:", codec.Name())
+	}
+
+	r = httptest.NewRequest("GET", "/ws?codec=json", nil)
+	if codec := SelectCodec(r); codec.Name() != "json" {
+		t.Fatal("expected json codec from query param, got:", codec.Name())
+	}
+
+	r = httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "chat, catchcatch.json")
+	if codec := SelectCodec(r); codec.Name() != "json" {
+		t.Fatal("expected json codec from subprotocol, got:", codec.Name())
+	}
+}
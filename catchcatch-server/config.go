@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kylelemons/go-gypsy/yaml"
+)
+
+// ConfigEnvPrefix prefixes the env vars which override the config file
+const ConfigEnvPrefix = "CATCHCATCH_"
+
+// ErrInvalidConfigFile happens when the config file is not a map of settings
+var ErrInvalidConfigFile = errors.New("config file must be a map of setting: value")
+
+// Config is the server configuration
+// every setting is a flag, its name is also the config file key and the env var suffix, eg:
+// -tile38-addr, tile38-addr: localhost:9851 and CATCHCATCH_TILE38_ADDR
+type Config struct {
+	Tile38Addr        string
+	Tile38Connections int
+	Port              int
+	WebDir            string
+	Zeroconf          bool
+	ZeroconfName      string
+	ZeroconfRefresh   time.Duration
+	WSDriver          string
+	LogLevel          string
+	LogFormat         string
+
+	TLSCert           string
+	TLSKey            string
+	TLSReloadInterval time.Duration
+	HTTPRedirectPort  int
+	WSAllowedOrigins  string
+
+	Heartbeat       WSHeartbeat
+	SendQueueSize   int
+	SendQueuePolicy string
+	PositionUpdates PositionUpdateConfig
+	InterestRadius  float64
+
+	AuthSecret    string
+	AuthIssue     string
+	AuthIssueRole string
+	AuthTokenTTL  time.Duration
+	AnonymousRole string
+
+	AuditLogPath    string
+	AuditLogMaxSize int64
+	AuditLogBackups int
+
+	InfluxDBAddr string
+	InfluxDBName string
+	InfluxDBUser string
+	InfluxDBPass string
+	InfluxBuffer MetricsBufferConfig
+	Prometheus   bool
+
+	ClusterNode      string
+	ClusterRedisAddr string
+	GameLeaseTTL     time.Duration
+	Game             GameRules
+
+	ShutdownTimeout time.Duration
+	ShutdownHint    ShutdownHint
+}
+
+// DefaultConfig returns the server defaults
+func DefaultConfig() *Config {
+	return &Config{
+		Tile38Addr: "localhost:9851", Tile38Connections: 100, Port: 5000, WebDir: "../web",
+		ZeroconfName: "CatchCatch", ZeroconfRefresh: 30 * time.Second,
+		WSDriver: "xnet", LogLevel: "info", LogFormat: "logfmt",
+		Heartbeat: DefaultWSHeartbeat, SendQueueSize: DefaultSendQueue.Size, SendQueuePolicy: string(DefaultSendQueue.Policy),
+		PositionUpdates: DefaultPositionUpdates, InterestRadius: DefaultInterestRadius,
+		AuthIssueRole: "player", AuthTokenTTL: 30 * 24 * time.Hour, AnonymousRole: "player",
+		AuditLogPath: "audit.log", AuditLogMaxSize: 10 * 1024 * 1024, AuditLogBackups: 5,
+		InfluxDBName: "catchcatch", InfluxBuffer: DefaultMetricsBuffer,
+		ClusterNode: hostname(), GameLeaseTTL: DefaultLeaseTTL, Game: DefaultGameRules,
+		ShutdownTimeout: DefaultShutdownTimeout, ShutdownHint: DefaultShutdownHint,
+	}
+}
+
+// Flags registers every setting on fs using the current values as defaults
+func (c *Config) Flags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Tile38Addr, "tile38-addr", c.Tile38Addr, "redis address")
+	fs.IntVar(&c.Tile38Connections, "tile38-connections", c.Tile38Connections, "max connections to tile38")
+	fs.IntVar(&c.Port, "port", c.Port, "server port")
+	fs.StringVar(&c.WebDir, "web-dir", c.WebDir, "web files dir")
+	fs.BoolVar(&c.Zeroconf, "zconf", c.Zeroconf, "start zeroconf server")
+	fs.StringVar(&c.ZeroconfName, "zconf-name", c.ZeroconfName, "server name advertised by zeroconf")
+	fs.DurationVar(&c.ZeroconfRefresh, "zconf-refresh-interval", c.ZeroconfRefresh, "time between updates of the players and games advertised by zeroconf")
+	fs.StringVar(&c.WSDriver, "wsdriver", c.WSDriver, "options: xnet, gobwas")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "options: debug, info, warn, error (debug logs tile38 commands), changed at runtime with /admin/log-level")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "options: logfmt, json")
+
+	fs.StringVar(&c.TLSCert, "tls-cert", c.TLSCert, "tls certificate file, serves https and wss with -tls-key (empty serves http)")
+	fs.StringVar(&c.TLSKey, "tls-key", c.TLSKey, "tls private key file")
+	fs.DurationVar(&c.TLSReloadInterval, "tls-reload-interval", c.TLSReloadInterval, "time between checks to reload changed tls files (0 disables reloading)")
+	fs.IntVar(&c.HTTPRedirectPort, "http-redirect-port", c.HTTPRedirectPort, "port redirecting http to https (0 disables the redirect)")
+	fs.StringVar(&c.WSAllowedOrigins, "ws-allowed-origins", c.WSAllowedOrigins, "comma separated origins allowed to open /ws besides the server host, * allows any origin")
+
+	fs.DurationVar(&c.Heartbeat.PingInterval, "ws-ping-interval", c.Heartbeat.PingInterval, "time between WS pings (0 disables pings)")
+	fs.DurationVar(&c.Heartbeat.ReadTimeout, "ws-read-timeout", c.Heartbeat.ReadTimeout, "close WS connections silent for this long (0 disables)")
+	fs.DurationVar(&c.Heartbeat.WriteTimeout, "ws-write-timeout", c.Heartbeat.WriteTimeout, "max time to write to a WS connection (0 disables)")
+	fs.IntVar(&c.SendQueueSize, "ws-send-queue-size", c.SendQueueSize, "max messages queued per WS connection (0 sends synchronously)")
+	fs.StringVar(&c.SendQueuePolicy, "ws-send-queue-policy", c.SendQueuePolicy, "full send queue policy: drop-oldest-position, disconnect")
+
+	fs.Float64Var(&c.PositionUpdates.MaxPerSecond, "player-max-updates", c.PositionUpdates.MaxPerSecond, "max position updates per second per player (0 disables the limit)")
+	fs.IntVar(&c.PositionUpdates.Burst, "player-update-burst", c.PositionUpdates.Burst, "position updates a player can send at once")
+	fs.DurationVar(&c.PositionUpdates.BatchInterval, "position-batch-interval", c.PositionUpdates.BatchInterval, "time between remote-players:batch broadcasts (0 broadcasts every update)")
+	fs.Float64Var(&c.InterestRadius, "interest-radius", c.InterestRadius, "meters around a player where remote players are visible (0 sends every player)")
+
+	fs.StringVar(&c.AuthSecret, "auth-secret", c.AuthSecret, "secret to validate /ws tokens (empty disables authentication)")
+	fs.StringVar(&c.AuthIssue, "auth-issue", c.AuthIssue, "print a token for this subject signed with -auth-secret and exit")
+	fs.StringVar(&c.AuthIssueRole, "auth-issue-role", c.AuthIssueRole, "role of the token printed by -auth-issue: player, admin, observer")
+	fs.DurationVar(&c.AuthTokenTTL, "auth-token-ttl", c.AuthTokenTTL, "expiration of tokens printed by -auth-issue")
+	fs.StringVar(&c.AnonymousRole, "anonymous-role", c.AnonymousRole, "role of connections when -auth-secret is not set")
+
+	fs.StringVar(&c.AuditLogPath, "audit-log", c.AuditLogPath, "admin actions audit log file")
+	fs.Int64Var(&c.AuditLogMaxSize, "audit-log-max-size", c.AuditLogMaxSize, "audit log file size in bytes to rotate")
+	fs.IntVar(&c.AuditLogBackups, "audit-log-backups", c.AuditLogBackups, "number of rotated audit log files to keep")
+
+	fs.StringVar(&c.InfluxDBAddr, "influxdb-addr", c.InfluxDBAddr, "influxdb address, eg: http://localhost:8086 (empty disables influxdb)")
+	fs.StringVar(&c.InfluxDBName, "influxdb-db", c.InfluxDBName, "influxdb database name")
+	fs.StringVar(&c.InfluxDBUser, "influxdb-user", c.InfluxDBUser, "influxdb user")
+	fs.StringVar(&c.InfluxDBPass, "influxdb-pass", c.InfluxDBPass, "influxdb password")
+	fs.IntVar(&c.InfluxBuffer.Size, "influxdb-buffer-size", c.InfluxBuffer.Size, "max metrics points waiting to be written, new points are dropped when full")
+	fs.IntVar(&c.InfluxBuffer.BatchSize, "influxdb-batch-size", c.InfluxBuffer.BatchSize, "metrics points written at once")
+	fs.DurationVar(&c.InfluxBuffer.FlushInterval, "influxdb-flush-interval", c.InfluxBuffer.FlushInterval, "max time a metrics point waits to be written")
+	fs.BoolVar(&c.Prometheus, "prometheus", c.Prometheus, "export metrics to prometheus at /metrics")
+
+	fs.StringVar(&c.ClusterNode, "cluster-node", c.ClusterNode, "name of this server in the cluster")
+	fs.StringVar(&c.ClusterRedisAddr, "cluster-redis-addr", c.ClusterRedisAddr, "redis address of the cluster message bus (empty runs a single server)")
+	fs.DurationVar(&c.GameLeaseTTL, "game-lease-ttl", c.GameLeaseTTL, "time a replica keeps watching a game without renewing its lease")
+
+	fs.IntVar(&c.Game.MinPlayers, "game-min-players", c.Game.MinPlayers, "players in a geofence to start a game")
+	fs.DurationVar(&c.Game.Duration, "game-duration", c.Game.Duration, "max time of a game")
+	fs.Float64Var(&c.Game.TargetReachedDistance, "game-target-reached-distance", c.Game.TargetReachedDistance, "meters from the target a hunter catches it")
+	fs.Float64Var(&c.Game.TargetNearDistance, "game-target-near-distance", c.Game.TargetNearDistance, "meters from the target a hunter is warned to be near")
+
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "max time to finish games and drain connections on exit")
+	fs.DurationVar(&c.ShutdownHint.ReconnectIn, "shutdown-reconnect-in", c.ShutdownHint.ReconnectIn, "time clients wait to reconnect after server:shutdown")
+	fs.StringVar(&c.ShutdownHint.ReconnectURL, "shutdown-reconnect-url", c.ShutdownHint.ReconnectURL, "address clients reconnect to after server:shutdown (empty means this one)")
+}
+
+// LoadConfig reads the configuration from the defaults, then the file set by -config or CATCHCATCH_CONFIG,
+// then the CATCHCATCH_* env vars and then args, each one overriding the previous
+func LoadConfig(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	c := DefaultConfig()
+	fs := flag.NewFlagSet("catchcatch-server", flag.ContinueOnError)
+	path := fs.String("config", "", "yaml config file, its keys are the flag names (env: "+ConfigEnvVar("config")+")")
+	c.Flags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	explicit := map[string]bool{"config": true}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if *path == "" {
+		*path, _ = lookupEnv(ConfigEnvVar("config"))
+	}
+	if *path != "" {
+		settings, err := readConfigFile(*path)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %v", *path, err)
+		}
+		for _, name := range sortedKeys(settings) {
+			if fs.Lookup(name) == nil || name == "config" {
+				return nil, fmt.Errorf("config %s: unknown setting %q", *path, name)
+			}
+			if explicit[name] {
+				continue
+			}
+			if err := fs.Set(name, settings[name]); err != nil {
+				return nil, fmt.Errorf("config %s: %s: %v", *path, name, err)
+			}
+		}
+	}
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, exists := lookupEnv(ConfigEnvVar(f.Name))
+		if !exists || explicit[f.Name] || envErr != nil {
+			return
+		}
+		if err := fs.Set(f.Name, value); err != nil {
+			envErr = fmt.Errorf("%s: %v", ConfigEnvVar(f.Name), err)
+		}
+	})
+	if envErr != nil {
+		return nil, envErr
+	}
+	return c, c.Validate()
+}
+
+// ConfigEnvVar is the env var of a setting, eg: tile38-addr is CATCHCATCH_TILE38_ADDR
+func ConfigEnvVar(name string) string {
+	return ConfigEnvPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// readConfigFile reads a yaml map of setting: value
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := yaml.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if file.Root == nil {
+		return map[string]string{}, nil
+	}
+	root, ok := file.Root.(yaml.Map)
+	if !ok {
+		return nil, ErrInvalidConfigFile
+	}
+	settings := make(map[string]string, len(root))
+	for name, node := range root {
+		value, ok := node.(yaml.Scalar)
+		if !ok {
+			return nil, fmt.Errorf("%s: must be a single value", name)
+		}
+		if settings[name], err = configValue(value.String()); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return settings, nil
+}
+
+// configValue removes quotes and trailing comments from a yaml value
+func configValue(value string) (string, error) {
+	if strings.HasPrefix(value, `"`) {
+		end := strings.LastIndex(value, `"`)
+		if end == 0 || strings.TrimSpace(strings.SplitN(value[end+1:], "#", 2)[0]) != "" {
+			return "", errors.New("invalid quoted value")
+		}
+		return strconv.Unquote(value[:end+1])
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Validate checks the settings values, every invalid setting is reported
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(setting string, valid bool, format string, args ...interface{}) {
+		if !valid {
+			errs = append(errs, fmt.Errorf("%s: "+format, append([]interface{}{setting}, args...)...))
+		}
+	}
+	check("port", c.Port > 0 && c.Port < 65536, "must be between 1 and 65535, got %d", c.Port)
+	check("tile38-addr", c.Tile38Addr != "", "is required")
+	check("tile38-connections", c.Tile38Connections > 0, "must be positive, got %d", c.Tile38Connections)
+	check("zconf-name", c.ZeroconfName != "", "is required")
+	check("zconf-refresh-interval", c.ZeroconfRefresh > 0, "must be positive, got %v", c.ZeroconfRefresh)
+	check("wsdriver", c.WSDriver == "xnet" || c.WSDriver == "gobwas", "options: xnet, gobwas, got %q", c.WSDriver)
+	_, err := ParseLogLevel(c.LogLevel)
+	check("log-level", err == nil, "%v, got %q", err, c.LogLevel)
+	check("log-format", c.LogFormat == "logfmt" || c.LogFormat == "json", "%v, got %q", ErrUnknownLogFormat, c.LogFormat)
+
+	check("tls-cert", (c.TLSCert == "") == (c.TLSKey == ""), "must be set together with tls-key")
+	check("tls-reload-interval", c.TLSReloadInterval >= 0, "can't be negative, got %v", c.TLSReloadInterval)
+	if c.HTTPRedirectPort != 0 {
+		check("http-redirect-port", c.HTTPRedirectPort > 0 && c.HTTPRedirectPort < 65536, "must be between 1 and 65535, got %d", c.HTTPRedirectPort)
+		check("http-redirect-port", c.TLSCert != "", "requires tls-cert and tls-key")
+		check("http-redirect-port", c.HTTPRedirectPort != c.Port, "must be different from port, got %d", c.HTTPRedirectPort)
+	}
+	_, err = ParseOrigins(c.WSAllowedOrigins)
+	check("ws-allowed-origins", err == nil, "%v", err)
+
+	check("ws-send-queue-size", c.SendQueueSize >= 0, "can't be negative, got %d", c.SendQueueSize)
+	_, err = ParseSendPolicy(c.SendQueuePolicy)
+	check("ws-send-queue-policy", err == nil, "%v", err)
+	check("player-max-updates", c.PositionUpdates.MaxPerSecond >= 0, "can't be negative, got %v", c.PositionUpdates.MaxPerSecond)
+	check("player-update-burst", c.PositionUpdates.Burst > 0, "must be positive, got %d", c.PositionUpdates.Burst)
+	check("interest-radius", c.InterestRadius >= 0, "can't be negative, got %v", c.InterestRadius)
+
+	_, err = ParseConnRole(c.AnonymousRole)
+	check("anonymous-role", err == nil, "%v, got %q", err, c.AnonymousRole)
+	_, err = ParseConnRole(c.AuthIssueRole)
+	check("auth-issue-role", err == nil, "%v, got %q", err, c.AuthIssueRole)
+
+	if c.InfluxDBAddr != "" {
+		u, err := url.Parse(c.InfluxDBAddr)
+		check("influxdb-addr", err == nil && u.Scheme != "" && u.Host != "", "must be an url like http://localhost:8086, got %q", c.InfluxDBAddr)
+	}
+	check("influxdb-buffer-size", c.InfluxBuffer.Size >= 0, "can't be negative, got %d", c.InfluxBuffer.Size)
+	check("influxdb-batch-size", c.InfluxBuffer.BatchSize > 0, "must be positive, got %d", c.InfluxBuffer.BatchSize)
+	check("influxdb-flush-interval", c.InfluxBuffer.FlushInterval > 0, "must be positive, got %v", c.InfluxBuffer.FlushInterval)
+
+	check("cluster-node", c.ClusterNode != "", "is required")
+	check("game-lease-ttl", c.GameLeaseTTL > 0, "must be positive, got %v", c.GameLeaseTTL)
+	check("game-min-players", c.Game.MinPlayers >= 2, "must be at least 2, got %d", c.Game.MinPlayers)
+	check("game-duration", c.Game.Duration > 0, "must be positive, got %v", c.Game.Duration)
+	check("game-target-reached-distance", c.Game.TargetReachedDistance > 0, "must be positive, got %v", c.Game.TargetReachedDistance)
+	check("game-target-near-distance", c.Game.TargetNearDistance > c.Game.TargetReachedDistance,
+		"must be greater than game-target-reached-distance, got %v", c.Game.TargetNearDistance)
+	check("shutdown-timeout", c.ShutdownTimeout > 0, "must be positive, got %v", c.ShutdownTimeout)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "catchcatch.yml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func fakeEnv(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, exists := vars[name]
+		return value, exists
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `# production
+tile38-addr: tile38.internal:9851
+port: 8080
+web-dir: "/app/web" # quoted
+game-duration: 2m
+game-min-players: 4
+`)
+	env := fakeEnv(map[string]string{"CATCHCATCH_PORT": "9090", "CATCHCATCH_GAME_MIN_PLAYERS": "5"})
+	cfg, err := LoadConfig([]string{"-config", path, "-game-min-players", "6"}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Tile38Addr != "tile38.internal:9851" || cfg.WebDir != "/app/web" || cfg.Game.Duration != 2*time.Minute {
+		t.Fatal("expected the file to override the defaults, got:", cfg.Tile38Addr, cfg.WebDir, cfg.Game.Duration)
+	}
+	if cfg.Port != 9090 {
+		t.Fatal("expected the env to override the file, got:", cfg.Port)
+	}
+	if cfg.Game.MinPlayers != 6 {
+		t.Fatal("expected the flags to override the env, got:", cfg.Game.MinPlayers)
+	}
+	if cfg.InterestRadius != DefaultInterestRadius || cfg.Game.TargetReachedDistance != DefaultGameRules.TargetReachedDistance {
+		t.Fatal("expected the defaults of missing settings, got:", cfg.InterestRadius, cfg.Game)
+	}
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	path := writeConfigFile(t, "port: 7000\n")
+	cfg, err := LoadConfig(nil, fakeEnv(map[string]string{"CATCHCATCH_CONFIG": path}))
+	if err != nil || cfg.Port != 7000 {
+		t.Fatal("expected CATCHCATCH_CONFIG to be loaded, got:", err, cfg)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	noEnv := fakeEnv(nil)
+	cases := []struct {
+		name     string
+		file     string
+		env      map[string]string
+		expected string
+	}{
+		{"unknown setting", "tile38-adr: localhost\n", nil, `unknown setting "tile38-adr"`},
+		{"nested setting", "tile38:\n  addr: localhost\n", nil, "tile38: must be a single value"},
+		{"invalid file value", "port: http\n", nil, `port: parse error`},
+		{"invalid env value", "", map[string]string{"CATCHCATCH_GAME_DURATION": "1 minute"}, "CATCHCATCH_GAME_DURATION"},
+		{"validation", "port: 0\ngame-target-near-distance: 10\nlog-level: verbose\n", nil,
+			"port: must be between 1 and 65535, got 0\nlog-level: " + ErrUnknownLogLevel.Error() + `, got "verbose"` +
+				"\ngame-target-near-distance: must be greater than game-target-reached-distance, got 10"},
+	}
+	for _, c := range cases {
+		env := noEnv
+		if c.env != nil {
+			env = fakeEnv(c.env)
+		}
+		_, err := LoadConfig([]string{"-config", writeConfigFile(t, c.file)}, env)
+		if err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Errorf("%s: expected error with %q, got: %v", c.name, c.expected, err)
+		}
+	}
+}
+
+func TestExampleConfigIsValid(t *testing.T) {
+	if _, err := LoadConfig([]string{"-config", "catchcatch.example.yml"}, fakeEnv(nil)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateTLSSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TLSCert = "cert.pem"
+	cfg.HTTPRedirectPort = cfg.Port
+	cfg.WSAllowedOrigins = "admin.catchcatch.test"
+	err := cfg.Validate()
+	for _, expected := range []string{"tls-cert: must be set together with tls-key",
+		"http-redirect-port: must be different from port", "ws-allowed-origins: invalid origin"} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error with %q, got: %v", expected, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+// Request is an event received by a connection
+type Request struct {
+	EventName string
+	RequestID string
+	// Payload is the decoded envelope payload, nil for legacy frames
+	Payload proto.Message
+
+	raw []byte
+}
+
+// ReplyID returns the request id to be echoed on replies
+func (r *Request) ReplyID() *string {
+	if r.RequestID == "" {
+		return nil
+	}
+	return proto.String(r.RequestID)
+}
+
+// HandleEvent registers a typed callback for event on c
+// the payload is decoded once into PT, decode failures are replied as error:invalid-message
+func HandleEvent[T any, PT interface {
+	*T
+	proto.Message
+}](c *WSConnListener, event string, callback func(*Request, PT)) {
+	c.On(event, func(req *Request) {
+		msg, err := decodeRequest[T, PT](c, req)
+		if err != nil {
+			c.EmitError(event, req.RequestID, ErrCodeInvalidMessage, err)
+			return
+		}
+		callback(req, msg)
+	})
+}
+
+func decodeRequest[T any, PT interface {
+	*T
+	proto.Message
+}](c *WSConnListener, req *Request) (PT, error) {
+	if req.Payload != nil {
+		msg, ok := req.Payload.(PT)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %T payload for %s", req.Payload, req.EventName)
+		}
+		return msg, nil
+	}
+	msg := PT(new(T))
+	if err := c.codec.Unmarshal(req.raw, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// NewEnvelope wraps message on an Envelope of the protocol version
+func NewEnvelope(version uint32, message Message) (*protobuf.Envelope, error) {
+	env := &protobuf.Envelope{EventName: proto.String(message.GetEventName()),
+		Version: proto.Uint32(version)}
+	if m, ok := message.(interface{ GetRequestId() string }); ok && m.GetRequestId() != "" {
+		env.RequestId = proto.String(m.GetRequestId())
+	}
+	switch m := message.(type) {
+	case *protobuf.Simple:
+		env.Payload = &protobuf.Envelope_Simple{Simple: m}
+	case *protobuf.Feature:
+		env.Payload = &protobuf.Envelope_Feature{Feature: m}
+	case *protobuf.Player:
+		env.Payload = &protobuf.Envelope_Player{Player: m}
+	case *protobuf.PlayerHello:
+		env.Payload = &protobuf.Envelope_PlayerHello{PlayerHello: m}
+	case *protobuf.GameInfo:
+		env.Payload = &protobuf.Envelope_GameInfo{GameInfo: m}
+	case *protobuf.GameRank:
+		env.Payload = &protobuf.Envelope_GameRank{GameRank: m}
+	case *protobuf.Distance:
+		env.Payload = &protobuf.Envelope_Distance{Distance: m}
+	case *protobuf.Detection:
+		env.Payload = &protobuf.Envelope_Detection{Detection: m}
+	case *protobuf.Auth:
+		env.Payload = &protobuf.Envelope_Auth{Auth: m}
+	case *protobuf.Error:
+		env.Payload = &protobuf.Envelope_Error{Error: m}
+	case *protobuf.AuditRequest:
+		env.Payload = &protobuf.Envelope_AuditRequest{AuditRequest: m}
+	case *protobuf.AuditPage:
+		env.Payload = &protobuf.Envelope_AuditPage{AuditPage: m}
+	case *protobuf.ListEnd:
+		env.Payload = &protobuf.Envelope_ListEnd{ListEnd: m}
+	case *protobuf.ProtocolHello:
+		env.Payload = &protobuf.Envelope_ProtocolHello{ProtocolHello: m}
+	case *protobuf.PlayerBatch:
+		env.Payload = &protobuf.Envelope_PlayerBatch{PlayerBatch: m}
+	case *protobuf.ServerShutdown:
+		env.Payload = &protobuf.Envelope_ServerShutdown{ServerShutdown: m}
+	case *protobuf.Envelope:
+		return m, nil
+	default:
+		return nil, fmt.Errorf("message %T can't be sent on an envelope", message)
+	}
+	return env, nil
+}
+
+// EnvelopePayload returns the message wrapped on env or nil when it is empty
+func EnvelopePayload(env *protobuf.Envelope) proto.Message {
+	switch p := env.Payload.(type) {
+	case *protobuf.Envelope_Simple:
+		return p.Simple
+	case *protobuf.Envelope_Feature:
+		return p.Feature
+	case *protobuf.Envelope_Player:
+		return p.Player
+	case *protobuf.Envelope_PlayerHello:
+		return p.PlayerHello
+	case *protobuf.Envelope_GameInfo:
+		return p.GameInfo
+	case *protobuf.Envelope_GameRank:
+		return p.GameRank
+	case *protobuf.Envelope_Distance:
+		return p.Distance
+	case *protobuf.Envelope_Detection:
+		return p.Detection
+	case *protobuf.Envelope_Auth:
+		return p.Auth
+	case *protobuf.Envelope_Error:
+		return p.Error
+	case *protobuf.Envelope_AuditRequest:
+		return p.AuditRequest
+	case *protobuf.Envelope_AuditPage:
+		return p.AuditPage
+	case *protobuf.Envelope_ListEnd:
+		return p.ListEnd
+	case *protobuf.Envelope_ProtocolHello:
+		return p.ProtocolHello
+	case *protobuf.Envelope_PlayerBatch:
+		return p.PlayerBatch
+	case *protobuf.Envelope_ServerShutdown:
+		return p.ServerShutdown
+	}
+	return nil
+}
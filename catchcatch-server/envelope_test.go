@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+type fakeWSConn struct {
+	request *http.Request
+	frames  [][]byte
+	sent    [][]byte
+}
+
+func (c *fakeWSConn) Read(buf *[]byte) (int, error) {
+	if len(c.frames) == 0 {
+		return 0, io.EOF
+	}
+	frame := c.frames[0]
+	c.frames = c.frames[1:]
+	if len(frame) > len(*buf) {
+		*buf = make([]byte, len(frame))
+	}
+	return copy(*buf, frame), nil
+}
+
+func (c *fakeWSConn) Send(payload []byte) error {
+	c.sent = append(c.sent, payload)
+	return nil
+}
+
+func (c *fakeWSConn) SendText(payload []byte) error {
+	return c.Send(payload)
+}
+
+func (c *fakeWSConn) Close() error           { return nil }
+func (c *fakeWSConn) Request() *http.Request { return c.request }
+
+func newTestListener(frames ...proto.Message) (*WSConnListener, *fakeWSConn) {
+	conn := &fakeWSConn{}
+	for _, f := range frames {
+		payload, _ := proto.Marshal(f)
+		conn.frames = append(conn.frames, payload)
+	}
+	return NewWSServer(nil, SendQueueConfig{}, nil).Add(conn), conn
+}
+
+func TestHandleEventDecodesEnvelopePayload(t *testing.T) {
+	player := &protobuf.Player{EventName: proto.String("player:update"), Id: proto.String("p1"),
+		Lat: proto.Float64(1), Lon: proto.Float64(2)}
+	env, _ := NewEnvelope(CurrentProtocolVersion, player)
+	env.RequestId = proto.String("r1")
+	c, conn := newTestListener(env)
+
+	var received *protobuf.Player
+	HandleEvent(c, "player:update", func(req *Request, msg *protobuf.Player) {
+		if req.RequestID != "r1" {
+			t.Fatal("unexpected request id:", req.RequestID)
+		}
+		received = msg
+	})
+	if err := c.readMessage(); err != nil {
+		t.Fatal(err)
+	}
+	if received.GetId() != "p1" || received.GetLat() != 1 {
+		t.Fatal("unexpected message:", received)
+	}
+
+	c.Emit(&protobuf.Simple{EventName: proto.String("pong")})
+	reply := &protobuf.Envelope{}
+	if err := proto.Unmarshal(conn.sent[0], reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.GetVersion() != CurrentProtocolVersion || reply.GetSimple().GetEventName() != "pong" {
+		t.Fatal("expected envelope reply, got:", reply)
+	}
+}
+
+func TestHandleEventDecodesLegacyFrames(t *testing.T) {
+	c, conn := newTestListener(&protobuf.Player{EventName: proto.String("player:update"), Id: proto.String("p1"),
+		Lat: proto.Float64(1), Lon: proto.Float64(2), RequestId: proto.String("r1")})
+
+	var received *protobuf.Player
+	HandleEvent(c, "player:update", func(req *Request, msg *protobuf.Player) {
+		received = msg
+	})
+	if err := c.readMessage(); err != nil {
+		t.Fatal(err)
+	}
+	if received.GetId() != "p1" || received.GetRequestId() != "r1" {
+		t.Fatal("unexpected message:", received)
+	}
+
+	c.Emit(&protobuf.Simple{EventName: proto.String("pong")})
+	reply := &protobuf.Simple{}
+	if err := proto.Unmarshal(conn.sent[0], reply); err != nil || reply.GetEventName() != "pong" {
+		t.Fatal("expected legacy reply, got:", reply, err)
+	}
+}
+
+func TestHandleEventRepliesDecodeFailures(t *testing.T) {
+	hello := &protobuf.ProtocolHello{EventName: proto.String("protocol:hello"),
+		Version: proto.Uint32(CurrentProtocolVersion), Capabilities: []string{CapErrors}}
+	env := &protobuf.Envelope{EventName: proto.String("player:update"), Version: proto.Uint32(CurrentProtocolVersion),
+		RequestId: proto.String("r1"), Payload: &protobuf.Envelope_Simple{
+			Simple: &protobuf.Simple{EventName: proto.String("player:update")}}}
+	c, conn := newTestListener(hello, env)
+
+	HandleEvent(c, "player:update", func(*Request, *protobuf.Player) {
+		t.Fatal("callback must not be called")
+	})
+	for i := 0; i < 2; i++ {
+		if err := c.readMessage(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reply := &protobuf.Envelope{}
+	if err := proto.Unmarshal(conn.sent[1], reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.GetEventName() != "error:"+ErrCodeInvalidMessage || reply.GetRequestId() != "r1" ||
+		reply.GetError().GetId() != "player:update" {
+		t.Fatal("unexpected reply:", reply)
+	}
+}
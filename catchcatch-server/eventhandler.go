@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 
@@ -14,14 +16,22 @@ import (
 
 // EventHandler handle websocket events
 type EventHandler struct {
-	server  *WSServer
-	service PlayerLocationService
-	games   *GameWatcher
+	server    *WSServer
+	service   PlayerLocationService
+	games     *GameWatcher
+	profiles  PlayerProfileStore
+	auth      Authenticator
+	audit     AuditLog
+	positions *PositionUpdates
+	interest  *AreaOfInterest
+	logger    *slog.Logger
 }
 
 // NewEventHandler EventHandler builder
-func NewEventHandler(server *WSServer, service PlayerLocationService, gw *GameWatcher) *EventHandler {
-	handler := &EventHandler{server, service, gw}
+func NewEventHandler(server *WSServer, service PlayerLocationService, gw *GameWatcher,
+	profiles PlayerProfileStore, auth Authenticator, audit AuditLog,
+	positions *PositionUpdates, interest *AreaOfInterest, logger *slog.Logger) *EventHandler {
+	handler := &EventHandler{server, service, gw, profiles, auth, audit, positions, interest, loggerOrDefault(logger)}
 	server.OnConnected(handler.onConnection)
 	return handler
 }
@@ -34,165 +44,430 @@ func (h *EventHandler) Listen(ctx context.Context) http.Handler {
 // Event handlers
 
 func (h *EventHandler) onConnection(c *WSConnListener) {
+	if token := authTokenFromRequest(c.Request()); token != "" || !h.auth.Required() {
+		h.authenticate(c, token)
+		return
+	}
+
+	authenticated, once := make(chan struct{}), sync.Once{}
+	HandleEvent(c, "auth:token", func(_ *Request, msg *protobuf.Auth) {
+		once.Do(func() {
+			close(authenticated)
+			h.authenticate(c, msg.GetToken())
+		})
+	})
+	go func() {
+		select {
+		case <-authenticated:
+		case <-time.After(AuthTimeout):
+			h.rejectConnection(c, ErrTokenRequired)
+		}
+	}()
+}
+
+func (h *EventHandler) onAuthenticated(c *WSConnListener, claims *AuthClaims) {
+	role, err := ParseConnRole(claims.Role)
+	if err != nil {
+		h.rejectConnection(c, err)
+		return
+	}
+	c.Role, c.Subject = role, claims.Subject
+	if err := h.server.Accept(c); err != nil {
+		h.logger.Info("connection refused", LogConnID, c.ID(), "error", err)
+		c.Close()
+		return
+	}
+	if role == RoleObserver {
+		h.onObserverConnection(c, claims)
+		return
+	}
+
 	player, err := h.newPlayer(c)
 	if err != nil {
-		log.Println("error to create player", err)
+		h.logger.Error("error to create player", LogConnID, c.ID(), "error", err)
 		c.Close()
 		return
 	}
-	log.Println("new player connected", player)
-	go h.sendPlayerList(c)
+	h.logger.Info("player connected", LogConnID, c.ID(), LogPlayerID, player.ID, "subject", claims.Subject, "role", role)
+	go h.sendPlayerList(c, nil)
 
-	c.On("player:request-games", h.onPlayerRequestGames(player, c))
-	c.On("player:request-remotes", h.onPlayerRequestRemotes(c))
-	c.On("player:update", h.onPlayerUpdate(player, c))
-	c.OnDisconnected(h.onPlayerDisconnect(player))
+	HandleEvent(c, "player:hello", h.onPlayerHello(player, c))
+	HandleEvent(c, "player:request-games", h.onPlayerRequestGames(player, c))
+	HandleEvent(c, "player:request-remotes", h.onPlayerRequestRemotes(c))
+	HandleEvent(c, "player:update", h.onPlayerUpdate(player, c))
+	c.OnDisconnected(h.onPlayerDisconnect(player, c))
 
-	c.On("admin:disconnect", h.onDisconnectByID())
-	c.On("admin:feature:add", h.onAddFeature())
-	c.On("admin:feature:request-list", h.onRequestFeatures(c))
-	c.On("admin:clear", h.onClear())
+	if role == RoleAdmin {
+		h.server.Join(RoomAdmin, c.ID())
+		h.registerAdminEvents(c)
+	} else {
+		h.denyAdminEvents(c)
+	}
+}
+
+// onObserverConnection registers read only connections, used by dashboards
+// they are not players and can't change anything but can list the map
+func (h *EventHandler) onObserverConnection(c *WSConnListener, claims *AuthClaims) {
+	h.logger.Info("observer connected", LogConnID, c.ID(), "subject", claims.Subject)
+	go h.sendPlayerList(c, nil)
+
+	HandleEvent(c, "player:request-remotes", h.onPlayerRequestRemotes(c))
+	h.denyAdminEvents(c)
+	HandleEvent(c, "admin:feature:request-list", h.onRequestFeatures(c))
+	HandleEvent(c, "admin:subscribe", h.onSubscribe(c))
+	HandleEvent(c, "admin:unsubscribe", h.onUnsubscribe(c))
+	h.server.Join(RoomAdmin, c.ID())
+}
+
+func (h *EventHandler) registerAdminEvents(c *WSConnListener) {
+	HandleEvent(c, "admin:disconnect", h.onDisconnectByID(c))
+	HandleEvent(c, "admin:feature:add", h.onAddFeature(c))
+	HandleEvent(c, "admin:feature:request-list", h.onRequestFeatures(c))
+	HandleEvent(c, "admin:clear", h.onClear(c))
+	HandleEvent(c, "admin:audit:request", h.onAuditRequest(c))
+	HandleEvent(c, "admin:subscribe", h.onSubscribe(c))
+	HandleEvent(c, "admin:unsubscribe", h.onUnsubscribe(c))
+}
+
+func (h *EventHandler) denyAdminEvents(c *WSConnListener) {
+	for _, event := range adminEvents {
+		c.On(event, h.onUnauthorized(event, c))
+	}
 }
 
 // Player events
 
-func (h *EventHandler) onPlayerDisconnect(player *model.Player) func() {
+func (h *EventHandler) onPlayerDisconnect(player *model.Player, c *WSConnListener) func() {
 	return func() {
-		log.Println("player:disconnect", player.ID)
-		h.server.Broadcast(&protobuf.Player{EventName: proto.String("remote-player:destroy"),
-			Id: &player.ID, Lon: &player.Lon, Lat: &player.Lat})
+		if current := h.server.Get(player.ID); current != nil && current != c {
+			h.logger.Info("player connection replaced", LogConnID, c.ID(), LogPlayerID, player.ID, LogEvent, "player:disconnect")
+			return
+		}
+		h.logger.Info("player disconnected", LogConnID, c.ID(), LogPlayerID, player.ID, LogEvent, "player:disconnect")
+		h.positions.Forget(player.ID)
+		h.interest.PlayerLeft(player)
 		h.service.Remove(player)
 	}
 }
 
-func (h *EventHandler) onPlayerUpdate(player *model.Player, c *WSConnListener) func([]byte) {
-	return func(buf []byte) {
-		msg := &protobuf.Player{}
-		proto.Unmarshal(buf, msg)
+func (h *EventHandler) onPlayerHello(player *model.Player, c *WSConnListener) func(*Request, *protobuf.PlayerHello) {
+	return func(req *Request, msg *protobuf.PlayerHello) {
+		profile, token, err := IdentifyPlayer(h.profiles, player.ID, msg.GetId(), msg.GetToken(), msg.GetName(), msg.GetColor())
+		if err == ErrInvalidProfileToken {
+			c.EmitError("player:hello", req.RequestID, ErrCodeUnauthorized, err)
+			return
+		} else if err != nil {
+			h.logger.Error("error to identify player", LogConnID, c.ID(), LogPlayerID, player.ID, LogEvent, "player:hello", "error", err)
+			c.EmitError("player:hello", req.RequestID, ErrCodeInternal, err)
+			return
+		}
+		if profile.ID != player.ID {
+			if err := h.server.Rename(c, profile.ID); err != nil {
+				h.logger.Warn("player id in use", LogConnID, c.ID(), LogPlayerID, profile.ID, LogEvent, "player:hello", "error", err)
+				c.EmitError("player:hello", req.RequestID, ErrCodeConflict, err)
+				return
+			}
+			h.interest.Renamed(player.ID, profile.ID)
+			h.positions.Forget(player.ID)
+			h.service.Remove(player)
+			h.interest.PlayerLeft(player)
+			player.ID = profile.ID
+		}
+		player.Name, player.Color = profile.Name, profile.Color
+		if err := h.service.Register(player); err != nil {
+			h.logger.Error("error to register player", LogConnID, c.ID(), LogPlayerID, player.ID, LogEvent, "player:hello", "error", err)
+			c.EmitError("player:hello", req.RequestID, ErrCodeInternal, err)
+			return
+		}
+		h.logger.Info("player registered", LogConnID, c.ID(), LogPlayerID, player.ID, LogEvent, "player:hello", "name", player.Name)
+
+		registered := playerMessage("player:registered", player)
+		registered.Token, registered.RequestId = &token, req.ReplyID()
+		c.Emit(registered)
+		h.interest.PlayerJoined(player)
+	}
+}
+
+func (h *EventHandler) onPlayerUpdate(player *model.Player, c *WSConnListener) func(*Request, *protobuf.Player) {
+	return func(req *Request, msg *protobuf.Player) {
 		lat, lon := float64(float32(msg.GetLat())), float64(float32(msg.GetLon()))
 		if lat == 0 || lon == 0 {
 			return
 		}
+		if !h.positions.Allow(player.ID) {
+			if h.positions.Warn(player.ID) {
+				c.EmitError("player:update", req.RequestID, ErrCodeRateLimited, ErrRateLimited)
+			}
+			return
+		}
 		player.Lat, player.Lon = lat, lon
-		h.service.Update(player)
+		if err := h.service.Update(player); err != nil {
+			h.logger.Error("error to update player", LogConnID, c.ID(), LogPlayerID, player.ID, LogEvent, "player:update", "error", err)
+			c.EmitError("player:update", req.RequestID, ErrCodeInternal, err)
+			return
+		}
 
-		c.Emit(&protobuf.Player{EventName: proto.String("player:updated"),
-			Id: &player.ID, Lon: &player.Lon, Lat: &player.Lat})
-		h.server.Broadcast(&protobuf.Player{EventName: proto.String("remote-player:updated"),
-			Id: &player.ID, Lon: &player.Lon, Lat: &player.Lat})
+		updated := playerMessage("player:updated", player)
+		updated.RequestId = req.ReplyID()
+		c.Emit(updated)
+		h.positions.Publish(player)
 	}
 }
 
-func (h *EventHandler) onPlayerRequestRemotes(so *WSConnListener) func([]byte) {
-	return func([]byte) {
-		h.sendPlayerList(so)
+func (h *EventHandler) onPlayerRequestRemotes(c *WSConnListener) func(*Request, *protobuf.Simple) {
+	return func(req *Request, msg *protobuf.Simple) {
+		if err := h.sendPlayerList(c, req.ReplyID()); err != nil {
+			h.logger.Error("error to send players", LogConnID, c.ID(), LogEvent, "player:request-remotes", "error", err)
+			c.EmitError("player:request-remotes", req.RequestID, ErrCodeInternal, err)
+		}
 	}
 }
 
-func (h *EventHandler) onPlayerRequestGames(player *model.Player, c *WSConnListener) func([]byte) {
-	return func([]byte) {
+func (h *EventHandler) onPlayerRequestGames(player *model.Player, c *WSConnListener) func(*Request, *protobuf.Simple) {
+	return func(req *Request, msg *protobuf.Simple) {
 		go func() {
 			games, err := h.service.FeaturesAround("geofences", player.Point())
 			if err != nil {
-				log.Println("Error to request games:", err)
+				h.logger.Error("error to request games", LogConnID, c.ID(), LogPlayerID, player.ID, LogEvent, "player:request-games", "error", err)
+				c.EmitError("player:request-games", req.RequestID, ErrCodeInternal, err)
 				return
 			}
 			event := proto.String("game:around")
 			for _, f := range games {
-				err := c.Emit(&protobuf.Feature{EventName: event, Id: &f.ID, Group: &f.Group, Coords: &f.Coordinates})
+				err := c.Emit(&protobuf.Feature{EventName: event, Id: &f.ID, Group: &f.Group, Coords: &f.Coordinates,
+					RequestId: req.ReplyID()})
 				if err != nil {
-					log.Println("Error to emit", *event, player)
+					h.logger.Info("error to emit", LogConnID, c.ID(), LogPlayerID, player.ID, LogEvent, *event, "error", err)
 				}
 			}
+			c.Emit(listEndMessage(*event, len(games), req.ReplyID()))
 		}()
 	}
 }
 
 // Admin events
 
-func (h *EventHandler) onDisconnectByID() func([]byte) {
-	return func(buf []byte) {
-		msg := &protobuf.Simple{}
-		proto.Unmarshal(buf, msg)
-		log.Println("admin:disconnect", msg.GetId())
+var adminEvents = []string{"admin:disconnect", "admin:feature:add", "admin:feature:request-list",
+	"admin:clear", "admin:audit:request", "admin:subscribe", "admin:unsubscribe"}
+
+// DefaultAuditPageSize is the page size of admin:audit:request when no limit is sent
+const DefaultAuditPageSize = 50
+
+// onUnauthorized accepts any payload, denied requests must be audited even when malformed
+func (h *EventHandler) onUnauthorized(event string, c *WSConnListener) func(*Request) {
+	return func(req *Request) {
+		payload := req.Payload
+		if payload == nil {
+			msg := &protobuf.Simple{}
+			c.Unmarshal(req.raw, msg)
+			payload = msg
+		}
+		h.recordAudit(c, event, payload.String(), ErrUnauthorized)
+		c.EmitError(event, req.RequestID, ErrCodeUnauthorized, ErrUnauthorized)
+	}
+}
+
+func (h *EventHandler) onDisconnectByID(c *WSConnListener) func(*Request, *protobuf.Simple) {
+	return func(req *Request, msg *protobuf.Simple) {
+		h.logger.Info("disconnecting player", LogConnID, c.ID(), LogPlayerID, msg.GetId(), LogEvent, "admin:disconnect")
 		player := &model.Player{ID: msg.GetId()}
-		h.service.Remove(player)
-		h.server.Remove(msg.GetId())
+		err := h.service.Remove(player)
+		if err := h.server.Disconnect(msg.GetId()); err != nil && err != ErrConnNotFound {
+			h.logger.Error("error to disconnect player", LogConnID, c.ID(), LogPlayerID, msg.GetId(), LogEvent, "admin:disconnect", "error", err)
+		}
+		h.recordAudit(c, "admin:disconnect", msg.String(), err)
+		if err != nil {
+			c.EmitError("admin:disconnect", req.RequestID, ErrCodeInternal, err)
+		}
 
-		h.server.Broadcast(&protobuf.Player{EventName: proto.String("remote-player:destroy"),
-			Id: &player.ID, Lon: &player.Lon, Lat: &player.Lat})
+		h.interest.PlayerLeft(player)
 	}
 }
 
-func (h *EventHandler) onClear() func([]byte) {
-	return func([]byte) {
+func (h *EventHandler) onClear(c *WSConnListener) func(*Request, *protobuf.Simple) {
+	return func(req *Request, _ *protobuf.Simple) {
 		h.games.Clear()
-		h.service.Clear()
-		h.server.CloseAll()
+		err := h.service.Clear()
+		h.recordAudit(c, "admin:clear", "", err)
+		if err != nil {
+			h.logger.Error("error to clear", LogConnID, c.ID(), LogEvent, "admin:clear", "error", err)
+			c.EmitError("admin:clear", req.RequestID, ErrCodeInternal, err)
+			return
+		}
+		if err := h.server.DisconnectAll(); err != nil {
+			h.logger.Error("error to disconnect other nodes", LogConnID, c.ID(), LogEvent, "admin:clear", "error", err)
+		}
 	}
 }
 
-// Map events
+// onSubscribe joins the connection to the room sent as id, like game:<id> or geofence:<id>
+func (h *EventHandler) onSubscribe(c *WSConnListener) func(*Request, *protobuf.Simple) {
+	return func(req *Request, msg *protobuf.Simple) {
+		if err := h.server.Join(msg.GetId(), c.ID()); err != nil {
+			c.EmitError("admin:subscribe", req.RequestID, ErrCodeInternal, err)
+			return
+		}
+		c.Emit(&protobuf.Simple{EventName: proto.String("admin:subscribed"), Id: msg.Id, RequestId: req.ReplyID()})
+	}
+}
 
-func (h *EventHandler) onAddFeature() func([]byte) {
-	return func(buf []byte) {
-		msg := &protobuf.Feature{}
-		proto.Unmarshal(buf, msg)
+func (h *EventHandler) onUnsubscribe(c *WSConnListener) func(*Request, *protobuf.Simple) {
+	return func(req *Request, msg *protobuf.Simple) {
+		if err := h.server.Leave(msg.GetId(), c.ID()); err != nil {
+			c.EmitError("admin:unsubscribe", req.RequestID, ErrCodeInternal, err)
+			return
+		}
+		c.Emit(&protobuf.Simple{EventName: proto.String("admin:unsubscribed"), Id: msg.Id, RequestId: req.ReplyID()})
+	}
+}
 
-		f, err := h.service.AddFeature(msg.GetGroup(), msg.GetId(), msg.GetCoords())
+func (h *EventHandler) onAuditRequest(c *WSConnListener) func(*Request, *protobuf.AuditRequest) {
+	return func(req *Request, msg *protobuf.AuditRequest) {
+		offset, limit := int(msg.GetOffset()), int(msg.GetLimit())
+		if offset < 0 {
+			offset = 0
+		}
+		if limit <= 0 || limit > DefaultAuditPageSize {
+			limit = DefaultAuditPageSize
+		}
+
+		entries, total, err := h.audit.Entries(offset, limit)
 		if err != nil {
-			log.Println("Error to create feature:", err)
+			h.logger.Error("error to read audit log", LogConnID, c.ID(), LogEvent, "admin:audit:request", "error", err)
+			c.EmitError("admin:audit:request", req.RequestID, ErrCodeInternal, err)
 			return
 		}
-		h.server.Broadcast(&protobuf.Feature{EventName: proto.String("admin:feature:added"), Id: &f.ID, Group: &f.Group, Coords: &f.Coordinates})
+		page := &protobuf.AuditPage{EventName: proto.String("admin:audit:page"), RequestId: req.ReplyID(),
+			Offset: proto.Int32(int32(offset)), Total: proto.Int32(int32(total)),
+			Entries: make([]*protobuf.AuditEntry, len(entries))}
+		for i, e := range entries {
+			page.Entries[i] = &protobuf.AuditEntry{
+				Time: proto.Int64(e.Time.UnixNano() / int64(time.Millisecond)), ConnId: proto.String(e.ConnID),
+				Subject: proto.String(e.Subject), Role: proto.String(string(e.Role)), Event: proto.String(e.Event),
+				Payload: proto.String(e.Payload), Outcome: proto.String(string(e.Outcome)), Error: proto.String(e.Error)}
+		}
+		c.Emit(page)
 	}
 }
 
-func (h *EventHandler) onRequestFeatures(c *WSConnListener) func([]byte) {
-	return func(buf []byte) {
-		msg := &protobuf.Feature{}
-		proto.Unmarshal(buf, msg)
+// Map events
 
-		features, err := h.service.Features(msg.GetGroup())
+func (h *EventHandler) onAddFeature(c *WSConnListener) func(*Request, *protobuf.Feature) {
+	return func(req *Request, msg *protobuf.Feature) {
+		f, err := h.service.AddFeature(msg.GetGroup(), msg.GetId(), msg.GetCoords())
+		h.recordAudit(c, "admin:feature:add", msg.String(), err)
 		if err != nil {
-			log.Println("Error on sendFeatures:", err)
+			h.logger.Error("error to create feature", LogConnID, c.ID(), LogEvent, "admin:feature:add", "error", err)
+			c.EmitError("admin:feature:add", req.RequestID, ErrCodeInternal, err)
+			return
+		}
+		h.server.BroadcastToRoom(RoomAdmin, &protobuf.Feature{EventName: proto.String("admin:feature:added"), Id: &f.ID, Group: &f.Group, Coords: &f.Coordinates})
+	}
+}
 
+func (h *EventHandler) onRequestFeatures(c *WSConnListener) func(*Request, *protobuf.Feature) {
+	return func(req *Request, msg *protobuf.Feature) {
+		features, err := h.service.Features(msg.GetGroup())
+		if err != nil {
+			h.logger.Error("error to send features", LogConnID, c.ID(), LogEvent, "admin:feature:request-list", "error", err)
+			c.EmitError("admin:feature:request-list", req.RequestID, ErrCodeInternal, err)
+			return
 		}
 		event := "admin:feature:added"
 		for _, f := range features {
-			c.Emit(&protobuf.Feature{EventName: &event, Id: &f.ID, Group: &f.Group, Coords: &f.Coordinates})
+			c.Emit(&protobuf.Feature{EventName: &event, Id: &f.ID, Group: &f.Group, Coords: &f.Coordinates,
+				RequestId: req.ReplyID()})
 		}
+		c.Emit(listEndMessage(event, len(features), req.ReplyID()))
 	}
 }
 
 // Actions
 
+func (h *EventHandler) authenticate(c *WSConnListener, token string) {
+	claims, err := h.auth.Authenticate(token)
+	if err != nil {
+		h.rejectConnection(c, err)
+		return
+	}
+	h.onAuthenticated(c, claims)
+}
+
+func (h *EventHandler) rejectConnection(c *WSConnListener, err error) {
+	h.logger.Warn("connection rejected", LogConnID, c.ID(), LogEvent, "auth:token", "error", err)
+	c.EmitError("auth:token", "", ErrCodeUnauthenticated, err)
+	c.Close()
+}
+
+func (h *EventHandler) recordAudit(c *WSConnListener, event, payload string, err error) {
+	entry := NewAuditEntry(c, event, payload, err)
+	if err := h.audit.Record(entry); err != nil {
+		h.logger.Error("error to record audit entry", LogConnID, c.ID(), LogEvent, event, "outcome", entry.Outcome, "error", err)
+	}
+}
+
 func (h *EventHandler) newPlayer(c *WSConnListener) (player *model.Player, err error) {
-	player = &model.Player{ID: c.ID, Lat: 0, Lon: 0}
+	player = &model.Player{ID: c.ID(), Lat: 0, Lon: 0}
 	if err := h.service.Register(player); err != nil {
 		return nil, errors.New("could not register: " + err.Error())
 	}
-	c.Emit(&protobuf.Player{EventName: proto.String("player:registered"), Id: &player.ID, Lon: &player.Lon, Lat: &player.Lat})
-	h.server.Broadcast(&protobuf.Player{EventName: proto.String("remote-player:new"), Id: &player.ID, Lon: &player.Lon, Lat: &player.Lat})
+	c.Emit(playerMessage("player:registered", player))
+	h.interest.PlayerJoined(player)
 	return player, nil
 }
 
-func (h *EventHandler) sendPlayerList(c *WSConnListener) error {
+func (h *EventHandler) sendPlayerList(c *WSConnListener, requestID *string) error {
 	return withRecover(func() error {
 		players, err := h.service.Players()
 		if err != nil {
 			return errors.New("player:request-remotes event error: " + err.Error())
 		}
-		event := "remote-player:new"
+		if players, err = h.interest.Visible(c, players); err != nil {
+			return errors.New("player:request-remotes event error: " + err.Error())
+		}
+		ids := make([]string, 0, len(players))
+		for _, p := range players {
+			if p != nil {
+				ids = append(ids, p.ID)
+			}
+		}
+		profiles, err := h.profiles.GetMany(ids)
+		if err != nil {
+			h.logger.Warn("error to get player profiles", LogConnID, c.ID(), LogEvent, "player:request-remotes", "error", err)
+		}
+		count := 0
 		for _, p := range players {
 			if p == nil {
 				continue
 			}
-			err := c.Emit(&protobuf.Player{EventName: &event, Id: &p.ID, Lon: &p.Lon, Lat: &p.Lat})
-			if err != nil {
+			if profile, found := profiles[p.ID]; found {
+				p.Name, p.Color = profile.Name, profile.Color
+			}
+			msg := playerMessage("remote-player:new", p)
+			msg.RequestId = requestID
+			if err := c.Emit(msg); err != nil {
 				return errors.New("player:request-remotes event error: " + err.Error())
 			}
+			count++
 		}
-		return nil
+		return c.Emit(listEndMessage("remote-player:new", count, requestID))
 	})
 }
+
+// listEndMessage marks the end of multi message replies as <event>:end
+func listEndMessage(event string, count int, requestID *string) *protobuf.ListEnd {
+	return &protobuf.ListEnd{EventName: proto.String(event + ":end"), Count: proto.Int32(int32(count)),
+		RequestId: requestID}
+}
+
+func playerMessage(event string, p *model.Player) *protobuf.Player {
+	msg := &protobuf.Player{EventName: proto.String(event), Id: proto.String(p.ID),
+		Lon: proto.Float64(p.Lon), Lat: proto.Float64(p.Lat)}
+	if p.Name != "" {
+		msg.Name = proto.String(p.Name)
+	}
+	if p.Color != "" {
+		msg.Color = proto.String(p.Color)
+	}
+	return msg
+}
@@ -4,9 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	protocol "github.com/quorzz/redis-protocol"
@@ -17,29 +18,61 @@ import (
 type EventStream interface {
 	StreamNearByEvents(ctx context.Context, nearByKey, roamKey string, meters int, callback DetectionHandler) error
 	StreamIntersects(ctx context.Context, intersectKey, onKey, onKeyID string, callback DetectionHandler) error
+	// Status returns the state of the running streams
+	Status() []StreamStatus
+}
+
+// StreamStatus is the state of a fence stream
+type StreamStatus struct {
+	Query     string    `json:"query"`
+	Connected bool      `json:"connected"`
+	Since     time.Time `json:"since"`
+	LastEvent time.Time `json:"last_event,omitempty"`
+	Error     string    `json:"error,omitempty"`
 }
 
 // Tile38EventStream Tile38 implementation of EventStream
 type Tile38EventStream struct {
-	addr string
+	addr   string
+	logger *slog.Logger
+
+	streams map[*StreamStatus]bool
+	sync.Mutex
 }
 
 // NewEventStream creates a Tile38EventStream
-func NewEventStream(addr string) EventStream {
-	return &Tile38EventStream{addr}
+func NewEventStream(addr string, logger *slog.Logger) EventStream {
+	return &Tile38EventStream{addr: addr, logger: loggerOrDefault(logger), streams: make(map[*StreamStatus]bool)}
 }
 
 // StreamNearByEvents stream proximation events
 func (es *Tile38EventStream) StreamNearByEvents(ctx context.Context, nearByKey, roamKey string, meters int, callback DetectionHandler) error {
 	cmd := query{"NEARBY", nearByKey, "FENCE", "ROAM", roamKey, "*", meters}
-	return streamDetection(ctx, es.addr, cmd, callback)
+	return es.streamDetection(ctx, cmd, callback)
 }
 
 // StreamIntersects stream intersection events
 func (es *Tile38EventStream) StreamIntersects(ctx context.Context, intersectKey, onKey, onKeyID string, callback DetectionHandler) error {
 	cmd := query{"INTERSECTS", intersectKey, "FENCE", "DETECT", "inside,enter,exit", "GET", onKey, onKeyID}
 	callback = overrideNearByFeatIDWrapper(onKeyID, callback)
-	return streamDetection(ctx, es.addr, cmd, callback)
+	return es.streamDetection(ctx, cmd, callback)
+}
+
+// Status implements EventStream.Status
+func (es *Tile38EventStream) Status() []StreamStatus {
+	es.Lock()
+	defer es.Unlock()
+	status := make([]StreamStatus, 0, len(es.streams))
+	for s := range es.streams {
+		status = append(status, *s)
+	}
+	return status
+}
+
+func (es *Tile38EventStream) updateStatus(s *StreamStatus, fn func(s *StreamStatus)) {
+	es.Lock()
+	defer es.Unlock()
+	fn(s)
 }
 
 func overrideNearByFeatIDWrapper(nearByFeatID string, handler DetectionHandler) DetectionHandler {
@@ -69,6 +102,8 @@ type Detection struct {
 	NearByFeatID string          `json:"near_by_feat_id"`
 	NearByMeters float64         `json:"near_by_meters"`
 	Intersects   IntersectsEvent `json:"intersects"`
+	// Time is when tile38 detected the event
+	Time time.Time `json:"time"`
 }
 
 func (d Detection) String() string {
@@ -86,14 +121,18 @@ func (err DetectionError) Error() string {
 	return string("DetectionError: " + err)
 }
 
-func streamDetection(ctx context.Context, addr string, q query, callback DetectionHandler) error {
+func (es *Tile38EventStream) streamDetection(ctx context.Context, q query, callback DetectionHandler) error {
 	interval := 300 * time.Microsecond
-	conn, err := listenTo(addr, q)
+	conn, err := es.listenTo(q)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
+	status := &StreamStatus{Query: strings.TrimSpace(q.String()), Connected: true, Since: time.Now()}
+	es.updateStatus(status, func(s *StreamStatus) { es.streams[s] = true })
+	defer es.updateStatus(status, func(s *StreamStatus) { delete(es.streams, s) })
+
 	buf, n := make([]byte, 4096), 0
 	t := time.NewTicker(interval)
 	defer t.Stop()
@@ -101,22 +140,33 @@ func streamDetection(ctx context.Context, addr string, q query, callback Detecti
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("eventscream:query:stop:%s", q.String())
+			es.logger.Info("stream stopped", "query", status.Query)
 			return nil
 		case <-t.C:
 			conn.SetReadDeadline(time.Now().Add(interval))
 			if n, err = conn.Read(buf); err != nil {
+				if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+					es.updateStatus(status, func(s *StreamStatus) {
+						if s.Connected {
+							es.logger.Warn("stream disconnected", "query", s.Query, "error", err)
+							s.Connected, s.Since, s.Error = false, time.Now(), err.Error()
+						}
+					})
+				}
 				continue
 			}
+			es.updateStatus(status, func(s *StreamStatus) { s.LastEvent = time.Now() })
 			for _, line := range strings.Split(string(buf[:n]), "\n") {
 				if len(line) == 0 || line[0] != '{' {
 					continue
 				}
 				detected, err := handleDetection(line)
 				if err != nil {
-					log.Println("Failed to handleDetection:", err)
+					es.logger.Warn("invalid detection", "query", status.Query, "error", err)
 					continue
 				}
+				es.logger.Debug("detection", "query", status.Query, LogPlayerID, detected.FeatID,
+					LogGameID, detected.NearByFeatID, LogEvent, string(detected.Intersects))
 				err = withRecover(func() error {
 					return callback(detected)
 				})
@@ -142,17 +192,18 @@ func handleDetection(msg string) (*Detection, error) {
 	} else if detect := gjson.Get(msg, "detect").String(); detect != "" {
 		intersects = IntersectsEvent(detect)
 	}
-	return &Detection{featID, lat, lon, nearByFeatID, nearByMeters, intersects}, nil
+	detectedAt := gjson.Get(msg, "time").Time()
+	return &Detection{featID, lat, lon, nearByFeatID, nearByMeters, intersects, detectedAt}, nil
 }
 
-func listenTo(addr string, q query) (net.Conn, error) {
-	conn, err := net.Dial("tcp", addr)
+func (es *Tile38EventStream) listenTo(q query) (net.Conn, error) {
+	conn, err := net.Dial("tcp", es.addr)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Println("REDIS DEBUG:", q)
-	if _, err = fmt.Fprintf(conn, q.cmd()); err != nil {
+	es.logger.Debug("tile38 command", "cmd", strings.TrimSpace(q.String()))
+	if _, err = fmt.Fprint(conn, q.cmd()); err != nil {
 		return nil, err
 	}
 	buf := make([]byte, 4096)
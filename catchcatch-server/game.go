@@ -4,9 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
 	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/perenecabuto/CatchCatch/catchcatch-server/model"
@@ -25,7 +26,7 @@ type GameEvents interface {
 	OnTargetWin(p GamePlayer)
 	OnGameFinish(r GameRank)
 	OnPlayerLoose(g *Game, p GamePlayer)
-	OnTargetReached(p GamePlayer, dist float64)
+	OnTargetReached(g *Game, p GamePlayer, dist float64)
 	OnPlayerNearToTarget(p GamePlayer, dist float64)
 }
 
@@ -41,6 +42,21 @@ const (
 	GameRoleHunter GameRole = "hunter"
 )
 
+// GameRules are the settings of every game
+type GameRules struct {
+	// MinPlayers is the number of players in a geofence to start a game
+	MinPlayers int
+	Duration   time.Duration
+	// TargetReachedDistance is how close in meters a hunter must get to catch the target
+	TargetReachedDistance float64
+	// TargetNearDistance is how close in meters a hunter is warned to be near the target
+	TargetNearDistance float64
+}
+
+// DefaultGameRules are the rules used by the server
+var DefaultGameRules = GameRules{MinPlayers: MinPlayersPerGame, Duration: DefaultGameDuration,
+	TargetReachedDistance: 20, TargetNearDistance: 100}
+
 // GamePlayer wraps player and its role in the game
 type GamePlayer struct {
 	model.Player
@@ -49,20 +65,31 @@ type GamePlayer struct {
 
 // Game controls rounds and players
 type Game struct {
-	ID       string
-	players  map[string]*GamePlayer
-	duration time.Duration
-	started  bool
-	target   *GamePlayer
-	events   GameEvents
-
-	stop context.CancelFunc
+	ID        string
+	players   map[string]*GamePlayer
+	rules     GameRules
+	started   bool
+	startedAt time.Time
+	target    *GamePlayer
+	events    GameEvents
+	logger    *slog.Logger
+
+	// playerCount and running mirror players and started for readers out of the game goroutines
+	playerCount int32
+	running     int32
+	// aborted games stop without finishing, their players get no rank
+	aborted int32
+
+	// stop holds the context.CancelFunc of the running game, it is set by Start
+	stop atomic.Value
 }
 
-// NewGame create a game with duration
-func NewGame(id string, duration time.Duration, events GameEvents) *Game {
-	return &Game{ID: id, events: events, duration: duration, started: false,
-		players: make(map[string]*GamePlayer), stop: func() {}}
+// NewGame create a game with rules
+func NewGame(id string, rules GameRules, events GameEvents, logger *slog.Logger) *Game {
+	g := &Game{ID: id, events: events, rules: rules, started: false,
+		players: make(map[string]*GamePlayer), logger: loggerOrDefault(logger).With(LogGameID, id)}
+	g.stop.Store(context.CancelFunc(func() {}))
+	return g
 }
 
 func (g Game) String() string {
@@ -77,25 +104,36 @@ func (g *Game) Start(ctx context.Context) error {
 		return ErrAlreadyStarted
 	}
 
-	log.Println("game:", g.ID, ":start!!!!!!")
+	g.logger.Info("game started", "players", len(g.players))
 	g.setPlayersRoles()
 
-	g.started = true
+	gameCtx, stop := context.WithTimeout(ctx, g.rules.Duration)
+	g.stop.Store(stop)
+	g.started, g.startedAt = true, time.Now()
+	g.updateState()
 
-	go g.handleGameFinishEvent(ctx)
+	go g.handleGameFinishEvent(gameCtx)
 	return nil
 }
 
-func (g *Game) handleGameFinishEvent(ctx context.Context) {
-	var gameCtx context.Context
-	gameCtx, g.stop = context.WithTimeout(ctx, g.duration)
+// Stop finishes the running game, it is safe to call from any goroutine
+func (g *Game) Stop() {
+	g.stop.Load().(context.CancelFunc)()
+}
+
+func (g *Game) handleGameFinishEvent(gameCtx context.Context) {
 	<-gameCtx.Done()
 	g.started = false
+	g.updateState()
+	if atomic.LoadInt32(&g.aborted) == 1 {
+		g.logger.Info("game aborted", "players", len(g.players))
+		return
+	}
 	g.finish(gameCtx)
 }
 
 func (g *Game) finish(ctx context.Context) {
-	log.Println("game:", g.ID, ":stop!!!!!!!")
+	g.logger.Info("game stopped", "players", len(g.players))
 	g.started = false
 
 	_, stillInTheGame := g.players[g.target.ID]
@@ -106,6 +144,31 @@ func (g *Game) finish(ctx context.Context) {
 	rank := NewGameRank(g.ID).ByPlayersDistanceToTarget(g.players, *g.target)
 	g.events.OnGameFinish(rank)
 	g.players = make(map[string]*GamePlayer)
+	g.updateState()
+}
+
+func (g *Game) updateState() {
+	running := int32(0)
+	if g.started {
+		running = 1
+	}
+	atomic.StoreInt32(&g.playerCount, int32(len(g.players)))
+	atomic.StoreInt32(&g.running, running)
+}
+
+// Abort makes the game stop without finishing when its context is done
+func (g *Game) Abort() {
+	atomic.StoreInt32(&g.aborted, 1)
+}
+
+// PlayerCount returns the number of players, it is safe to call from any goroutine
+func (g *Game) PlayerCount() int {
+	return int(atomic.LoadInt32(&g.playerCount))
+}
+
+// Running tells if the game started, it is safe to call from any goroutine
+func (g *Game) Running() bool {
+	return atomic.LoadInt32(&g.running) == 1
 }
 
 // GameInfo ...
@@ -172,8 +235,9 @@ The rule is:
 func (g *Game) SetPlayer(id string, lon, lat float64) error {
 	if !g.started {
 		if _, exists := g.players[id]; !exists {
-			log.Printf("game:%s:detect=enter:%s\n", g.ID, id)
+			g.logger.Info("player entered", LogPlayerID, id, LogEvent, "enter")
 			g.players[id] = &GamePlayer{model.Player{ID: id, Lon: lon, Lat: lat}, GameRoleUndefined}
+			g.updateState()
 		}
 		return nil
 	}
@@ -196,13 +260,14 @@ func (g *Game) notifyToTheHunterTheDistanceToTheTarget(p *GamePlayer) error {
 	}
 	dist := p.DistTo(target.Player)
 
-	if dist <= 20 {
-		log.Printf("game:%s:detect=winner:%s:dist:%f\n", g.ID, p.ID, dist)
+	if dist <= g.rules.TargetReachedDistance {
+		g.logger.Info("target reached", LogPlayerID, p.ID, LogEvent, "winner", "dist", dist)
 		delete(g.players, target.ID)
+		g.updateState()
 		g.events.OnPlayerLoose(g, *target)
-		g.events.OnTargetReached(*p, dist)
-		g.stop()
-	} else if dist <= 100 {
+		g.events.OnTargetReached(g, *p, dist)
+		g.Stop()
+	} else if dist <= g.rules.TargetNearDistance {
 		g.events.OnPlayerNearToTarget(*p, dist)
 	}
 	return nil
@@ -221,24 +286,25 @@ func (g *Game) RemovePlayer(id string) {
 		return
 	}
 	delete(g.players, id)
+	defer g.updateState()
 	if !g.started {
-		log.Println("game:"+g.ID+":detect=exit:", gamePlayer)
+		g.logger.Info("player left", LogPlayerID, id, LogEvent, "exit")
 		return
 	}
 
 	if len(g.players) == 1 {
-		log.Println("game:"+g.ID+":detect=last-one:", gamePlayer)
-		g.stop()
+		g.logger.Info("only one player left", LogPlayerID, id, LogEvent, "last-one")
+		g.Stop()
 	} else if id == g.target.ID {
-		log.Println("game:"+g.ID+":detect=target-loose:", gamePlayer)
+		g.logger.Info("target left", LogPlayerID, id, LogEvent, "target-loose")
 		go g.events.OnPlayerLoose(g, *gamePlayer)
-		g.stop()
+		g.Stop()
 	} else if len(g.players) == 0 {
-		log.Println("game:"+g.ID+":detect=no-players:", gamePlayer)
+		g.logger.Info("no players left", LogPlayerID, id, LogEvent, "no-players")
 		g.players[id] = gamePlayer
-		g.stop()
+		g.Stop()
 	} else {
-		log.Println("game:"+g.ID+":detect=loose:", gamePlayer)
+		g.logger.Info("player lost", LogPlayerID, id, LogEvent, "loose")
 		go g.events.OnPlayerLoose(g, *gamePlayer)
 	}
 	return
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// GameCounts are the totals of game events of a geofence
+type GameCounts struct {
+	Created  int64 `json:"created"`
+	Started  int64 `json:"started"`
+	Finished int64 `json:"finished"`
+	Catches  int64 `json:"catches"`
+}
+
+type detectionLatency struct {
+	count int64
+	total time.Duration
+	max   time.Duration
+}
+
+// GameMetrics reports how games are played, tagged by geofence
+// game events are notified when they happen, detection latency is aggregated and reported by Run
+type GameMetrics struct {
+	sink    MetricsSink
+	host    string
+	counts  map[string]*GameCounts
+	latency map[string]*detectionLatency
+	logger  *slog.Logger
+	sync.Mutex
+}
+
+// NewGameMetrics creates a GameMetrics
+func NewGameMetrics(sink MetricsSink, host string, logger *slog.Logger) *GameMetrics {
+	return &GameMetrics{sink: sink, host: host, logger: loggerOrDefault(logger),
+		counts: make(map[string]*GameCounts), latency: make(map[string]*detectionLatency)}
+}
+
+// GameCreated is called when a geofence starts to be watched as a game
+func (m *GameMetrics) GameCreated(geofence string) {
+	counts := m.count(geofence, func(c *GameCounts) { c.Created++ })
+	m.notify("games", geofence, counts.values())
+}
+
+// GameStarted is called when a game starts with its players
+func (m *GameMetrics) GameStarted(geofence string, players int) {
+	counts := m.count(geofence, func(c *GameCounts) { c.Started++ })
+	m.notify("games", geofence, counts.values())
+	m.notify("game_players", geofence, Values{"players": players})
+}
+
+// GameFinished is called when a started game finishes
+func (m *GameMetrics) GameFinished(geofence string, duration time.Duration, players int) {
+	counts := m.count(geofence, func(c *GameCounts) { c.Finished++ })
+	m.notify("games", geofence, counts.values())
+	m.notify("game_duration", geofence, Values{"seconds": duration.Seconds(), "players": players})
+}
+
+// TargetCaught is called when a hunter reaches the target
+func (m *GameMetrics) TargetCaught(geofence string, timeToCatch time.Duration) {
+	counts := m.count(geofence, func(c *GameCounts) { c.Catches++ })
+	m.notify("games", geofence, counts.values())
+	m.notify("game_catch", geofence, Values{"time_to_catch_seconds": timeToCatch.Seconds()})
+}
+
+// Detected records the time between the detection in tile38 and its handling
+func (m *GameMetrics) Detected(geofence string, latency time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	l, exists := m.latency[geofence]
+	if !exists {
+		l = &detectionLatency{}
+		m.latency[geofence] = l
+	}
+	l.count++
+	l.total += latency
+	if latency > l.max {
+		l.max = latency
+	}
+}
+
+// Counts returns the game counts of a geofence
+func (m *GameMetrics) Counts(geofence string) GameCounts {
+	return m.count(geofence, func(*GameCounts) {})
+}
+
+// Run reports the detection latency of each geofence every interval
+func (m *GameMetrics) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.reportLatency()
+		}
+	}
+}
+
+func (m *GameMetrics) reportLatency() {
+	m.Lock()
+	latency := m.latency
+	m.latency = make(map[string]*detectionLatency)
+	m.Unlock()
+	for geofence, l := range latency {
+		avg := l.total / time.Duration(l.count)
+		m.notify("detection_latency", geofence, Values{"count": l.count,
+			"avg_ms": avg.Seconds() * 1000, "max_ms": l.max.Seconds() * 1000})
+	}
+}
+
+func (m *GameMetrics) count(geofence string, fn func(*GameCounts)) GameCounts {
+	m.Lock()
+	defer m.Unlock()
+	c, exists := m.counts[geofence]
+	if !exists {
+		c = &GameCounts{}
+		m.counts[geofence] = c
+	}
+	fn(c)
+	return *c
+}
+
+func (c GameCounts) values() Values {
+	return Values{"created": Counter(c.Created), "started": Counter(c.Started),
+		"finished": Counter(c.Finished), "catches": Counter(c.Catches)}
+}
+
+func (m *GameMetrics) notify(measurement, geofence string, values Values) {
+	if err := m.sink.Notify(measurement, Tags{"host": m.host, "geofence": geofence}, values); err != nil {
+		m.logger.Error("error to notify metrics", "measurement", measurement, "geofence", geofence, "error", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordedMetric struct {
+	measurement string
+	tags        Tags
+	values      Values
+}
+
+type recordingSink struct {
+	NoopMetrics
+	metrics []recordedMetric
+	sync.Mutex
+}
+
+func (s *recordingSink) Notify(measurement string, tags Tags, values Values) error {
+	s.Lock()
+	defer s.Unlock()
+	s.metrics = append(s.metrics, recordedMetric{measurement, tags, values})
+	return nil
+}
+
+func (s *recordingSink) last(measurement string) *recordedMetric {
+	s.Lock()
+	defer s.Unlock()
+	for i := len(s.metrics) - 1; i >= 0; i-- {
+		if s.metrics[i].measurement == measurement {
+			return &s.metrics[i]
+		}
+	}
+	return nil
+}
+
+func TestGameMetricsTagsEventsByGeofence(t *testing.T) {
+	sink := &recordingSink{}
+	m := NewGameMetrics(sink, "node-a", nil)
+	m.GameCreated("g1")
+	m.GameStarted("g1", 4)
+	m.TargetCaught("g1", 20*time.Second)
+	m.GameFinished("g1", 30*time.Second, 3)
+	m.GameCreated("g2")
+
+	if counts := m.Counts("g1"); counts != (GameCounts{Created: 1, Started: 1, Finished: 1, Catches: 1}) {
+		t.Fatal("unexpected g1 counts:", counts)
+	}
+	if counts := m.Counts("g2"); counts != (GameCounts{Created: 1}) {
+		t.Fatal("unexpected g2 counts:", counts)
+	}
+	players := sink.last("game_players")
+	if players == nil || players.tags["geofence"] != "g1" || players.tags["host"] != "node-a" || players.values["players"] != 4 {
+		t.Fatal("unexpected game_players metric:", players)
+	}
+	if catch := sink.last("game_catch"); catch == nil || catch.values["time_to_catch_seconds"] != 20.0 {
+		t.Fatal("unexpected game_catch metric:", catch)
+	}
+	if duration := sink.last("game_duration"); duration == nil || duration.values["seconds"] != 30.0 {
+		t.Fatal("unexpected game_duration metric:", duration)
+	}
+}
+
+func TestGameMetricsAggregatesDetectionLatency(t *testing.T) {
+	sink := &recordingSink{}
+	m := NewGameMetrics(sink, "node-a", nil)
+	m.Detected("g1", 10*time.Millisecond)
+	m.Detected("g1", 30*time.Millisecond)
+	m.reportLatency()
+
+	latency := sink.last("detection_latency")
+	if latency == nil || latency.tags["geofence"] != "g1" || latency.values["count"] != int64(2) ||
+		latency.values["avg_ms"] != 20.0 || latency.values["max_ms"] != 30.0 {
+		t.Fatal("unexpected detection_latency metric:", latency)
+	}
+	m.reportLatency()
+	if len(sink.metrics) != 1 {
+		t.Fatal("expected latency to be reset after each report, got:", sink.metrics)
+	}
+}
+
+func TestHandleDetectionParsesTile38Time(t *testing.T) {
+	d, err := handleDetection(`{"command":"set","detect":"enter","id":"p1","time":"2018-01-02T15:04:05.5Z","object":{"type":"Point","coordinates":[-46.6,-23.5]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Date(2018, 1, 2, 15, 4, 5, 5e8, time.UTC); !d.Time.Equal(expected) {
+		t.Fatal("expected detection time", expected, "got:", d.Time)
+	}
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"context"
-	"log"
-	"runtime/debug"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/golang/protobuf/proto"
@@ -23,26 +23,60 @@ type GameContext struct {
 	cancel context.CancelFunc
 }
 
+// GameLease configures the ownership of games between server replicas
+// each game is watched only by the replica holding its lease
+type GameLease struct {
+	Locks LockBackend
+	// Owner is the name of this replica
+	Owner string
+	// TTL is how long the lease lasts when the owner stops renewing it
+	TTL time.Duration
+}
+
 // GameWatcher is made to start/stop games by player presence
 // and notify players events to each game by geo position
 type GameWatcher struct {
-	games  map[string]*GameContext
-	wss    *WSServer
-	stream EventStream
-	Clear  context.CancelFunc
+	games    map[string]*GameContext
+	wss      *WSServer
+	stream   EventStream
+	profiles PlayerProfileStore
+	lease    GameLease
+	rules    GameRules
+	metrics  *GameMetrics
+	logger   *slog.Logger
+	closed   bool
+	// leasedElsewhere are the games owned by other replicas until their lease can expire
+	leasedElsewhere map[string]time.Time
+	Clear           context.CancelFunc
+	sync.Mutex
 }
 
 // NewGameWatcher builds GameWatecher
-func NewGameWatcher(stream EventStream, wss *WSServer) *GameWatcher {
-	return &GameWatcher{make(map[string]*GameContext), wss, stream, func() {}}
+// game metrics are discarded when metrics is nil
+func NewGameWatcher(stream EventStream, wss *WSServer, profiles PlayerProfileStore, lease GameLease,
+	rules GameRules, metrics *GameMetrics, logger *slog.Logger) *GameWatcher {
+	if metrics == nil {
+		metrics = NewGameMetrics(NoopMetrics{}, lease.Owner, logger)
+	}
+	return &GameWatcher{games: make(map[string]*GameContext), wss: wss, stream: stream, profiles: profiles,
+		lease: lease, rules: rules, metrics: metrics, logger: loggerOrDefault(logger),
+		leasedElsewhere: make(map[string]time.Time), Clear: func() {}}
+}
+
+func gameLeaseKey(gameID string) string {
+	return "catchcatch:lease:game:" + gameID
 }
 
 // observeGamePlayers events
 // TODO: monitor game player watches
 func (gw *GameWatcher) observeGamePlayers(ctx context.Context, g *Game) error {
 	return gw.stream.StreamIntersects(ctx, "player", "geofences", g.ID, func(d *Detection) error {
+		if !d.Time.IsZero() {
+			gw.metrics.Detected(g.ID, time.Since(d.Time))
+		}
 		switch d.Intersects {
 		case Enter:
+			gw.wss.Join(GeofenceRoom(g.ID), d.FeatID)
 			if err := g.SetPlayer(d.FeatID, d.Lat, d.Lon); err != nil {
 				return err
 			}
@@ -51,6 +85,7 @@ func (gw *GameWatcher) observeGamePlayers(ctx context.Context, g *Game) error {
 				return err
 			}
 		case Exit:
+			gw.wss.Leave(GeofenceRoom(g.ID), d.FeatID)
 			g.RemovePlayer(d.FeatID)
 		}
 		return nil
@@ -67,14 +102,14 @@ func (gw *GameWatcher) WatchGames(ctx context.Context) error {
 
 	return gw.stream.StreamNearByEvents(watcherCtx, "player", "geofences", 0, func(d *Detection) error {
 		gameID := d.NearByFeatID
-		if gameID == "" {
+		if gameID == "" || gw.isLeasedElsewhere(gameID) {
 			return nil
 		}
 
 		go func() {
 			if err := gw.watchGame(watcherCtx, gameID); err != nil {
-				log.Println(err)
-				gw.games[gameID].cancel()
+				gw.logger.Error("error to watch game", LogGameID, gameID, "error", err)
+				gw.stopGame(gameID)
 			}
 		}()
 		return nil
@@ -96,17 +131,51 @@ func (gw *GameWatcher) WatchGamesForever(ctx context.Context) error {
 	}
 }
 
+// watchGame starts the game when this replica gets its lease
+// other replicas take the game over when the lease expires, the game restarts with them
 func (gw *GameWatcher) watchGame(ctx context.Context, gameID string) error {
-	_, exists := gw.games[gameID]
-	if exists {
+	if gw.watchingOrClosed(gameID) {
+		return nil
+	}
+	// the lease backend may be remote so it is never called holding the watcher lock
+	owned, err := gw.lease.Locks.Acquire(gameLeaseKey(gameID), gw.lease.Owner, gw.lease.TTL)
+	if err != nil {
+		return err
+	} else if !owned {
+		gw.Lock()
+		gw.leasedElsewhere[gameID] = time.Now().Add(gw.lease.TTL)
+		gw.Unlock()
+		return nil
+	}
+	gw.Lock()
+	if _, exists := gw.games[gameID]; exists || gw.closed {
+		// another watch of this owner took the game meanwhile, it keeps the lease
+		gw.Unlock()
+		if !exists {
+			gw.lease.Locks.Release(gameLeaseKey(gameID), gw.lease.Owner)
+		}
 		return nil
 	}
-	g := NewGame(gameID, DefaultGameDuration, gw)
+	gw.logger.Info("game lease acquired", LogGameID, gameID, "owner", gw.lease.Owner)
+	g := NewGame(gameID, gw.rules, gw, gw.logger)
 	gCtx, cancel := context.WithCancel(ctx)
-	gw.games[gameID] = &GameContext{game: g, cancel: func() {
-		delete(gw.games, gameID)
+	watched := &GameContext{game: g}
+	watched.cancel = func() {
+		gw.Lock()
+		owned := gw.games[gameID] == watched
+		if owned {
+			delete(gw.games, gameID)
+		}
+		gw.Unlock()
+		if owned {
+			gw.lease.Locks.Release(gameLeaseKey(gameID), gw.lease.Owner)
+		}
 		cancel()
-	}}
+	}
+	gw.games[gameID] = watched
+	gw.Unlock()
+	gw.metrics.GameCreated(gameID)
+	go gw.keepLease(gCtx, gameID, watched)
 
 	errChan := make(chan error)
 	go func() {
@@ -118,12 +187,91 @@ func (gw *GameWatcher) watchGame(ctx context.Context, gameID string) error {
 		}
 	}()
 	if err := <-errChan; err != nil {
-		gw.games[gameID].cancel()
+		gw.stopGame(gameID)
 		return err
 	}
 	return nil
 }
 
+func (gw *GameWatcher) watchingOrClosed(gameID string) bool {
+	gw.Lock()
+	defer gw.Unlock()
+	_, exists := gw.games[gameID]
+	return exists || gw.closed
+}
+
+// isLeasedElsewhere tells if another replica may still hold the game lease
+func (gw *GameWatcher) isLeasedElsewhere(gameID string) bool {
+	gw.Lock()
+	defer gw.Unlock()
+	until, exists := gw.leasedElsewhere[gameID]
+	if exists && time.Now().After(until) {
+		delete(gw.leasedElsewhere, gameID)
+		return false
+	}
+	return exists
+}
+
+// keepLease renews the game lease until the game stops
+// renew errors are retried until the lease expires, then the game is lost so only one replica runs it:
+// it is aborted without a rank, its players receive game:loose
+// and the game restarts from scratch on the replica which takes the lease
+func (gw *GameWatcher) keepLease(ctx context.Context, gameID string, watched *GameContext) {
+	key := gameLeaseKey(gameID)
+	defer watched.cancel()
+	ticker := time.NewTicker(gw.lease.TTL / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			owned, err := gw.lease.Locks.Acquire(key, gw.lease.Owner, gw.lease.TTL)
+			if err == nil && owned {
+				renewed = time.Now()
+			} else if err != nil && time.Since(renewed) < gw.lease.TTL {
+				gw.logger.Warn("error to renew game lease", LogGameID, gameID, "owner", gw.lease.Owner, "error", err)
+			} else {
+				gw.logger.Warn("game lease lost", LogGameID, gameID, "owner", gw.lease.Owner, "error", err)
+				watched.game.Abort()
+				room := GameRoom(gameID)
+				gw.wss.BroadcastToRoom(room, &protobuf.Simple{EventName: proto.String("game:loose"), Id: &gameID})
+				gw.wss.CloseRoom(room)
+				return
+			}
+		}
+	}
+}
+
+// GameState is a snapshot of a watched game
+type GameState struct {
+	ID      string `json:"id"`
+	Players int    `json:"players"`
+	Started bool   `json:"started"`
+}
+
+// Games returns the games watched by this replica
+func (gw *GameWatcher) Games() []GameState {
+	gw.Lock()
+	defer gw.Unlock()
+	games := make([]GameState, 0, len(gw.games))
+	for id, watched := range gw.games {
+		games = append(games, GameState{ID: id, Players: watched.game.PlayerCount(), Started: watched.game.Running()})
+	}
+	return games
+}
+
+// stopGame cancels the game when this replica is watching it
+func (gw *GameWatcher) stopGame(gameID string) {
+	gw.Lock()
+	gCtx, exists := gw.games[gameID]
+	gw.Unlock()
+	if exists {
+		gCtx.cancel()
+	}
+}
+
 func (gw *GameWatcher) startGameWhenReady(ctx context.Context, g *Game) error {
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
@@ -132,10 +280,16 @@ func (gw *GameWatcher) startGameWhenReady(ctx context.Context, g *Game) error {
 		case <-ctx.Done():
 			return nil
 		case <-ticker.C:
-			ready := len(g.players) >= MinPlayersPerGame
-			if ready {
-				return g.Start(ctx)
+			ready := g.PlayerCount() >= gw.rules.MinPlayers
+			if !ready {
+				continue
 			}
+			players := g.PlayerCount()
+			if err := g.Start(ctx); err != nil {
+				return err
+			}
+			gw.metrics.GameStarted(g.ID, players)
+			return nil
 		}
 	}
 }
@@ -157,14 +311,16 @@ func (gw *GameWatcher) WatchCheckpoints(ctx context.Context) {
 			NearByMeters: &d.NearByMeters,
 		}
 		if err := gw.wss.Emit(d.FeatID, payload); err != nil {
-			log.Println("Error to notify player", d.FeatID, err)
+			gw.logger.Info("error to notify checkpoint", LogPlayerID, d.FeatID, LogEvent, "checkpoint:detected", "error", err)
 		}
 		payload.EventName = proto.String("admin:feature:checkpoint")
-		return gw.wss.Broadcast(payload)
+		if err := gw.wss.BroadcastToRoom(RoomAdmin, payload); err != nil {
+			gw.logger.Info("error to broadcast checkpoint", LogPlayerID, d.FeatID, LogEvent, "admin:feature:checkpoint", "error", err)
+		}
+		return nil
 	})
 	if err != nil {
-		log.Println("Error to stream geofence:event", err)
-		debug.PrintStack()
+		gw.logger.Error("error to stream checkpoints", "error", err)
 	}
 }
 
@@ -172,6 +328,9 @@ func (gw *GameWatcher) WatchCheckpoints(ctx context.Context) {
 
 // OnGameStarted implements GameEvent.OnGameStarted
 func (gw *GameWatcher) OnGameStarted(g *Game, p GamePlayer) {
+	if err := gw.wss.Join(GameRoom(g.ID), p.ID); err != nil {
+		gw.logger.Warn("error to join game room", LogGameID, g.ID, LogPlayerID, p.ID, "error", err)
+	}
 	gw.wss.Emit(p.ID, &protobuf.GameInfo{
 		EventName: proto.String("game:started"),
 		Id:        &g.ID,
@@ -185,27 +344,40 @@ func (gw *GameWatcher) OnTargetWin(p GamePlayer) {
 
 // OnGameFinish implements GameEvent.OnGameFinish
 func (gw *GameWatcher) OnGameFinish(rank GameRank) {
-	log.Printf("gamewatcher:stop:game:%s", rank.Game)
-	gw.games[rank.Game].cancel()
+	gw.logger.Info("game finished", LogGameID, rank.Game, "players", len(rank.PlayerIDs))
+	gw.Lock()
+	watched, exists := gw.games[rank.Game]
+	gw.Unlock()
+	if exists {
+		gw.metrics.GameFinished(rank.Game, time.Since(watched.game.startedAt), len(rank.PlayerIDs))
+	}
+	gw.stopGame(rank.Game)
 
 	playersRank := make([]*protobuf.PlayerRank, len(rank.PlayerRank))
 	for i, pr := range rank.PlayerRank {
-		playersRank[i] = &protobuf.PlayerRank{Player: &pr.Player, Points: proto.Int32(int32(pr.Points))}
+		playersRank[i] = &protobuf.PlayerRank{Player: proto.String(pr.Player), Points: proto.Int32(int32(pr.Points))}
+		if profile, err := gw.profiles.Get(pr.Player); err == nil {
+			playersRank[i].Name, playersRank[i].Color = proto.String(profile.Name), proto.String(profile.Color)
+		}
 	}
+	// the rank goes by player id so it reaches the players held by other nodes
 	gw.wss.BroadcastTo(rank.PlayerIDs, &protobuf.GameRank{
 		EventName: proto.String("game:finish"),
 		Id:        &rank.Game,
 		Game:      &rank.Game, PlayersRank: playersRank,
 	})
+	gw.wss.CloseRoom(GameRoom(rank.Game))
 }
 
 // OnPlayerLoose implements GameEvent.OnPlayerLoose
 func (gw *GameWatcher) OnPlayerLoose(g *Game, p GamePlayer) {
+	gw.wss.Leave(GameRoom(g.ID), p.ID)
 	gw.wss.Emit(p.ID, &protobuf.Simple{EventName: proto.String("game:loose"), Id: &g.ID})
 }
 
 // OnTargetReached implements GameEvent.OnTargetReached
-func (gw *GameWatcher) OnTargetReached(p GamePlayer, dist float64) {
+func (gw *GameWatcher) OnTargetReached(g *Game, p GamePlayer, dist float64) {
+	gw.metrics.TargetCaught(g.ID, time.Since(g.startedAt))
 	gw.wss.Emit(p.ID, &protobuf.Distance{EventName: proto.String("game:target:reached"),
 		Dist: &dist})
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ErrNoStreams happens when no fence stream is running
+var ErrNoStreams = errors.New("no fence stream running")
+
+// ErrStreamDisconnected happens when a fence stream lost its connection
+var ErrStreamDisconnected = errors.New("fence stream disconnected")
+
+// Pinger is a dependency which can tell if it is reachable
+type Pinger interface {
+	Ping() error
+}
+
+// HealthHandler serves the health, readiness and diagnostics endpoints
+type HealthHandler struct {
+	server  *WSServer
+	service PlayerLocationService
+	stream  EventStream
+	games   *GameWatcher
+	metrics Pinger
+	auth    Authenticator
+	started time.Time
+}
+
+// NewHealthHandler creates a HealthHandler
+func NewHealthHandler(server *WSServer, service PlayerLocationService, stream EventStream,
+	games *GameWatcher, metrics Pinger, auth Authenticator) *HealthHandler {
+	return &HealthHandler{server, service, stream, games, metrics, auth, time.Now()}
+}
+
+// Register the endpoints on mux
+func (h *HealthHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", h.Healthz)
+	mux.HandleFunc("/readyz", h.Readyz)
+	mux.HandleFunc("/debug/state", h.DebugState)
+}
+
+// Healthz tells the process is up
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz tells if the server dependencies are reachable, it fails with 503 when any is not
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]error{
+		"location_service": h.service.Ping(),
+		"fence_streams":    checkStreams(h.stream.Status()),
+		"metrics":          h.metrics.Ping(),
+	}
+	if h.server.Draining() {
+		checks["server"] = ErrServerShuttingDown
+	}
+	ready, result := true, make(map[string]string, len(checks))
+	for name, err := range checks {
+		result[name] = "ok"
+		if err != nil {
+			ready, result[name] = false, err.Error()
+		}
+	}
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, map[string]interface{}{"ready": ready, "checks": result})
+}
+
+func checkStreams(streams []StreamStatus) error {
+	if len(streams) == 0 {
+		return ErrNoStreams
+	}
+	for _, s := range streams {
+		if !s.Connected {
+			return ErrStreamDisconnected
+		}
+	}
+	return nil
+}
+
+// DebugState returns the connections, games and streams of this server
+// admin or observer tokens are required when authentication is enabled
+func (h *HealthHandler) DebugState(w http.ResponseWriter, r *http.Request) {
+	if !authorizeRole(w, r, h.auth, RoleAdmin, RoleObserver) {
+		return
+	}
+
+	roles := make(map[ConnRole]int)
+	total := 0
+	h.server.ForEach(func(c *WSConnListener) {
+		roles[c.Role]++
+		total++
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"node":        h.server.Node(),
+		"uptime":      time.Since(h.started).String(),
+		"draining":    h.server.Draining(),
+		"connections": map[string]interface{}{"total": total, "by_role": roles},
+		"send_queue":  h.server.SendQueueStats(),
+		"games":       h.games.Games(),
+		"streams":     h.stream.Status(),
+	})
+}
+
+// authorizeRole checks the request token has one of roles when authentication is enabled
+// it writes the error response when it is not authorized
+func authorizeRole(w http.ResponseWriter, r *http.Request, auth Authenticator, roles ...ConnRole) bool {
+	if !auth.Required() {
+		return true
+	}
+	claims, err := auth.Authenticate(authTokenFromRequest(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return false
+	}
+	role, _ := ParseConnRole(claims.Role)
+	for _, allowed := range roles {
+		if role == allowed {
+			return true
+		}
+	}
+	http.Error(w, ErrUnauthorized.Error(), http.StatusForbidden)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("error to write response", "error", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakePinger struct {
+	err error
+}
+
+func (p fakePinger) Ping() error { return p.err }
+
+type fakeStatusStream struct {
+	idleEventStream
+	status []StreamStatus
+}
+
+func (s fakeStatusStream) Status() []StreamStatus { return s.status }
+
+type pingLocationService struct {
+	PlayerLocationService
+	fakePinger
+}
+
+func (s pingLocationService) Ping() error { return s.fakePinger.Ping() }
+
+func TestReadyzReportsEachDependency(t *testing.T) {
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	games := NewGameWatcher(idleEventStream{}, server, nil, GameLease{Locks: NewMemoryLock(), Owner: "a", TTL: time.Second}, DefaultGameRules, nil, nil)
+	stream := fakeStatusStream{status: []StreamStatus{{Query: "NEARBY player", Connected: true}}}
+	service := pingLocationService{}
+	h := NewHealthHandler(server, service, stream, games, fakePinger{}, NoAuthenticator{})
+
+	recorder := httptest.NewRecorder()
+	h.Readyz(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatal("expected ready, got:", recorder.Code, recorder.Body.String())
+	}
+
+	stream.status[0].Connected = false
+	h = NewHealthHandler(server, service, stream, games, fakePinger{errors.New("influxdb down")}, NoAuthenticator{})
+	recorder = httptest.NewRecorder()
+	h.Readyz(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	body := struct {
+		Ready  bool
+		Checks map[string]string
+	}{}
+	json.NewDecoder(recorder.Body).Decode(&body)
+	if recorder.Code != http.StatusServiceUnavailable || body.Ready {
+		t.Fatal("expected not ready, got:", recorder.Code, body)
+	}
+	if body.Checks["location_service"] != "ok" || body.Checks["metrics"] != "influxdb down" ||
+		body.Checks["fence_streams"] != ErrStreamDisconnected.Error() {
+		t.Fatal("unexpected checks:", body.Checks)
+	}
+}
+
+func TestDebugStateRequiresAdminToken(t *testing.T) {
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	server.Add(&fakeWSConn{})
+	games := NewGameWatcher(idleEventStream{}, server, nil, GameLease{Locks: NewMemoryLock(), Owner: "a", TTL: time.Second}, DefaultGameRules, nil, nil)
+	auth := NewHMACAuthenticator("secret")
+	h := NewHealthHandler(server, pingLocationService{}, idleEventStream{}, games, fakePinger{}, auth)
+
+	player, _ := auth.Sign(AuthClaims{Subject: "p1", Role: "player"})
+	recorder := httptest.NewRecorder()
+	h.DebugState(recorder, httptest.NewRequest("GET", "/debug/state?token="+player, nil))
+	if recorder.Code != http.StatusForbidden {
+		t.Fatal("expected players to be forbidden, got:", recorder.Code)
+	}
+
+	admin, _ := auth.Sign(AuthClaims{Subject: "ops", Role: "admin"})
+	recorder = httptest.NewRecorder()
+	h.DebugState(recorder, httptest.NewRequest("GET", "/debug/state?token="+admin, nil))
+	state := struct {
+		Connections struct{ Total int }
+	}{}
+	json.NewDecoder(recorder.Body).Decode(&state)
+	if recorder.Code != http.StatusOK || state.Connections.Total != 1 {
+		t.Fatal("unexpected state:", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/model"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+// DefaultInterestRadius is the distance in meters players see each other
+const DefaultInterestRadius = 1000
+
+// bus handlers of the players events, every node routes them to its own connections
+const (
+	busPlayerJoined = "interest:player:joined"
+	busPlayerLeft   = "interest:player:left"
+)
+
+// AreaOfInterest decides which connections receive the remote-player events
+// players see the ones within radius or inside the same game geofence,
+// admins and observers see everyone
+// each node routes the players events to its own connections
+// with the neighbors found by the node which published them
+type AreaOfInterest struct {
+	server  *WSServer
+	service PlayerLocationService
+	radius  float64
+	logger  *slog.Logger
+
+	// visible are the players each connection was told about
+	visible map[string]map[string]bool
+	sync.Mutex
+}
+
+// NewAreaOfInterest creates an AreaOfInterest, radius zero disables the filter
+func NewAreaOfInterest(server *WSServer, service PlayerLocationService, radius float64, logger *slog.Logger) *AreaOfInterest {
+	a := &AreaOfInterest{server: server, service: service, radius: radius, logger: loggerOrDefault(logger),
+		visible: make(map[string]map[string]bool)}
+	server.HandleBus(busPlayerJoined, func(m Message) {
+		if msg, ok := m.(*protobuf.PlayerBatch); ok && len(msg.Players) == 1 {
+			a.playerJoined(playerFromMessage(msg.Players[0]), neighborhoodFromMessage(msg.Neighbors))
+		}
+	})
+	server.HandleBus(busPlayerLeft, func(m Message) {
+		if msg, ok := m.(*protobuf.Player); ok {
+			a.playerLeft(playerFromMessage(msg))
+		}
+	})
+	return a
+}
+
+// Route is what a connection must receive about updated players
+type Route struct {
+	Conn *WSConnListener
+	// Updated players were already known by the connection
+	Updated []*model.Player
+	// Joined players entered the connection area, they are sent as remote-player:new
+	Joined []*model.Player
+	// Left players left the connection area, they are sent as remote-player:destroy
+	Left []*model.Player
+}
+
+// Neighborhood are the players seen by each player, by player id
+type Neighborhood map[string]map[string]*model.Player
+
+// ReceivesAll tells if the connection sees every player
+func (a *AreaOfInterest) ReceivesAll(c *WSConnListener) bool {
+	return a.radius <= 0 || c.Role == RoleAdmin || c.Role == RoleObserver
+}
+
+// Neighbors returns the players p sees, p excluded
+func (a *AreaOfInterest) Neighbors(p *model.Player) (map[string]*model.Player, error) {
+	return a.neighbors(p, make(map[string]model.PlayerList))
+}
+
+// neighbors reuses the players of the geofences found in within
+func (a *AreaOfInterest) neighbors(p *model.Player, within map[string]model.PlayerList) (map[string]*model.Player, error) {
+	neighbors := make(map[string]*model.Player)
+	around, err := a.service.PlayersAround(p.Point(), a.radius)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range around {
+		neighbors[n.ID] = n
+	}
+	geofences, err := a.service.FeaturesAt("geofences", p.Point())
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range geofences {
+		players, found := within[g.ID]
+		if !found {
+			if players, err = a.service.PlayersWithin(g); err != nil {
+				return nil, err
+			}
+			within[g.ID] = players
+		}
+		for _, n := range players {
+			neighbors[n.ID] = n
+		}
+	}
+	delete(neighbors, p.ID)
+	return neighbors, nil
+}
+
+// Neighborhood returns the neighbors of the updated players, it is empty when the filter is disabled
+// players whose neighbors can't be found are left out
+func (a *AreaOfInterest) Neighborhood(players []*model.Player) Neighborhood {
+	neighbors := make(Neighborhood, len(players))
+	if a.radius <= 0 {
+		return neighbors
+	}
+	within := make(map[string]model.PlayerList)
+	for _, p := range players {
+		n, err := a.neighbors(p, within)
+		if err != nil {
+			a.logger.Error("error to find neighbors", LogPlayerID, p.ID, "error", err)
+			continue
+		}
+		neighbors[p.ID] = n
+	}
+	return neighbors
+}
+
+// Route returns what each connection of this node must receive about the updated players
+// neighbors are the Neighborhood of the players, updated players also discover their new neighbors
+func (a *AreaOfInterest) Route(players []*model.Player, neighbors Neighborhood) []*Route {
+	a.Lock()
+	defer a.Unlock()
+	routes := make([]*Route, 0)
+	a.server.ForEach(func(c *WSConnListener) {
+		route := &Route{Conn: c}
+		if a.ReceivesAll(c) {
+			route.Updated = players
+			routes = append(routes, route)
+			return
+		}
+		for _, p := range players {
+			n, found := neighbors[p.ID]
+			if !found || c.ID() == p.ID {
+				continue
+			}
+			_, interested := n[c.ID()]
+			a.route(route, p, interested)
+		}
+		if n, found := neighbors[c.ID()]; found {
+			a.discover(route, n)
+		}
+		if len(route.Updated)+len(route.Joined)+len(route.Left) > 0 {
+			routes = append(routes, route)
+		}
+	})
+	return routes
+}
+
+func (a *AreaOfInterest) route(route *Route, p *model.Player, interested bool) {
+	visible := a.visibleTo(route.Conn.ID())
+	switch {
+	case interested && visible[p.ID]:
+		route.Updated = append(route.Updated, p)
+	case interested:
+		visible[p.ID] = true
+		route.Joined = append(route.Joined, p)
+	case visible[p.ID]:
+		delete(visible, p.ID)
+		route.Left = append(route.Left, p)
+	}
+}
+
+// discover the neighbors of the connection player and forget the ones which left
+func (a *AreaOfInterest) discover(route *Route, neighbors map[string]*model.Player) {
+	visible := a.visibleTo(route.Conn.ID())
+	for id, n := range neighbors {
+		if !visible[id] {
+			visible[id] = true
+			route.Joined = append(route.Joined, n)
+		}
+	}
+	for id := range visible {
+		if _, found := neighbors[id]; !found {
+			delete(visible, id)
+			route.Left = append(route.Left, &model.Player{ID: id})
+		}
+	}
+}
+
+func (a *AreaOfInterest) visibleTo(connID string) map[string]bool {
+	visible, exists := a.visible[connID]
+	if !exists {
+		visible = make(map[string]bool)
+		a.visible[connID] = visible
+	}
+	return visible
+}
+
+// PlayerJoined sends remote-player:new of p to the connections interested on it on every node
+func (a *AreaOfInterest) PlayerJoined(p *model.Player) {
+	neighbors := a.Neighborhood([]*model.Player{p})
+	a.playerJoined(p, neighbors)
+	msg := &protobuf.PlayerBatch{EventName: proto.String("remote-player:new"),
+		Players: []*protobuf.Player{playerMessage("remote-player:new", p)}, Neighbors: neighbors.message()}
+	if err := a.server.PublishToNodes(busPlayerJoined, msg); err != nil {
+		a.logger.Error("error to publish player", LogPlayerID, p.ID, LogEvent, "remote-player:new", "error", err)
+	}
+}
+
+func (a *AreaOfInterest) playerJoined(p *model.Player, neighbors Neighborhood) {
+	for _, route := range a.Route([]*model.Player{p}, neighbors) {
+		for _, joined := range append(route.Updated, route.Joined...) {
+			route.Conn.Emit(playerMessage("remote-player:new", joined))
+		}
+		for _, left := range route.Left {
+			route.Conn.Emit(playerMessage("remote-player:destroy", left))
+		}
+	}
+}
+
+// PlayerLeft sends remote-player:destroy of p to the connections which knew it on every node
+func (a *AreaOfInterest) PlayerLeft(p *model.Player) {
+	a.playerLeft(p)
+	if err := a.server.PublishToNodes(busPlayerLeft, playerMessage("remote-player:destroy", p)); err != nil {
+		a.logger.Error("error to publish player", LogPlayerID, p.ID, LogEvent, "remote-player:destroy", "error", err)
+	}
+}
+
+func (a *AreaOfInterest) playerLeft(p *model.Player) {
+	msg := playerMessage("remote-player:destroy", p)
+	a.Lock()
+	defer a.Unlock()
+	delete(a.visible, p.ID)
+	a.server.ForEach(func(c *WSConnListener) {
+		visible := a.visible[c.ID()]
+		if a.ReceivesAll(c) || visible[p.ID] {
+			delete(visible, p.ID)
+			c.Emit(msg)
+		}
+	})
+}
+
+// Renamed makes the connection renamed from oldID to newID keep the players it was told about
+func (a *AreaOfInterest) Renamed(oldID, newID string) {
+	a.Lock()
+	defer a.Unlock()
+	if visible, exists := a.visible[oldID]; exists {
+		delete(a.visible, oldID)
+		delete(visible, newID)
+		a.visible[newID] = visible
+	}
+}
+
+func (n Neighborhood) message() []*protobuf.PlayerNeighbors {
+	msg := make([]*protobuf.PlayerNeighbors, 0, len(n))
+	for id, neighbors := range n {
+		entry := &protobuf.PlayerNeighbors{PlayerId: proto.String(id),
+			Neighbors: make([]*protobuf.Player, 0, len(neighbors))}
+		for _, p := range neighbors {
+			entry.Neighbors = append(entry.Neighbors, playerMessage("remote-player:new", p))
+		}
+		msg = append(msg, entry)
+	}
+	return msg
+}
+
+func neighborhoodFromMessage(msg []*protobuf.PlayerNeighbors) Neighborhood {
+	n := make(Neighborhood, len(msg))
+	for _, entry := range msg {
+		neighbors := make(map[string]*model.Player, len(entry.Neighbors))
+		for _, p := range entry.Neighbors {
+			neighbors[p.GetId()] = playerFromMessage(p)
+		}
+		n[entry.GetPlayerId()] = neighbors
+	}
+	return n
+}
+
+func playerFromMessage(msg *protobuf.Player) *model.Player {
+	return &model.Player{ID: msg.GetId(), Lat: msg.GetLat(), Lon: msg.GetLon(), Name: msg.GetName(), Color: msg.GetColor()}
+}
+
+// Visible filters the players the connection sees and remembers them as known
+func (a *AreaOfInterest) Visible(c *WSConnListener, players model.PlayerList) (model.PlayerList, error) {
+	if a.ReceivesAll(c) {
+		return players, nil
+	}
+	var self *model.Player
+	for _, p := range players {
+		if p != nil && p.ID == c.ID() {
+			self = p
+		}
+	}
+	if self == nil {
+		return model.PlayerList{}, nil
+	}
+	neighbors, err := a.Neighbors(self)
+	if err != nil {
+		return nil, err
+	}
+	a.Lock()
+	defer a.Unlock()
+	visible := a.visibleTo(c.ID())
+	list := make(model.PlayerList, 0, len(neighbors))
+	for _, p := range players {
+		if p == nil {
+			continue
+		}
+		if _, found := neighbors[p.ID]; found {
+			visible[p.ID] = true
+			list = append(list, p)
+		}
+	}
+	return list, nil
+}
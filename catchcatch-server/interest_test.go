@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	geo "github.com/kellydunn/golang-geo"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/model"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+type fakeLocationService struct {
+	PlayerLocationService
+	players model.PlayerList
+}
+
+func (s *fakeLocationService) PlayersAround(point *geo.Point, meters float64) (model.PlayerList, error) {
+	around := model.PlayerList{}
+	for _, p := range s.players {
+		if p.Point().GreatCircleDistance(point)*1000 <= meters {
+			around = append(around, p)
+		}
+	}
+	return around, nil
+}
+
+func (s *fakeLocationService) FeaturesAt(group string, point *geo.Point) ([]*model.Feature, error) {
+	return nil, nil
+}
+
+func sentEvents(t *testing.T, conn *fakeWSConn) []string {
+	events := make([]string, 0, len(conn.sent))
+	for _, payload := range conn.sent {
+		msg := &protobuf.Player{}
+		if err := proto.Unmarshal(payload, msg); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, msg.GetEventName()+" "+msg.GetId())
+	}
+	conn.sent = nil
+	return events
+}
+
+func TestAreaOfInterestSendsUpdatesToNearbyConnections(t *testing.T) {
+	p1 := &model.Player{ID: "p1", Lat: -23.5500, Lon: -46.6300}
+	p2 := &model.Player{ID: "p2", Lat: -23.5510, Lon: -46.6310}
+	p3 := &model.Player{ID: "p3", Lat: -22.9000, Lon: -43.2000}
+	service := &fakeLocationService{players: model.PlayerList{p1, p2, p3}}
+
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	conns := map[string]*fakeWSConn{}
+	for _, id := range []string{"p1", "p2", "p3", "admin"} {
+		conns[id] = &fakeWSConn{}
+		c := server.Add(conns[id])
+		server.Rename(c, id)
+		if id == "admin" {
+			c.Role = RoleAdmin
+		}
+	}
+	u := NewPositionUpdates(NewAreaOfInterest(server, service, 1000, nil), PositionUpdateConfig{})
+
+	u.Publish(p1)
+	if events := sentEvents(t, conns["p2"]); len(events) != 1 || events[0] != "remote-player:new p1" {
+		t.Fatal("expected p2 to discover p1, got:", events)
+	}
+	if events := sentEvents(t, conns["p1"]); len(events) != 1 || events[0] != "remote-player:new p2" {
+		t.Fatal("expected p1 to discover p2, got:", events)
+	}
+	if events := sentEvents(t, conns["p3"]); len(events) != 0 {
+		t.Fatal("expected nothing sent to p3, got:", events)
+	}
+	if events := sentEvents(t, conns["admin"]); len(events) != 1 || events[0] != "remote-player:updated p1" {
+		t.Fatal("expected admin to receive every update, got:", events)
+	}
+
+	u.Publish(p1)
+	if events := sentEvents(t, conns["p2"]); len(events) != 1 || events[0] != "remote-player:updated p1" {
+		t.Fatal("expected p2 to receive p1 update, got:", events)
+	}
+
+	p1.Lat, p1.Lon = p3.Lat, p3.Lon
+	u.Publish(p1)
+	if events := sentEvents(t, conns["p2"]); len(events) != 1 || events[0] != "remote-player:destroy p1" {
+		t.Fatal("expected p1 to leave p2 area, got:", events)
+	}
+	if events := sentEvents(t, conns["p3"]); len(events) != 1 || events[0] != "remote-player:new p1" {
+		t.Fatal("expected p3 to discover p1, got:", events)
+	}
+}
+
+func TestAreaOfInterestReachesPlayersOnOtherNodes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p1 := &model.Player{ID: "p1", Lat: -23.5500, Lon: -46.6300}
+	p2 := &model.Player{ID: "p2", Lat: -23.5510, Lon: -46.6310}
+	service := &fakeLocationService{players: model.PlayerList{p1, p2}}
+
+	bus, directory := NewLocalMessageBus(), NewLocalConnDirectory()
+	nodeA, nodeB := NewWSServer(nil, SendQueueConfig{}, nil), NewWSServer(nil, SendQueueConfig{}, nil)
+	nodeA.JoinCluster(ctx, "a", bus, directory)
+	nodeB.JoinCluster(ctx, "b", bus, directory)
+	connA, connB := &fakeWSConn{}, &fakeWSConn{}
+	nodeA.Rename(nodeA.Add(connA), "p1")
+	nodeB.Rename(nodeB.Add(connB), "p2")
+	interestA := NewAreaOfInterest(nodeA, service, 1000, nil)
+	// node b finds no one, it must route with the neighbors found by node a
+	NewPositionUpdates(NewAreaOfInterest(nodeB, &fakeLocationService{}, 1000, nil), PositionUpdateConfig{})
+	u := NewPositionUpdates(interestA, PositionUpdateConfig{})
+
+	u.Publish(p1)
+	if events := sentEvents(t, connB); len(events) != 1 || events[0] != "remote-player:new p1" {
+		t.Fatal("expected p2 on node b to discover p1, got:", events)
+	}
+	u.Publish(p1)
+	if events := sentEvents(t, connB); len(events) != 1 || events[0] != "remote-player:updated p1" {
+		t.Fatal("expected p2 on node b to receive p1 update, got:", events)
+	}
+	interestA.PlayerLeft(p1)
+	if events := sentEvents(t, connB); len(events) != 1 || events[0] != "remote-player:destroy p1" {
+		t.Fatal("expected p2 on node b to forget p1, got:", events)
+	}
+	interestA.PlayerJoined(p1)
+	if events := sentEvents(t, connB); len(events) != 1 || events[0] != "remote-player:new p1" {
+		t.Fatal("expected p2 on node b to discover p1 again, got:", events)
+	}
+}
+
+func TestAreaOfInterestKeepsVisiblePlayersOnRename(t *testing.T) {
+	p1 := &model.Player{ID: "p1", Lat: -23.5500, Lon: -46.6300}
+	p2 := &model.Player{ID: "p2", Lat: -23.5510, Lon: -46.6310}
+	service := &fakeLocationService{players: model.PlayerList{p1, p2}}
+
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	conn := &fakeWSConn{}
+	c := server.Add(conn)
+	server.Rename(c, "p1")
+	interest := NewAreaOfInterest(server, service, 1000, nil)
+	u := NewPositionUpdates(interest, PositionUpdateConfig{})
+
+	u.Publish(p2)
+	if events := sentEvents(t, conn); len(events) != 1 || events[0] != "remote-player:new p2" {
+		t.Fatal("expected p1 to discover p2, got:", events)
+	}
+	server.Rename(c, "profile-1")
+	interest.Renamed("p1", "profile-1")
+	p1.ID = "profile-1"
+	u.Publish(p2)
+	if events := sentEvents(t, conn); len(events) != 1 || events[0] != "remote-player:updated p2" {
+		t.Fatal("expected the renamed connection to keep knowing p2, got:", events)
+	}
+}
+
+type geofenceLocationService struct {
+	fakeLocationService
+	geofence    *model.Feature
+	withinCalls int
+}
+
+func (s *geofenceLocationService) FeaturesAt(group string, point *geo.Point) ([]*model.Feature, error) {
+	return []*model.Feature{s.geofence}, nil
+}
+
+func (s *geofenceLocationService) PlayersWithin(f *model.Feature) (model.PlayerList, error) {
+	s.withinCalls++
+	return s.players, nil
+}
+
+func TestAreaOfInterestQueriesEachGeofenceOncePerBatch(t *testing.T) {
+	players := model.PlayerList{
+		{ID: "p1", Lat: -23.5500, Lon: -46.6300},
+		{ID: "p2", Lat: -23.5510, Lon: -46.6310},
+		{ID: "p3", Lat: -23.5520, Lon: -46.6320},
+	}
+	service := &geofenceLocationService{fakeLocationService: fakeLocationService{players: players},
+		geofence: &model.Feature{ID: "g1", Group: "geofences"}}
+	interest := NewAreaOfInterest(NewWSServer(nil, SendQueueConfig{}, nil), service, 1000, nil)
+
+	interest.Neighborhood(players)
+	if service.withinCalls != 1 {
+		t.Fatal("expected the geofence players to be queried once, got:", service.withinCalls)
+	}
+}
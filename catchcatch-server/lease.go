@@ -0,0 +1,106 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	redis "gopkg.in/redis.v5"
+)
+
+// DefaultLeaseTTL is how long a game lease lasts without being renewed
+const DefaultLeaseTTL = 10 * time.Second
+
+// LockBackend stores leases shared by the server replicas
+type LockBackend interface {
+	// Acquire takes or renews key for owner during ttl
+	// it returns false when key is held by another owner
+	Acquire(key, owner string, ttl time.Duration) (bool, error)
+	// Release frees key when it is held by owner
+	Release(key, owner string) error
+}
+
+// MemoryLock is a LockBackend for a single server
+type MemoryLock struct {
+	leases map[string]memoryLease
+	now    func() time.Time
+	sync.Mutex
+}
+
+type memoryLease struct {
+	owner   string
+	expires time.Time
+}
+
+// NewMemoryLock creates a MemoryLock
+func NewMemoryLock() *MemoryLock {
+	return &MemoryLock{leases: make(map[string]memoryLease), now: time.Now}
+}
+
+// Acquire implements LockBackend.Acquire
+func (l *MemoryLock) Acquire(key, owner string, ttl time.Duration) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	now := l.now()
+	if lease, exists := l.leases[key]; exists && lease.owner != owner && now.Before(lease.expires) {
+		return false, nil
+	}
+	l.leases[key] = memoryLease{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Release implements LockBackend.Release
+func (l *MemoryLock) Release(key, owner string) error {
+	l.Lock()
+	defer l.Unlock()
+	if l.leases[key].owner == owner {
+		delete(l.leases, key)
+	}
+	return nil
+}
+
+// the lease scripts compare the owner and change the key atomically
+const (
+	redisAcquireScript = `local current = redis.call('GET', KEYS[1])
+if not current then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+if current == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0`
+	redisReleaseScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`
+)
+
+var (
+	acquireScript = redis.NewScript(redisAcquireScript)
+	releaseScript = redis.NewScript(redisReleaseScript)
+)
+
+// RedisLock is a LockBackend stored in redis keys which expire with the lease
+type RedisLock struct {
+	client *redis.Client
+}
+
+// NewRedisLock creates a RedisLock
+func NewRedisLock(client *redis.Client) *RedisLock {
+	return &RedisLock{client}
+}
+
+// Acquire implements LockBackend.Acquire
+func (l *RedisLock) Acquire(key, owner string, ttl time.Duration) (bool, error) {
+	acquired, err := acquireScript.Run(l.client, []string{key}, owner, int64(ttl/time.Millisecond)).Result()
+	if err != nil {
+		return false, err
+	}
+	return acquired == int64(1), nil
+}
+
+// Release implements LockBackend.Release
+func (l *RedisLock) Release(key, owner string) error {
+	return releaseScript.Run(l.client, []string{key}, owner).Err()
+}
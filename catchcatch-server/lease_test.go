@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+	redis "gopkg.in/redis.v5"
+)
+
+func TestMemoryLockLeases(t *testing.T) {
+	now := time.Now()
+	locks := NewMemoryLock()
+	locks.now = func() time.Time { return now }
+
+	if owned, _ := locks.Acquire("g1", "a", time.Second); !owned {
+		t.Fatal("expected a to acquire the lease")
+	}
+	if owned, _ := locks.Acquire("g1", "b", time.Second); owned {
+		t.Fatal("expected b not to acquire a lease held by a")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if owned, _ := locks.Acquire("g1", "a", time.Second); !owned {
+		t.Fatal("expected a to renew its lease")
+	}
+	locks.Release("g1", "b")
+	now = now.Add(time.Second)
+	if owned, _ := locks.Acquire("g1", "b", time.Second); !owned {
+		t.Fatal("expected b to acquire the expired lease")
+	}
+	locks.Release("g1", "b")
+	if owned, _ := locks.Acquire("g1", "a", time.Second); !owned {
+		t.Fatal("expected a to acquire the released lease")
+	}
+}
+
+type idleEventStream struct{}
+
+func (idleEventStream) StreamNearByEvents(ctx context.Context, nearByKey, roamKey string, meters int, callback DetectionHandler) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (idleEventStream) StreamIntersects(ctx context.Context, intersectKey, onKey, onKeyID string, callback DetectionHandler) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (idleEventStream) Status() []StreamStatus {
+	return nil
+}
+
+// crashableLock stops renewing and releasing leases like a replica which died
+type crashableLock struct {
+	LockBackend
+	crashed bool
+	sync.Mutex
+}
+
+func (l *crashableLock) Acquire(key, owner string, ttl time.Duration) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.crashed {
+		return false, errors.New("crashed")
+	}
+	return l.LockBackend.Acquire(key, owner, ttl)
+}
+
+func (l *crashableLock) Release(key, owner string) error {
+	l.Lock()
+	defer l.Unlock()
+	if l.crashed {
+		return nil
+	}
+	return l.LockBackend.Release(key, owner)
+}
+
+func (gw *GameWatcher) watching(gameID string) bool {
+	gw.Lock()
+	defer gw.Unlock()
+	_, exists := gw.games[gameID]
+	return exists
+}
+
+func waitFor(t *testing.T, description string, condition func() bool) {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if condition() {
+			return
+		}
+	}
+	t.Fatal("timeout waiting for", description)
+}
+
+func TestGameWatcherLeaseHandoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var clock sync.Mutex
+	now := time.Now()
+	locks := NewMemoryLock()
+	locks.now = func() time.Time {
+		clock.Lock()
+		defer clock.Unlock()
+		return now
+	}
+	lockA := &crashableLock{LockBackend: locks}
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	ttl := 30 * time.Millisecond
+	gwA := NewGameWatcher(idleEventStream{}, server, nil, GameLease{Locks: lockA, Owner: "a", TTL: ttl}, DefaultGameRules, nil, nil)
+	gwB := NewGameWatcher(idleEventStream{}, server, nil, GameLease{Locks: locks, Owner: "b", TTL: ttl}, DefaultGameRules, nil, nil)
+
+	go gwA.watchGame(ctx, "g1")
+	waitFor(t, "a to watch g1", func() bool { return gwA.watching("g1") })
+	if err := gwB.watchGame(ctx, "g1"); err != nil || gwB.watching("g1") {
+		t.Fatal("expected b not to watch a game owned by a, got:", err)
+	}
+
+	lockA.Lock()
+	lockA.crashed = true
+	lockA.Unlock()
+	waitFor(t, "a to stop g1 after losing its lease", func() bool { return !gwA.watching("g1") })
+	if gwB.watchGame(ctx, "g1"); gwB.watching("g1") {
+		t.Fatal("expected b to wait for the lease to expire")
+	}
+
+	clock.Lock()
+	now = now.Add(ttl)
+	clock.Unlock()
+	go gwB.watchGame(ctx, "g1")
+	waitFor(t, "b to take g1 over", func() bool { return gwB.watching("g1") })
+}
+
+// blockingLock holds Acquire until release is closed
+type blockingLock struct {
+	LockBackend
+	acquiring chan struct{}
+	release   chan struct{}
+}
+
+func (l *blockingLock) Acquire(key, owner string, ttl time.Duration) (bool, error) {
+	l.acquiring <- struct{}{}
+	<-l.release
+	return l.LockBackend.Acquire(key, owner, ttl)
+}
+
+func TestGameWatcherAcquiresLeasesWithoutLocking(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	locks := &blockingLock{LockBackend: NewMemoryLock(), acquiring: make(chan struct{}), release: make(chan struct{})}
+	gw := NewGameWatcher(idleEventStream{}, NewWSServer(nil, SendQueueConfig{}, nil), nil,
+		GameLease{Locks: locks, Owner: "a", TTL: time.Minute}, DefaultGameRules, nil, nil)
+
+	go gw.watchGame(ctx, "g1")
+	<-locks.acquiring
+	done := make(chan struct{})
+	go func() {
+		gw.Games()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the watcher not to be locked while acquiring a lease")
+	}
+	close(locks.release)
+	waitFor(t, "a to watch g1", func() bool { return gw.watching("g1") })
+}
+
+func TestGameWatcherTellsPlayersTheLeaseWasLost(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lock := &crashableLock{LockBackend: NewMemoryLock()}
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	conn := &fakeWSConn{}
+	server.Rename(server.Add(conn), "p1")
+	server.Join(GameRoom("g1"), "p1")
+	gw := NewGameWatcher(idleEventStream{}, server, nil, GameLease{Locks: lock, Owner: "a", TTL: 30 * time.Millisecond}, DefaultGameRules, nil, nil)
+
+	go gw.watchGame(ctx, "g1")
+	waitFor(t, "a to watch g1", func() bool { return gw.watching("g1") })
+	lock.Lock()
+	lock.crashed = true
+	lock.Unlock()
+	waitFor(t, "a to stop g1 after losing its lease", func() bool { return !gw.watching("g1") })
+
+	if len(conn.sent) != 1 {
+		t.Fatal("expected the player to be told the game was lost, got:", len(conn.sent))
+	}
+	msg := &protobuf.Simple{}
+	if err := proto.Unmarshal(conn.sent[0], msg); err != nil || msg.GetEventName() != "game:loose" || msg.GetId() != "g1" {
+		t.Fatal("unexpected message:", msg, err)
+	}
+	if members := server.RoomMembers(GameRoom("g1")); len(members) != 0 {
+		t.Fatal("expected the game room to be closed, got:", members)
+	}
+}
+
+func TestGameWatcherGamesSnapshotsRunningGames(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gw := NewGameWatcher(idleEventStream{}, NewWSServer(nil, SendQueueConfig{}, nil), nil,
+		GameLease{Locks: NewMemoryLock(), Owner: "a", TTL: time.Minute}, DefaultGameRules, nil, nil)
+	go gw.watchGame(ctx, "g1")
+	waitFor(t, "a to watch g1", func() bool { return gw.watching("g1") })
+	gw.Lock()
+	game := gw.games["g1"].game
+	gw.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, id := range []string{"p1", "p2"} {
+			game.SetPlayer(id, -46.63, -23.55)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		gw.Games()
+	}
+	<-done
+	if games := gw.Games(); len(games) != 1 || games[0].Players != 2 || games[0].Started {
+		t.Fatal("unexpected games:", games)
+	}
+}
+
+func TestRedisLockLeases(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: startFakeRedis(t)})
+	defer client.Close()
+	locks := NewRedisLock(client)
+
+	if owned, err := locks.Acquire("g1", "a", time.Second); err != nil || !owned {
+		t.Fatal("expected a to acquire the lease, got:", owned, err)
+	}
+	if owned, _ := locks.Acquire("g1", "b", time.Second); owned {
+		t.Fatal("expected b not to acquire a lease held by a")
+	}
+	if owned, _ := locks.Acquire("g1", "a", time.Second); !owned {
+		t.Fatal("expected a to renew its lease")
+	}
+	locks.Release("g1", "b")
+	if owned, _ := locks.Acquire("g1", "b", time.Second); owned {
+		t.Fatal("expected b not to release a lease held by a")
+	}
+	locks.Release("g1", "a")
+	if owned, _ := locks.Acquire("g1", "b", time.Second); !owned {
+		t.Fatal("expected b to acquire the released lease")
+	}
+
+	// the key expires and another replica takes it, a must not renew it
+	client.Del("g1")
+	locks.Acquire("g1", "c", time.Second)
+	if owned, _ := locks.Acquire("g1", "a", time.Second); owned {
+		t.Fatal("expected a not to renew a lease taken by c")
+	}
+}
+
+// flakyLock fails the renews while failures is positive
+type flakyLock struct {
+	LockBackend
+	failures int
+	sync.Mutex
+}
+
+func (l *flakyLock) Acquire(key, owner string, ttl time.Duration) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.failures > 0 {
+		l.failures--
+		return false, errors.New("timeout")
+	}
+	return l.LockBackend.Acquire(key, owner, ttl)
+}
+
+func TestGameWatcherRetriesLeaseRenewErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lock := &flakyLock{LockBackend: NewMemoryLock()}
+	ttl := 60 * time.Millisecond
+	gw := NewGameWatcher(idleEventStream{}, NewWSServer(nil, SendQueueConfig{}, nil), nil,
+		GameLease{Locks: lock, Owner: "a", TTL: ttl}, DefaultGameRules, nil, nil)
+
+	go gw.watchGame(ctx, "g1")
+	waitFor(t, "a to watch g1", func() bool { return gw.watching("g1") })
+	lock.Lock()
+	lock.failures = 1
+	lock.Unlock()
+	time.Sleep(3 * ttl)
+	if !gw.watching("g1") {
+		t.Fatal("expected one renew error not to stop the game")
+	}
+}
+
+// detectionStream sends the nearby detections of gameID and ends
+type detectionStream struct {
+	idleEventStream
+	gameID     string
+	detections int
+}
+
+func (s detectionStream) StreamNearByEvents(ctx context.Context, nearByKey, roamKey string, meters int, callback DetectionHandler) error {
+	for i := 0; i < s.detections; i++ {
+		callback(&Detection{FeatID: "p1", NearByFeatID: s.gameID})
+	}
+	return nil
+}
+
+// countingLock counts the acquire calls
+type countingLock struct {
+	LockBackend
+	acquires int
+	sync.Mutex
+}
+
+func (l *countingLock) Acquire(key, owner string, ttl time.Duration) (bool, error) {
+	l.Lock()
+	l.acquires++
+	l.Unlock()
+	return l.LockBackend.Acquire(key, owner, ttl)
+}
+
+func TestGameWatcherSkipsGamesLeasedElsewhere(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	locks := NewMemoryLock()
+	locks.Acquire(gameLeaseKey("g1"), "a", time.Minute)
+	lock := &countingLock{LockBackend: locks}
+	gw := NewGameWatcher(detectionStream{gameID: "g1", detections: 5}, NewWSServer(nil, SendQueueConfig{}, nil), nil,
+		GameLease{Locks: lock, Owner: "b", TTL: time.Minute}, DefaultGameRules, nil, nil)
+
+	if err := gw.watchGame(ctx, "g1"); err != nil || gw.watching("g1") {
+		t.Fatal("expected b not to watch a game owned by a, got:", err)
+	}
+	gw.WatchGames(ctx)
+	time.Sleep(20 * time.Millisecond)
+	lock.Lock()
+	defer lock.Unlock()
+	if lock.acquires != 1 {
+		t.Fatal("expected no lease request for a game leased elsewhere, got:", lock.acquires)
+	}
+}
+
+func TestGameWatcherAbortsGamesWhenTheLeaseIsLost(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lock := &crashableLock{LockBackend: NewMemoryLock()}
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	conns := map[string]*fakeWSConn{"p1": {}, "p2": {}}
+	for id, conn := range conns {
+		server.Rename(server.Add(conn), id)
+	}
+	rules := DefaultGameRules
+	rules.MinPlayers = 2
+	gw := NewGameWatcher(idleEventStream{}, server, nil, GameLease{Locks: lock, Owner: "a", TTL: 30 * time.Millisecond}, rules, nil, nil)
+
+	go gw.watchGame(ctx, "g1")
+	waitFor(t, "a to watch g1", func() bool { return gw.watching("g1") })
+	gw.Lock()
+	game := gw.games["g1"].game
+	gw.Unlock()
+	game.SetPlayer("p1", -46.63, -23.55)
+	game.SetPlayer("p2", -46.63, -23.55)
+	for deadline := time.Now().Add(2 * time.Second); !game.Running() && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !game.Running() {
+		t.Fatal("expected the game to start")
+	}
+
+	lock.Lock()
+	lock.crashed = true
+	lock.Unlock()
+	waitFor(t, "a to stop g1 after losing its lease", func() bool { return !gw.watching("g1") })
+	time.Sleep(20 * time.Millisecond)
+	for id, conn := range conns {
+		events := make([]string, 0, len(conn.sent))
+		for _, payload := range conn.sent {
+			msg := &protobuf.Simple{}
+			proto.Unmarshal(payload, msg)
+			events = append(events, msg.GetEventName())
+		}
+		if len(events) != 2 || events[0] != "game:started" || events[1] != "game:loose" {
+			t.Fatalf("expected %s to lose the game without a rank, got: %v", id, events)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Log fields shared by every component
+const (
+	LogConnID   = "conn_id"
+	LogPlayerID = "player_id"
+	LogGameID   = "game_id"
+	LogEvent    = "event"
+)
+
+var (
+	// ErrUnknownLogFormat happens when the log format is not json or logfmt
+	ErrUnknownLogFormat = errors.New("unknown log format, options: logfmt, json")
+	// ErrUnknownLogLevel happens when the log level is not debug, info, warn or error
+	ErrUnknownLogLevel = errors.New("unknown log level, options: debug, info, warn, error")
+)
+
+// NewLogger creates a structured logger writing to w in format json or logfmt
+// level can be changed while the logger is in use
+func NewLogger(w io.Writer, format string, level *slog.LevelVar) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "logfmt", "":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	}
+	return nil, ErrUnknownLogFormat
+}
+
+// ParseLogLevel converts debug, info, warn or error to slog.Level
+func ParseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	switch strings.ToLower(name) {
+	case "debug", "info", "warn", "error":
+		err := level.UnmarshalText([]byte(name))
+		return level, err
+	}
+	return level, ErrUnknownLogLevel
+}
+
+func loggerOrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+// LogLevelHandler shows and changes the log level at runtime
+// GET returns the level, PUT or POST with ?level= changes it
+// admin tokens are required when authentication is enabled, observers can only read it
+type LogLevelHandler struct {
+	level  *slog.LevelVar
+	auth   Authenticator
+	logger *slog.Logger
+}
+
+// NewLogLevelHandler creates a LogLevelHandler
+func NewLogLevelHandler(level *slog.LevelVar, auth Authenticator, logger *slog.Logger) *LogLevelHandler {
+	return &LogLevelHandler{level, auth, loggerOrDefault(logger)}
+}
+
+func (h *LogLevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !authorizeRole(w, r, h.auth, RoleAdmin, RoleObserver) {
+			return
+		}
+	case http.MethodPut, http.MethodPost:
+		if !authorizeRole(w, r, h.auth, RoleAdmin) {
+			return
+		}
+		level, err := ParseLogLevel(r.FormValue("level"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		previous := h.level.Level()
+		h.level.Set(level)
+		h.logger.Warn("log level changed", "from", previous.String(), "to", level.String(), "remote_addr", r.RemoteAddr)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"level": strings.ToLower(h.level.Level().String())})
+}
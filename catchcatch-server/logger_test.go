@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewLoggerFormats(t *testing.T) {
+	level := new(slog.LevelVar)
+	out := &bytes.Buffer{}
+	logger, err := NewLogger(out, "json", level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("game started", LogGameID, "g1")
+	line := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil || line[LogGameID] != "g1" || line["msg"] != "game started" {
+		t.Fatal("unexpected json log:", out.String(), err)
+	}
+
+	out.Reset()
+	logger, _ = NewLogger(out, "logfmt", level)
+	logger.Debug("hidden")
+	level.Set(slog.LevelDebug)
+	logger.Debug("tile38 command", "cmd", "PING")
+	if got := out.String(); strings.Contains(got, "hidden") || !strings.Contains(got, `msg="tile38 command" cmd=PING`) {
+		t.Fatal("unexpected logfmt log:", got)
+	}
+
+	if _, err := NewLogger(out, "xml", level); err != ErrUnknownLogFormat {
+		t.Fatal("expected ErrUnknownLogFormat, got:", err)
+	}
+}
+
+func TestLogLevelHandlerChangesLevel(t *testing.T) {
+	level := new(slog.LevelVar)
+	auth := NewHMACAuthenticator("secret")
+	h := NewLogLevelHandler(level, auth, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+	admin, _ := auth.Sign(AuthClaims{Subject: "ops", Role: "admin"})
+	observer, _ := auth.Sign(AuthClaims{Subject: "dashboard", Role: "observer"})
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("PUT", "/admin/log-level?level=debug&token="+observer, nil))
+	if recorder.Code != http.StatusForbidden || level.Level() != slog.LevelInfo {
+		t.Fatal("expected observers not to change the level, got:", recorder.Code, level.Level())
+	}
+
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("PUT", "/admin/log-level?level=verbose&token="+admin, nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatal("expected unknown levels to be refused, got:", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("PUT", "/admin/log-level?level=debug&token="+admin, nil))
+	if recorder.Code != http.StatusOK || level.Level() != slog.LevelDebug {
+		t.Fatal("expected admins to change the level, got:", recorder.Code, level.Level())
+	}
+
+	recorder = httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("GET", "/admin/log-level?token="+observer, nil))
+	if body := strings.TrimSpace(recorder.Body.String()); body != `{"level":"debug"}` {
+		t.Fatal("unexpected level:", recorder.Code, body)
+	}
+}
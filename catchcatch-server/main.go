@@ -2,100 +2,271 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime/debug"
 	"strconv"
+	"syscall"
 	"time"
 
 	zconf "github.com/grandcat/zeroconf"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/model"
 	redis "gopkg.in/redis.v5"
 )
 
-var (
-	tile38Addr     = flag.String("tile38-addr", "localhost:9851", "redis address")
-	maxConnections = flag.Int("tile38-connections", 100, "tile38 address")
-	port           = flag.Int("port", 5000, "server port")
-	webDir         = flag.String("web-dir", "../web", "web files dir")
-	zconfEnabled   = flag.Bool("zconf", false, "start zeroconf server")
-	debugMode      = flag.Bool("debug", false, "debug")
-	wsdriver       = flag.String("wsdriver", "xnet", "options: xnet, gobwas")
-
-	influxdbAddr = flag.String("influxdb-addr", "http://localhost:8086", "influxdb address")
-	influxdbDB   = flag.String("influxdb-db", "catchcatch", "influxdb database name")
-	influxdbUser = flag.String("influxdb-user", "", "influxdb user")
-	influxdbPass = flag.String("influxdb-pass", "", "influxdb password")
-)
-
 func main() {
-	flag.Parse()
-	if *zconfEnabled {
-		zcServer, _ := zconf.Register("CatchCatch", "_catchcatch._tcp", "", *port, nil, nil)
-		defer zcServer.Shutdown()
-	}
-
-	metrics, err := NewMetricsCollector(*influxdbAddr, *influxdbDB, *influxdbUser, *influxdbPass)
-	if err != nil {
-		log.Panic(err)
+	cfg, err := LoadConfig(os.Args[1:], os.LookupEnv)
+	if err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		log.Fatal(err)
 	}
-	if err := metrics.RunGlobalCollector(); err != nil {
-		log.Panic(err)
+	level := new(slog.LevelVar)
+	logger := mustCreateLogger(cfg, level)
+	slog.SetDefault(logger)
+	if cfg.AuthIssue != "" {
+		printAuthToken(cfg, logger)
+		return
 	}
+	promSink := NewPrometheusSink(logger.With("component", "prometheus"))
+	metrics := selectMetricsSink(cfg, promSink, logger.With("component", "metrics"))
 
 	ctx, cancel := context.WithCancel(context.Background())
-	stream := NewEventStream(*tile38Addr)
-	client := mustConnectTile38(*debugMode)
+	stream := NewEventStream(cfg.Tile38Addr, logger.With("component", "eventstream"))
+	client := mustConnectTile38(cfg.Tile38Addr, cfg.Tile38Connections, logger.With("component", "tile38"))
 	service := NewPlayerLocationService(client)
-	wsHandler := selectWsDriver(*wsdriver)
-	server := NewWSServer(wsHandler)
-	watcher := NewGameWatcher(stream, server)
-	onExit(func() {
-		cancel()
-		client.Close()
-		server.CloseAll()
-	})
+	profiles := NewPlayerProfileStore(client)
+	audit, err := NewFileAuditLog(cfg.AuditLogPath, cfg.AuditLogMaxSize, cfg.AuditLogBackups)
+	if err != nil {
+		fatal(logger, "error to open the audit log", err)
+	}
+	origins, err := ParseOrigins(cfg.WSAllowedOrigins)
+	if err != nil {
+		fatal(logger, "invalid ws-allowed-origins", err)
+	}
+	wsHandler := selectWsDriver(cfg.WSDriver, cfg.Heartbeat, AllowedOrigins(origins), logger.With("component", "wsdriver"))
+	queuePolicy, err := ParseSendPolicy(cfg.SendQueuePolicy)
+	if err != nil {
+		fatal(logger, "invalid ws-send-queue-policy", err)
+	}
+	server := NewWSServer(wsHandler, SendQueueConfig{Size: cfg.SendQueueSize, Policy: queuePolicy}, logger.With("component", "wsserver"))
+	var locks LockBackend = NewMemoryLock()
+	if cfg.ClusterRedisAddr != "" {
+		busClient := redis.NewClient(&redis.Options{Addr: cfg.ClusterRedisAddr, DialTimeout: 1 * time.Second})
+		defer busClient.Close()
+		err := server.JoinCluster(ctx, cfg.ClusterNode, NewRedisMessageBus(busClient, logger.With("component", "messagebus")),
+			NewRedisConnDirectory(busClient, logger.With("component", "conndirectory")))
+		if err != nil {
+			fatal(logger, "error to join cluster", err)
+		}
+		logger.Info("joined cluster", "node", cfg.ClusterNode)
+		locks = NewRedisLock(busClient)
+	}
+	interest := NewAreaOfInterest(server, service, cfg.InterestRadius, logger.With("component", "interest"))
+	positions := NewPositionUpdates(interest, cfg.PositionUpdates)
+	go positions.Run(ctx)
+	go reportServerStats(ctx, metrics, server, positions, logger.With("component", "metrics"))
+	gameMetrics := NewGameMetrics(metrics, cfg.ClusterNode, logger.With("component", "gamemetrics"))
+	go gameMetrics.Run(ctx, ServerMetricsInterval)
+	watcher := NewGameWatcher(stream, server, profiles, GameLease{Locks: locks, Owner: cfg.ClusterNode, TTL: cfg.GameLeaseTTL},
+		cfg.Game, gameMetrics, logger.With("component", "gamewatcher"))
 
 	go func() {
 		if err := watcher.WatchGamesForever(ctx); err != nil {
-			log.Panic("WatchGamesForever:error", err)
+			logger.Error("error to watch games", "error", err)
+			panic(err)
 		}
 	}()
 	go watcher.WatchCheckpoints(ctx)
+	if cfg.Zeroconf {
+		info := model.ServerInfo{Name: cfg.ZeroconfName, ProtocolVersion: CurrentProtocolVersion, TLS: cfg.TLSCert != "", WSPath: "/ws"}
+		zcServer, err := zconf.Register(cfg.ZeroconfName, ZeroconfService, "", cfg.Port, info.TXT(), nil)
+		if err != nil {
+			logger.Warn("zeroconf disabled", "error", err)
+		} else {
+			defer zcServer.Shutdown()
+			go NewZeroconfAdvertiser(zcServer, info, server, watcher).Run(ctx, cfg.ZeroconfRefresh)
+		}
+	}
 
-	eventH := NewEventHandler(server, service, watcher)
+	auth := selectAuthenticator(cfg, logger)
+	eventH := NewEventHandler(server, service, watcher, profiles, auth, audit, positions, interest,
+		logger.With("component", "eventhandler"))
 	http.Handle("/ws", recoverWrapper(eventH.Listen(ctx)))
-	http.Handle("/", http.FileServer(http.Dir(*webDir)))
+	NewHealthHandler(server, service, stream, watcher, metrics, auth).Register(http.DefaultServeMux)
+	http.Handle("/admin/log-level", NewLogLevelHandler(level, auth, logger))
+	if cfg.Prometheus {
+		http.Handle("/metrics", promSink)
+	}
+	http.Handle("/", http.FileServer(http.Dir(cfg.WebDir)))
+
+	httpServer := &http.Server{Addr: ":" + strconv.Itoa(cfg.Port)}
+	var redirectServer *http.Server
+	if cfg.TLSCert != "" {
+		certs, err := NewCertReloader(cfg.TLSCert, cfg.TLSKey, logger.With("component", "tls"))
+		if err != nil {
+			fatal(logger, "error to load tls certificate", err)
+		}
+		if cfg.TLSReloadInterval > 0 {
+			go certs.Watch(ctx, cfg.TLSReloadInterval)
+		}
+		httpServer.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate, MinVersion: tls.VersionTLS12}
+		if cfg.HTTPRedirectPort != 0 {
+			redirectServer = &http.Server{Addr: ":" + strconv.Itoa(cfg.HTTPRedirectPort), Handler: RedirectToHTTPS(cfg.Port)}
+			go func() {
+				logger.Info("redirecting to https", "port", cfg.HTTPRedirectPort)
+				if err := redirectServer.ListenAndServe(); err != http.ErrServerClosed {
+					fatal(logger, "error to serve https redirect", err)
+				}
+			}()
+		}
+	}
+	go func() {
+		var err error
+		if httpServer.TLSConfig != nil {
+			logger.Info("serving https", "port", cfg.Port)
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			logger.Info("serving http", "port", cfg.Port)
+			err = httpServer.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			fatal(logger, "error to serve", err)
+		}
+	}()
 
-	log.Println("Serving at localhost:", strconv.Itoa(*port), "...")
-	log.Fatal(http.ListenAndServe(":"+strconv.Itoa(*port), nil))
+	waitForExitSignal()
+	logger.Info("shutting down", "timeout", cfg.ShutdownTimeout)
+	shutdownCtx, done := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer done()
+	server.StopAccepting()
+	if err := watcher.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("games not finished on shutdown", "error", err)
+	}
+	if err := server.Shutdown(shutdownCtx, cfg.ShutdownHint); err != nil {
+		logger.Warn("connections not drained on shutdown", "error", err)
+	}
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("error to shutdown http server", "error", err)
+	}
+	if redirectServer != nil {
+		redirectServer.Close()
+	}
+	cancel()
+	metrics.Close()
+	client.Close()
+	audit.Close()
 }
 
-func selectWsDriver(name string) WSDriver {
+func selectWsDriver(name string, heartbeat WSHeartbeat, checkOrigin OriginChecker, logger *slog.Logger) WSDriver {
 	switch name {
 	case "gobwas":
-		return NewGobwasWSDriver()
+		return NewGobwasWSDriver(heartbeat, checkOrigin, logger)
 	default:
-		return NewXNetWSDriver()
+		return NewXNetWSDriver(heartbeat, checkOrigin, logger)
 	}
 }
 
-func mustConnectTile38(debug bool) *redis.Client {
-	client := redis.NewClient(&redis.Options{Addr: *tile38Addr, PoolSize: 1000, DialTimeout: 1 * time.Second})
-	if debug {
-		client.WrapProcess(tile38DebugWrapper)
+func selectMetricsSink(cfg *Config, promSink *PrometheusSink, logger *slog.Logger) MetricsSink {
+	var sinks []MetricsSink
+	if cfg.InfluxDBAddr != "" {
+		influx, err := NewMetricsCollector(cfg.InfluxDBAddr, cfg.InfluxDBName, cfg.InfluxDBUser, cfg.InfluxDBPass, cfg.InfluxBuffer, logger)
+		if err != nil {
+			logger.Warn("influxdb metrics disabled", "error", err)
+		} else {
+			if err := influx.RunGlobalCollector(); err != nil {
+				logger.Warn("influxdb go runtime metrics disabled", "error", err)
+			}
+			sinks = append(sinks, influx)
+		}
+	}
+	if cfg.Prometheus {
+		sinks = append(sinks, promSink)
+	}
+	if len(sinks) == 0 {
+		logger.Warn("no metrics backend configured, metrics are discarded")
+	}
+	return NewMetricsSink(sinks...)
+}
+
+func selectAuthenticator(cfg *Config, logger *slog.Logger) Authenticator {
+	if cfg.AuthSecret == "" {
+		role, err := ParseConnRole(cfg.AnonymousRole)
+		if err != nil {
+			fatal(logger, "invalid anonymous-role", err)
+		}
+		logger.Warn("auth-secret not set, /ws connections are not authenticated", "role", role)
+		return NoAuthenticator{Role: role}
+	}
+	return NewHMACAuthenticator(cfg.AuthSecret)
+}
+
+func printAuthToken(cfg *Config, logger *slog.Logger) {
+	if cfg.AuthSecret == "" {
+		logger.Error("-auth-issue requires -auth-secret")
+		os.Exit(1)
+	}
+	role, err := ParseConnRole(cfg.AuthIssueRole)
+	if err != nil {
+		fatal(logger, "invalid auth-issue-role", err)
+	}
+	now := time.Now()
+	claims := AuthClaims{Subject: cfg.AuthIssue, Role: string(role), IssuedAt: now.Unix(), ExpiresAt: now.Add(cfg.AuthTokenTTL).Unix()}
+	token, err := NewHMACAuthenticator(cfg.AuthSecret).Sign(claims)
+	if err != nil {
+		fatal(logger, "error to issue token", err)
 	}
+	fmt.Println(token)
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "localhost"
+	}
+	return name
+}
+
+// fatal logs err and exits, it is used while the server starts
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func mustCreateLogger(cfg *Config, level *slog.LevelVar) *slog.Logger {
+	initial, err := ParseLogLevel(cfg.LogLevel)
+	if err != nil {
+		log.Fatal("log-level: ", err)
+	}
+	level.Set(initial)
+	logger, err := NewLogger(os.Stderr, cfg.LogFormat, level)
+	if err != nil {
+		log.Fatal("log-format: ", err)
+	}
+	return logger
+}
 
+func mustConnectTile38(addr string, poolSize int, logger *slog.Logger) *redis.Client {
+	client := redis.NewClient(&redis.Options{Addr: addr, PoolSize: poolSize, DialTimeout: 1 * time.Second})
+	client.WrapProcess(tile38LogWrapper(logger))
 	return client
 }
 
-func tile38DebugWrapper(oldProcess func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
-	return func(cmd redis.Cmder) error {
-		log.Println("TILE38 DEBUG:", cmd.String())
-		return oldProcess(cmd)
+// tile38LogWrapper logs the tile38 commands when the log level is debug
+func tile38LogWrapper(logger *slog.Logger) func(oldProcess func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+	return func(oldProcess func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			if logger.Enabled(context.Background(), slog.LevelDebug) {
+				logger.Debug("tile38 command", "cmd", cmd.String())
+			}
+			return oldProcess(cmd)
+		}
 	}
 }
 
@@ -116,20 +287,15 @@ func withRecover(fn func() error) (err error) {
 		r := recover()
 		if r != nil {
 			err = fmt.Errorf("%v", r)
-			log.Printf("[panic withRecover] %v", err)
-			debug.PrintStack()
+			slog.Error("panic recovered", "error", err, "stack", string(debug.Stack()))
 		}
 	}()
 	return fn()
 }
 
-func onExit(fn func()) {
+func waitForExitSignal() {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, os.Kill)
-	go func() {
-		<-c
-		fn()
-		time.Sleep(2 * time.Second)
-		os.Exit(0)
-	}()
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+	signal.Stop(c)
 }
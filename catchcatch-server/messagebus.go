@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// BusMessage is a message sent to the connections held by other nodes
+type BusMessage struct {
+	// Origin is the node which published the message
+	Origin string `json:"origin"`
+	// To are the receiver connection ids, empty sends to every connection
+	To []string `json:"to,omitempty"`
+	// Room sends the envelope to the connections of the node in this room instead of To
+	Room string `json:"room,omitempty"`
+	// Handler delivers the envelope to the node handler registered with HandleBus instead of connections
+	Handler string `json:"handler,omitempty"`
+	// ConnOp is applied to the connections in To, or to every connection of the node when To is empty,
+	// it has no envelope
+	ConnOp ConnOp `json:"conn_op,omitempty"`
+	// RoomOp makes the connections in To join or leave Room, or closes it, it has no envelope
+	RoomOp RoomOp `json:"room_op,omitempty"`
+	// Envelope is the message encoded as protobuf.Envelope
+	Envelope []byte `json:"envelope"`
+}
+
+// RoomOp is an operation on the rooms of other nodes
+type RoomOp string
+
+// room operations sent over the bus
+const (
+	RoomOpJoin  RoomOp = "join"
+	RoomOpLeave RoomOp = "leave"
+	RoomOpClose RoomOp = "close"
+)
+
+// ConnOp is an operation on the connections of other nodes
+type ConnOp string
+
+// ConnOpDisconnect closes connections
+const ConnOpDisconnect ConnOp = "disconnect"
+
+// MessageBus delivers messages between the nodes of a cluster
+type MessageBus interface {
+	// Publish sends msg to node, empty node sends to every node
+	Publish(node string, msg *BusMessage) error
+	// Subscribe delivers the messages sent to node to fn until ctx is done
+	// it returns once the subscription is ready
+	Subscribe(ctx context.Context, node string, fn func(*BusMessage)) error
+}
+
+// ConnDirectory tells which node holds each connection
+type ConnDirectory interface {
+	// Join removes the connections left by a crashed run of node
+	// and keeps the connections of node alive until ctx is done
+	Join(ctx context.Context, node string) error
+	Register(connID, node string) error
+	// Unregister removes connID only when it is still held by node
+	Unregister(connID, node string) error
+	// Lookup returns the node of connID or ErrConnNotFound
+	Lookup(connID string) (string, error)
+}
+
+// LocalMessageBus is a MessageBus for nodes running in the same process
+type LocalMessageBus struct {
+	subscribers map[string]map[int]func(*BusMessage)
+	lastID      int
+	sync.RWMutex
+}
+
+// NewLocalMessageBus creates a LocalMessageBus
+func NewLocalMessageBus() *LocalMessageBus {
+	return &LocalMessageBus{subscribers: make(map[string]map[int]func(*BusMessage))}
+}
+
+// Publish implements MessageBus.Publish
+func (b *LocalMessageBus) Publish(node string, msg *BusMessage) error {
+	b.RLock()
+	defer b.RUnlock()
+	for name, subscribers := range b.subscribers {
+		if node != "" && node != name {
+			continue
+		}
+		for _, fn := range subscribers {
+			fn(msg)
+		}
+	}
+	return nil
+}
+
+// Subscribe implements MessageBus.Subscribe
+func (b *LocalMessageBus) Subscribe(ctx context.Context, node string, fn func(*BusMessage)) error {
+	b.Lock()
+	defer b.Unlock()
+	if _, exists := b.subscribers[node]; !exists {
+		b.subscribers[node] = make(map[int]func(*BusMessage))
+	}
+	b.lastID++
+	id := b.lastID
+	b.subscribers[node][id] = fn
+	go func() {
+		<-ctx.Done()
+		b.Lock()
+		defer b.Unlock()
+		delete(b.subscribers[node], id)
+	}()
+	return nil
+}
+
+// LocalConnDirectory is a ConnDirectory for nodes running in the same process
+type LocalConnDirectory struct {
+	nodes map[string]string
+	sync.RWMutex
+}
+
+// NewLocalConnDirectory creates a LocalConnDirectory
+func NewLocalConnDirectory() *LocalConnDirectory {
+	return &LocalConnDirectory{nodes: make(map[string]string)}
+}
+
+// Join implements ConnDirectory.Join
+func (d *LocalConnDirectory) Join(ctx context.Context, node string) error {
+	d.purge(node)
+	go func() {
+		<-ctx.Done()
+		d.purge(node)
+	}()
+	return nil
+}
+
+func (d *LocalConnDirectory) purge(node string) {
+	d.Lock()
+	defer d.Unlock()
+	for connID, current := range d.nodes {
+		if current == node {
+			delete(d.nodes, connID)
+		}
+	}
+}
+
+// Register implements ConnDirectory.Register
+func (d *LocalConnDirectory) Register(connID, node string) error {
+	d.Lock()
+	defer d.Unlock()
+	d.nodes[connID] = node
+	return nil
+}
+
+// Unregister implements ConnDirectory.Unregister
+func (d *LocalConnDirectory) Unregister(connID, node string) error {
+	d.Lock()
+	defer d.Unlock()
+	if d.nodes[connID] == node {
+		delete(d.nodes, connID)
+	}
+	return nil
+}
+
+// Lookup implements ConnDirectory.Lookup
+func (d *LocalConnDirectory) Lookup(connID string) (string, error) {
+	d.RLock()
+	defer d.RUnlock()
+	node, exists := d.nodes[connID]
+	if !exists {
+		return "", ErrConnNotFound
+	}
+	return node, nil
+}
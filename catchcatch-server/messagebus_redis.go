@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	redis "gopkg.in/redis.v5"
+)
+
+const (
+	redisBusChannel     = "catchcatch:bus:"
+	redisBusAllChannel  = redisBusChannel + "*all*"
+	redisConnectionsKey = "catchcatch:connections"
+	redisNodeKey        = "catchcatch:node:"
+	// redisNodeTTL is how long the connections of a crashed node stay in the directory
+	redisNodeTTL = 30 * time.Second
+)
+
+// the directory scripts compare and delete atomically
+// a connection is only found while its node key is alive
+const (
+	redisUnregisterScript = `if redis.call('HGET', KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return 0`
+	redisLookupScript = `local node = redis.call('HGET', KEYS[1], ARGV[1])
+if not node then
+	return false
+end
+if redis.call('EXISTS', ARGV[2] .. node) == 0 then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+	return false
+end
+return node`
+	redisPurgeScript = `local entries = redis.call('HGETALL', KEYS[1])
+local purged = 0
+for i = 1, #entries, 2 do
+	if entries[i + 1] == ARGV[1] then
+		purged = purged + redis.call('HDEL', KEYS[1], entries[i])
+	end
+end
+return purged`
+)
+
+var (
+	unregisterScript = redis.NewScript(redisUnregisterScript)
+	lookupScript     = redis.NewScript(redisLookupScript)
+	purgeScript      = redis.NewScript(redisPurgeScript)
+)
+
+// RedisMessageBus is a MessageBus over redis pub/sub
+// every node subscribes to its own channel and to the broadcast channel
+type RedisMessageBus struct {
+	client *redis.Client
+	logger *slog.Logger
+}
+
+// NewRedisMessageBus creates a RedisMessageBus
+func NewRedisMessageBus(client *redis.Client, logger *slog.Logger) *RedisMessageBus {
+	return &RedisMessageBus{client, loggerOrDefault(logger)}
+}
+
+// Publish implements MessageBus.Publish
+func (b *RedisMessageBus) Publish(node string, msg *BusMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	channel := redisBusAllChannel
+	if node != "" {
+		channel = redisBusChannel + node
+	}
+	return b.client.Publish(channel, string(payload)).Err()
+}
+
+// Subscribe implements MessageBus.Subscribe
+func (b *RedisMessageBus) Subscribe(ctx context.Context, node string, fn func(*BusMessage)) error {
+	channels := []string{redisBusChannel + node, redisBusAllChannel}
+	pubsub, err := b.client.Subscribe(channels...)
+	if err != nil {
+		return err
+	}
+	for range channels {
+		if _, err := pubsub.Receive(); err != nil {
+			pubsub.Close()
+			return err
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		pubsub.Close()
+	}()
+	go func() {
+		for {
+			received, err := pubsub.ReceiveMessage()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				b.logger.Error("error to receive bus message", "node", node, "error", err)
+				continue
+			}
+			msg := &BusMessage{}
+			if err := json.Unmarshal([]byte(received.Payload), msg); err != nil {
+				b.logger.Warn("invalid bus message", "node", node, "error", err)
+				continue
+			}
+			fn(msg)
+		}
+	}()
+	return nil
+}
+
+// RedisConnDirectory is a ConnDirectory stored in a redis hash
+// every node refreshes its node key, the connections of nodes without it are ignored
+type RedisConnDirectory struct {
+	client *redis.Client
+	ttl    time.Duration
+	logger *slog.Logger
+}
+
+// NewRedisConnDirectory creates a RedisConnDirectory
+func NewRedisConnDirectory(client *redis.Client, logger *slog.Logger) *RedisConnDirectory {
+	return &RedisConnDirectory{client, redisNodeTTL, loggerOrDefault(logger)}
+}
+
+// Join implements ConnDirectory.Join
+func (d *RedisConnDirectory) Join(ctx context.Context, node string) error {
+	if err := d.purge(node); err != nil {
+		return err
+	}
+	if err := d.client.Set(redisNodeKey+node, time.Now().Unix(), d.ttl).Err(); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(d.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				d.client.Del(redisNodeKey + node)
+				d.purge(node)
+				return
+			case <-ticker.C:
+				if err := d.client.Set(redisNodeKey+node, time.Now().Unix(), d.ttl).Err(); err != nil {
+					d.logger.Error("error to refresh node key", "node", node, "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (d *RedisConnDirectory) purge(node string) error {
+	return purgeScript.Run(d.client, []string{redisConnectionsKey}, node).Err()
+}
+
+// Register implements ConnDirectory.Register
+func (d *RedisConnDirectory) Register(connID, node string) error {
+	return d.client.HSet(redisConnectionsKey, connID, node).Err()
+}
+
+// Unregister implements ConnDirectory.Unregister
+func (d *RedisConnDirectory) Unregister(connID, node string) error {
+	return unregisterScript.Run(d.client, []string{redisConnectionsKey}, connID, node).Err()
+}
+
+// Lookup implements ConnDirectory.Lookup
+func (d *RedisConnDirectory) Lookup(connID string) (string, error) {
+	node, err := lookupScript.Run(d.client, []string{redisConnectionsKey}, connID, redisNodeKey).Result()
+	if err == redis.Nil {
+		return "", ErrConnNotFound
+	} else if err != nil {
+		return "", err
+	}
+	return node.(string), nil
+}
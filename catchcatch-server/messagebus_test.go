@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+	redis "gopkg.in/redis.v5"
+)
+
+func TestWSServerRoutesMessagesThroughTheBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus, directory := NewLocalMessageBus(), NewLocalConnDirectory()
+	nodeA, nodeB := NewWSServer(nil, SendQueueConfig{}, nil), NewWSServer(nil, SendQueueConfig{}, nil)
+	nodeA.JoinCluster(ctx, "a", bus, directory)
+	nodeB.JoinCluster(ctx, "b", bus, directory)
+
+	connA, connB := &fakeWSConn{}, &fakeWSConn{}
+	nodeA.Rename(nodeA.Add(connA), "p1")
+	nodeB.Rename(nodeB.Add(connB), "p2")
+
+	if err := nodeA.Emit("p2", &protobuf.Simple{EventName: proto.String("game:loose")}); err != nil {
+		t.Fatal(err)
+	}
+	nodeA.BroadcastTo([]string{"p1", "p2"}, &protobuf.Simple{EventName: proto.String("game:finish")})
+	nodeA.Broadcast(&protobuf.Simple{EventName: proto.String("admin:feature:added")})
+	if len(connA.sent) != 2 || len(connB.sent) != 3 {
+		t.Fatal("unexpected messages:", len(connA.sent), len(connB.sent))
+	}
+	msg := &protobuf.Simple{}
+	proto.Unmarshal(connB.sent[0], msg)
+	if msg.GetEventName() != "game:loose" {
+		t.Fatal("unexpected message:", msg)
+	}
+
+	nodeB.Remove("p2")
+	if err := nodeA.Emit("p2", &protobuf.Simple{EventName: proto.String("game:loose")}); err != ErrConnNotFound {
+		t.Fatal("expected ErrConnNotFound, got:", err)
+	}
+}
+
+func TestRedisMessageBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startFakeRedis(t)
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	bus := NewRedisMessageBus(client, nil)
+
+	received := make(chan *BusMessage, 2)
+	if err := bus.Subscribe(ctx, "b", func(msg *BusMessage) { received <- msg }); err != nil {
+		t.Fatal(err)
+	}
+	bus.Publish("a", &BusMessage{Origin: "b", To: []string{"nobody"}})
+	bus.Publish("b", &BusMessage{Origin: "a", To: []string{"p2"}})
+	bus.Publish("", &BusMessage{Origin: "a", Envelope: []byte("all")})
+
+	for _, expected := range []string{"p2", "all"} {
+		select {
+		case msg := <-received:
+			if got := strings.Join(msg.To, "") + string(msg.Envelope); got != expected {
+				t.Fatal("expected", expected, "got:", msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected message", expected)
+		}
+	}
+}
+
+func TestRedisConnDirectory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := redis.NewClient(&redis.Options{Addr: startFakeRedis(t)})
+	defer client.Close()
+	directory := NewRedisConnDirectory(client, nil)
+	directory.Join(ctx, "a")
+	directory.Join(ctx, "b")
+
+	directory.Register("p1", "a")
+	directory.Register("p1", "b")
+	directory.Unregister("p1", "a")
+	if node, err := directory.Lookup("p1"); err != nil || node != "b" {
+		t.Fatal("expected p1 on node b, got:", node, err)
+	}
+	directory.Unregister("p1", "b")
+	if _, err := directory.Lookup("p1"); err != ErrConnNotFound {
+		t.Fatal("expected ErrConnNotFound, got:", err)
+	}
+}
+
+func TestRedisConnDirectoryForgetsCrashedNodes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := redis.NewClient(&redis.Options{Addr: startFakeRedis(t)})
+	defer client.Close()
+	directory := NewRedisConnDirectory(client, nil)
+	directory.Join(ctx, "a")
+	directory.Register("p1", "a")
+	directory.Register("p2", "a")
+
+	// the node key expires when the node stops refreshing it
+	client.Del(redisNodeKey + "a")
+	if _, err := directory.Lookup("p1"); err != ErrConnNotFound {
+		t.Fatal("expected connections of a crashed node to be gone, got:", err)
+	}
+
+	directory.Join(ctx, "a")
+	if _, err := directory.Lookup("p2"); err != ErrConnNotFound {
+		t.Fatal("expected a rejoining node to purge its old connections, got:", err)
+	}
+}
+
+func TestWSServerRoomsReachOtherNodes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus, directory := NewLocalMessageBus(), NewLocalConnDirectory()
+	nodeA, nodeB := NewWSServer(nil, SendQueueConfig{}, nil), NewWSServer(nil, SendQueueConfig{}, nil)
+	nodeA.JoinCluster(ctx, "a", bus, directory)
+	nodeB.JoinCluster(ctx, "b", bus, directory)
+
+	connA, connB := &fakeWSConn{}, &fakeWSConn{}
+	nodeA.Rename(nodeA.Add(connA), "p1")
+	nodeB.Rename(nodeB.Add(connB), "p2")
+
+	room := GameRoom("g1")
+	if err := nodeA.Join(room, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodeA.Join(room, "p2"); err != nil {
+		t.Fatal(err)
+	}
+	nodeA.BroadcastToRoom(room, &protobuf.Simple{EventName: proto.String("game:finish")})
+	if len(connA.sent) != 1 || len(connB.sent) != 1 {
+		t.Fatal("expected every room member to receive the message:", len(connA.sent), len(connB.sent))
+	}
+
+	nodeA.Leave(room, "p2")
+	nodeA.BroadcastToRoom(room, &protobuf.Simple{EventName: proto.String("game:finish")})
+	if len(connA.sent) != 2 || len(connB.sent) != 1 {
+		t.Fatal("expected the remote member to leave the room:", len(connA.sent), len(connB.sent))
+	}
+
+	nodeA.Join(room, "p2")
+	nodeA.CloseRoom(room)
+	if members := nodeB.RoomMembers(room); len(members) != 0 {
+		t.Fatal("expected the room to be closed on every node, got:", members)
+	}
+}
+
+// startFakeRedis serves the redis commands used by the cluster bus, the leases and admin:clear
+func startFakeRedis(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	server := &fakeRedis{strings: make(map[string]string), hashes: make(map[string]map[string]string), channels: make(map[string][]*fakeRedisConn)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(&fakeRedisConn{Conn: conn})
+		}
+	}()
+	return listener.Addr().String()
+}
+
+type fakeRedis struct {
+	strings  map[string]string
+	hashes   map[string]map[string]string
+	channels map[string][]*fakeRedisConn
+	sync.Mutex
+}
+
+type fakeRedisConn struct {
+	net.Conn
+	sync.Mutex
+}
+
+func (c *fakeRedisConn) reply(format string, args ...interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	fmt.Fprintf(c, format, args...)
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func (r *fakeRedis) serve(conn *fakeRedisConn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		cmd, err := readRESPCommand(reader)
+		if err != nil {
+			return
+		}
+		r.Lock()
+		switch strings.ToUpper(cmd[0]) {
+		case "PING":
+			conn.reply("*2\r\n%s%s", bulk("pong"), bulk(""))
+		case "HSET":
+			if r.hashes[cmd[1]] == nil {
+				r.hashes[cmd[1]] = make(map[string]string)
+			}
+			r.hashes[cmd[1]][cmd[2]] = cmd[3]
+			conn.reply(":1\r\n")
+		case "HGET":
+			if value, exists := r.hashes[cmd[1]][cmd[2]]; exists {
+				conn.reply("%s", bulk(value))
+			} else {
+				conn.reply("$-1\r\n")
+			}
+		case "HDEL":
+			delete(r.hashes[cmd[1]], cmd[2])
+			conn.reply(":1\r\n")
+		case "SET":
+			r.strings[cmd[1]] = cmd[2]
+			conn.reply("+OK\r\n")
+		case "DEL":
+			delete(r.strings, cmd[1])
+			conn.reply(":1\r\n")
+		case "KEYS":
+			keys := make([]string, 0, len(r.hashes)+len(r.strings))
+			for key := range r.hashes {
+				keys = append(keys, key)
+			}
+			for key := range r.strings {
+				keys = append(keys, key)
+			}
+			reply := "*" + strconv.Itoa(len(keys)) + "\r\n"
+			for _, key := range keys {
+				reply += bulk(key)
+			}
+			conn.reply("%s", reply)
+		case "DROP":
+			delete(r.hashes, cmd[1])
+			conn.reply(":1\r\n")
+		case "EVALSHA":
+			conn.reply("-NOSCRIPT No matching script\r\n")
+		case "EVAL":
+			conn.reply("%s", r.eval(cmd[1], cmd[3], cmd[4:]))
+		case "SUBSCRIBE":
+			for i, channel := range cmd[1:] {
+				r.channels[channel] = append(r.channels[channel], conn)
+				conn.reply("*3\r\n%s%s:%d\r\n", bulk("subscribe"), bulk(channel), i+1)
+			}
+		case "PUBLISH":
+			for _, subscriber := range r.channels[cmd[1]] {
+				subscriber.reply("*3\r\n%s%s%s", bulk("message"), bulk(cmd[1]), bulk(cmd[2]))
+			}
+			conn.reply(":%d\r\n", len(r.channels[cmd[1]]))
+		default:
+			conn.reply("-ERR unknown command\r\n")
+		}
+		r.Unlock()
+	}
+}
+
+// eval runs the directory and lease scripts, which are known by their source
+func (r *fakeRedis) eval(script, key string, args []string) string {
+	hash := r.hashes[key]
+	switch script {
+	case redisUnregisterScript:
+		if node, exists := hash[args[0]]; exists && node == args[1] {
+			delete(hash, args[0])
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case redisLookupScript:
+		node, exists := hash[args[0]]
+		if !exists {
+			return "$-1\r\n"
+		} else if _, alive := r.strings[args[1]+node]; !alive {
+			delete(hash, args[0])
+			return "$-1\r\n"
+		}
+		return bulk(node)
+	case redisAcquireScript:
+		if current, exists := r.strings[key]; exists && current != args[0] {
+			return ":0\r\n"
+		}
+		r.strings[key] = args[0]
+		return ":1\r\n"
+	case redisReleaseScript:
+		if current, exists := r.strings[key]; exists && current == args[0] {
+			delete(r.strings, key)
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case redisPurgeScript:
+		purged := 0
+		for connID, node := range hash {
+			if node == args[0] {
+				delete(hash, connID)
+				purged++
+			}
+		}
+		return ":" + strconv.Itoa(purged) + "\r\n"
+	}
+	return "-ERR unknown script\r\n"
+}
+
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestWSServerDisconnectsConnectionsOfOtherNodes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus, directory := NewLocalMessageBus(), NewLocalConnDirectory()
+	nodeA, nodeB := NewWSServer(nil, SendQueueConfig{}, nil), NewWSServer(nil, SendQueueConfig{}, nil)
+	nodeA.JoinCluster(ctx, "a", bus, directory)
+	nodeB.JoinCluster(ctx, "b", bus, directory)
+	nodeB.Rename(nodeB.Add(&fakeWSConn{}), "p2")
+	nodeB.Rename(nodeB.Add(&fakeWSConn{}), "p3")
+	p3 := nodeB.Get("p3")
+
+	if err := nodeA.Disconnect("p2"); err != nil {
+		t.Fatal(err)
+	}
+	if nodeB.Get("p2") != nil {
+		t.Fatal("expected p2 to be disconnected from node b")
+	}
+	if err := nodeA.Disconnect("p2"); err != ErrConnNotFound {
+		t.Fatal("expected ErrConnNotFound, got:", err)
+	}
+
+	nodeA.DisconnectAll()
+	select {
+	case <-p3.closed:
+	default:
+		t.Fatal("expected every connection of node b to be closed")
+	}
+}
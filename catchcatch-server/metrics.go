@@ -1,7 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/tevjef/go-runtime-metrics/expvar"
@@ -13,56 +19,245 @@ import (
 // MetricsTimeout is the default timeout for metrics
 const MetricsTimeout = time.Second
 
+// ServerMetricsInterval is the interval to report the WS server metrics
+const ServerMetricsInterval = 10 * time.Second
+
+// ErrMetricsClosed happens when a point is notified after the collector is closed
+var ErrMetricsClosed = errors.New("metrics collector closed")
+
 // Tags to be sent to metrics
 type Tags map[string]string
 
 // Values to be sent to metrics
 type Values map[string]interface{}
 
-// MetricsCollector is a service to collect metrics
+// Counter is a value which only grows, sinks which tell counters from gauges export it as a counter
+type Counter int64
+
+// MetricsBufferConfig configures how MetricsCollector buffers and writes points
+type MetricsBufferConfig struct {
+	// Size is the max number of points waiting to be written, new points are dropped when it is full
+	Size int
+	// BatchSize is the number of points which triggers a write before FlushInterval
+	BatchSize int
+	// FlushInterval is the max time a point waits to be written
+	FlushInterval time.Duration
+	// MaxRetries is the number of times a failed write is retried before its points are discarded
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, it doubles at each retry
+	RetryBackoff time.Duration
+}
+
+// DefaultMetricsBuffer is the buffer used by the server
+var DefaultMetricsBuffer = MetricsBufferConfig{Size: 10000, BatchSize: 500, FlushInterval: time.Second,
+	MaxRetries: 3, RetryBackoff: 100 * time.Millisecond}
+
+// MetricsStats are the MetricsCollector counters
+type MetricsStats struct {
+	// Written is the number of points written to influxdb
+	Written int64 `json:"written"`
+	// Dropped is the number of points discarded because the buffer was full
+	Dropped int64 `json:"dropped"`
+	// Failed is the number of points discarded after the write retries
+	Failed int64 `json:"failed"`
+}
+
+// MetricsCollector is a MetricsSink which writes to InfluxDB
+// points are buffered and written in batches in background
 type MetricsCollector struct {
 	addr     string
 	db       string
 	username string
 	password string
 	client   influxdb.Client
+
+	buffer MetricsBufferConfig
+	points chan *influxdb.Point
+	done   chan struct{}
+	closed bool
+	stats  MetricsStats
+	logger *slog.Logger
+	sync.RWMutex
 }
 
-// NewMetricsCollector build the MetricsCollector
-func NewMetricsCollector(addr, db, username, password string) (*MetricsCollector, error) {
+// NewMetricsCollector build the MetricsCollector and starts writing its points
+func NewMetricsCollector(addr, db, username, password string, buffer MetricsBufferConfig, logger *slog.Logger) (*MetricsCollector, error) {
 	client, err := influxdb.NewHTTPClient(influxdb.HTTPConfig{
 		Addr:     addr,
 		Username: username,
 		Password: password,
 		Timeout:  MetricsTimeout,
 	})
-	return &MetricsCollector{addr, db, username, password, client}, err
+	if err != nil {
+		return nil, err
+	}
+	if buffer.BatchSize <= 0 {
+		buffer.BatchSize = 1
+	}
+	if buffer.FlushInterval <= 0 {
+		buffer.FlushInterval = DefaultMetricsBuffer.FlushInterval
+	}
+	c := &MetricsCollector{addr: addr, db: db, username: username, password: password, client: client,
+		buffer: buffer, points: make(chan *influxdb.Point, buffer.Size), done: make(chan struct{}), logger: loggerOrDefault(logger)}
+	go c.run()
+	return c, nil
 }
 
 // Ping check if the server is responsible
-func (c MetricsCollector) Ping() error {
+func (c *MetricsCollector) Ping() error {
 	_, _, err := c.client.Ping(MetricsTimeout)
 	return err
 }
 
 // Notify register metrics
-func (c MetricsCollector) Notify(measurement string, tags Tags, values Values) error {
-	// r, err := c.client.Query(influxdb.Query{Command: "CREATE DATABASE " + c.db})
-	// log.Println("R:", r, "err:", err)
-	bp, err := influxdb.NewBatchPoints(
-		influxdb.BatchPointsConfig{Database: c.db, Precision: "ms"})
+// the point is buffered to be written later, it is dropped when the buffer is full
+func (c *MetricsCollector) Notify(measurement string, tags Tags, values Values) error {
+	point, err := influxdb.NewPoint(measurement, tags, influxValues(values), time.Now())
 	if err != nil {
 		return err
 	}
-	point, err := influxdb.NewPoint(measurement, tags, values, time.Now())
+	c.RLock()
+	defer c.RUnlock()
+	if c.closed {
+		return ErrMetricsClosed
+	}
+	select {
+	case c.points <- point:
+	default:
+		atomic.AddInt64(&c.stats.Dropped, 1)
+	}
+	return nil
+}
+
+// influxValues replaces the counters by their values, influxdb has no value types
+func influxValues(values Values) Values {
+	fields := make(Values, len(values))
+	for k, v := range values {
+		if c, ok := v.(Counter); ok {
+			v = int64(c)
+		}
+		fields[k] = v
+	}
+	return fields
+}
+
+// Stats returns the written, dropped and failed points counters
+func (c *MetricsCollector) Stats() MetricsStats {
+	return MetricsStats{
+		Written: atomic.LoadInt64(&c.stats.Written),
+		Dropped: atomic.LoadInt64(&c.stats.Dropped),
+		Failed:  atomic.LoadInt64(&c.stats.Failed),
+	}
+}
+
+func (c *MetricsCollector) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.buffer.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]*influxdb.Point, 0, c.buffer.BatchSize)
+	for {
+		select {
+		case point, ok := <-c.points:
+			if !ok {
+				c.write(batch)
+				return
+			}
+			batch = append(batch, point)
+			if len(batch) >= c.buffer.BatchSize {
+				c.write(batch)
+				batch = make([]*influxdb.Point, 0, c.buffer.BatchSize)
+			}
+		case <-ticker.C:
+			c.write(batch)
+			batch = make([]*influxdb.Point, 0, c.buffer.BatchSize)
+		}
+	}
+}
+
+func (c *MetricsCollector) write(points []*influxdb.Point) {
+	if len(points) == 0 {
+		return
+	}
+	bp, err := influxdb.NewBatchPoints(influxdb.BatchPointsConfig{Database: c.db, Precision: "ms"})
 	if err != nil {
-		return err
+		c.logger.Error("error to create metrics batch", "error", err)
+		return
+	}
+	for _, p := range points {
+		bp.AddPoint(p)
+	}
+	backoff := c.buffer.RetryBackoff
+	for retry := 0; ; retry++ {
+		err := c.client.Write(bp)
+		if err == nil {
+			atomic.AddInt64(&c.stats.Written, int64(len(points)))
+			return
+		}
+		if retry >= c.buffer.MaxRetries {
+			atomic.AddInt64(&c.stats.Failed, int64(len(points)))
+			c.logger.Error("error to write metrics points", "points", len(points), "retries", retry, "error", err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
 	}
-	bp.AddPoint(point)
-	return c.client.Write(bp)
+}
+
+// Close writes the buffered points and closes the metrics client
+func (c *MetricsCollector) Close() error {
+	c.Lock()
+	if c.closed {
+		c.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.points)
+	c.Unlock()
+	<-c.done
+	return c.client.Close()
 }
 
 // RunGlobalCollector collects server go metrics
-func (c MetricsCollector) RunGlobalCollector() error {
+func (c *MetricsCollector) RunGlobalCollector() error {
 	return metrics.RunCollector(&metrics.Config{Database: c.db, Host: strings.Replace(c.addr, "http://", "", 1)})
 }
+
+func reportServerStats(ctx context.Context, c MetricsSink, server *WSServer, positions *PositionUpdates, logger *slog.Logger) {
+	logger = loggerOrDefault(logger)
+	host, _ := os.Hostname()
+	ticker := time.NewTicker(ServerMetricsInterval)
+	defer ticker.Stop()
+	lastAccepted, lastReport := positions.Stats().Accepted, time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			conns := server.ConnectionStats()
+			err := c.Notify("connections", Tags{"host": host},
+				Values{"open": conns.Open(), "opened": Counter(conns.Opened), "closed": Counter(conns.Closed)})
+			if err != nil {
+				logger.Error("error to notify metrics", "measurement", "connections", "error", err)
+			}
+			stats := server.SendQueueStats()
+			err = c.Notify("ws_send_queue", Tags{"host": host},
+				Values{"depth": stats.Depth, "dropped": Counter(stats.Dropped), "disconnected": Counter(stats.Disconnected)})
+			if err != nil {
+				logger.Error("error to notify metrics", "measurement", "ws_send_queue", "error", err)
+			}
+			updates := positions.Stats()
+			err = c.Notify("position_updates", Tags{"host": host},
+				Values{"accepted": Counter(updates.Accepted), "throttled": Counter(updates.Throttled),
+					"coalesced": Counter(updates.Coalesced), "batches": Counter(updates.Batches)})
+			if err != nil {
+				logger.Error("error to notify metrics", "measurement", "position_updates", "error", err)
+			}
+			perSecond := float64(updates.Accepted-lastAccepted) / now.Sub(lastReport).Seconds()
+			lastAccepted, lastReport = updates.Accepted, now
+			err = c.Notify("player_updates", Tags{"host": host}, Values{"per_second": perSecond})
+			if err != nil {
+				logger.Error("error to notify metrics", "measurement", "player_updates", "error", err)
+			}
+		}
+	}
+}
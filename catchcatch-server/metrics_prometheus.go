@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PrometheusNamespace prefixes every metric exported by PrometheusSink
+const PrometheusNamespace = "catchcatch"
+
+var invalidPrometheusChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// PrometheusSink keeps the last value of each measurement to be scraped at /metrics
+// every value field of a measurement is exported as a gauge named <namespace>_<measurement>_<field>,
+// Counter fields are exported as counters named <namespace>_<measurement>_<field>_total
+type PrometheusSink struct {
+	series   map[string]map[string]float64
+	counters map[string]bool
+	logger   *slog.Logger
+	sync.Mutex
+}
+
+// NewPrometheusSink creates a PrometheusSink
+func NewPrometheusSink(logger *slog.Logger) *PrometheusSink {
+	return &PrometheusSink{series: make(map[string]map[string]float64), counters: make(map[string]bool),
+		logger: loggerOrDefault(logger)}
+}
+
+// Notify implements MetricsSink.Notify
+// values which are not numbers or booleans are ignored
+func (s *PrometheusSink) Notify(measurement string, tags Tags, values Values) error {
+	labels := prometheusLabels(tags)
+	s.Lock()
+	defer s.Unlock()
+	for field, value := range values {
+		v, ok := prometheusValue(value)
+		if !ok {
+			continue
+		}
+		name := prometheusName(PrometheusNamespace, measurement, field)
+		if _, counter := value.(Counter); counter {
+			name += "_total"
+			s.counters[name] = true
+		}
+		if s.series[name] == nil {
+			s.series[name] = make(map[string]float64)
+		}
+		s.series[name][labels] = v
+	}
+	return nil
+}
+
+// Ping implements MetricsSink.Ping
+func (s *PrometheusSink) Ping() error { return nil }
+
+// Close implements MetricsSink.Close
+func (s *PrometheusSink) Close() error { return nil }
+
+// ServeHTTP writes the metrics in the prometheus text format
+func (s *PrometheusSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	out := bufio.NewWriter(w)
+	writeRuntimeGauges(out)
+
+	s.Lock()
+	names := make([]string, 0, len(s.series))
+	for name := range s.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		series := s.series[name]
+		labels := make([]string, 0, len(series))
+		for l := range series {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		metricType := "gauge"
+		if s.counters[name] {
+			metricType = "counter"
+		}
+		fmt.Fprintf(out, "# TYPE %s %s\n", name, metricType)
+		for _, l := range labels {
+			fmt.Fprintf(out, "%s%s %v\n", name, l, series[l])
+		}
+	}
+	s.Unlock()
+
+	if err := out.Flush(); err != nil {
+		s.logger.Error("error to write prometheus metrics", "error", err)
+	}
+}
+
+func writeRuntimeGauges(out *bufio.Writer) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	gauges := []struct {
+		name  string
+		value float64
+	}{
+		{"go_goroutines", float64(runtime.NumGoroutine())},
+		{"go_memstats_alloc_bytes", float64(mem.Alloc)},
+		{"go_memstats_heap_objects", float64(mem.HeapObjects)},
+		{"go_memstats_sys_bytes", float64(mem.Sys)},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(out, "# TYPE %s gauge\n%s %v\n", g.name, g.name, g.value)
+	}
+	fmt.Fprintf(out, "# TYPE go_gc_count_total counter\ngo_gc_count_total %v\n", mem.NumGC)
+}
+
+func prometheusName(parts ...string) string {
+	return invalidPrometheusChars.ReplaceAllString(strings.Join(parts, "_"), "_")
+}
+
+func prometheusLabels(tags Tags) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", prometheusName(k), v))
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func prometheusValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case Counter:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,61 @@
+package main
+
+// MetricsSink receives the server measurements
+type MetricsSink interface {
+	Notify(measurement string, tags Tags, values Values) error
+	// Ping checks if the backend is reachable
+	Ping() error
+	Close() error
+}
+
+// NoopMetrics discards every measurement, used when no metrics backend is configured
+type NoopMetrics struct{}
+
+// Notify implements MetricsSink.Notify
+func (NoopMetrics) Notify(measurement string, tags Tags, values Values) error { return nil }
+
+// Ping implements MetricsSink.Ping
+func (NoopMetrics) Ping() error { return nil }
+
+// Close implements MetricsSink.Close
+func (NoopMetrics) Close() error { return nil }
+
+// MultiMetricsSink sends the measurements to every sink
+// errors don't stop the other sinks, the last one is returned
+type MultiMetricsSink []MetricsSink
+
+// NewMetricsSink combines sinks, no sink means NoopMetrics
+func NewMetricsSink(sinks ...MetricsSink) MetricsSink {
+	switch len(sinks) {
+	case 0:
+		return NoopMetrics{}
+	case 1:
+		return sinks[0]
+	}
+	return MultiMetricsSink(sinks)
+}
+
+// Notify implements MetricsSink.Notify
+func (m MultiMetricsSink) Notify(measurement string, tags Tags, values Values) error {
+	return m.each(func(s MetricsSink) error { return s.Notify(measurement, tags, values) })
+}
+
+// Ping implements MetricsSink.Ping
+func (m MultiMetricsSink) Ping() error {
+	return m.each(MetricsSink.Ping)
+}
+
+// Close implements MetricsSink.Close
+func (m MultiMetricsSink) Close() error {
+	return m.each(MetricsSink.Close)
+}
+
+func (m MultiMetricsSink) each(fn func(MetricsSink) error) error {
+	var lastErr error
+	for _, s := range m {
+		if err := fn(s); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
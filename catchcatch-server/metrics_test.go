@@ -1,15 +1,64 @@
 package main
 
 import (
+	"bufio"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+// fakeInfluxDB records the lines written to /write, failing the first failures writes
+type fakeInfluxDB struct {
+	*httptest.Server
+	lines    []string
+	writes   int
+	failures int
+	block    chan struct{}
+	sync.Mutex
+}
+
+func startFakeInfluxDB(failures int) *fakeInfluxDB {
+	db := &fakeInfluxDB{failures: failures}
+	db.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/write" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if db.block != nil {
+			<-db.block
+		}
+		db.Lock()
+		defer db.Unlock()
+		db.writes++
+		if db.writes <= db.failures {
+			http.Error(w, `{"error":"unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			db.lines = append(db.lines, scanner.Text())
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return db
+}
+
+func (db *fakeInfluxDB) written() ([]string, int) {
+	db.Lock()
+	defer db.Unlock()
+	return append([]string{}, db.lines...), db.writes
+}
+
 func TestNotify(t *testing.T) {
-	m, err := NewMetricsCollector("http://localhost:8086", "catchcatch", "", "")
+	m, err := NewMetricsCollector("http://localhost:8086", "catchcatch", "", "", DefaultMetricsBuffer, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer m.Close()
 	if err := m.Ping(); err != nil {
 		t.Skip(err)
 	}
@@ -25,3 +74,120 @@ func TestNotify(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestMetricsCollectorWritesBatches(t *testing.T) {
+	db := startFakeInfluxDB(0)
+	defer db.Close()
+	m, err := NewMetricsCollector(db.URL, "catchcatch", "", "",
+		MetricsBufferConfig{Size: 10, BatchSize: 2, FlushInterval: 50 * time.Millisecond}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	m.Notify("games", Tags{"geofence": "g1"}, Values{"created": 1})
+	m.Notify("games", Tags{"geofence": "g2"}, Values{"created": 1})
+	waitFor(t, "the full batch to be written", func() bool {
+		_, writes := db.written()
+		return writes == 1
+	})
+	m.Notify("games", Tags{"geofence": "g3"}, Values{"created": Counter(1)})
+	waitFor(t, "the interval flush", func() bool {
+		lines, _ := db.written()
+		return len(lines) == 3
+	})
+	lines, writes := db.written()
+	if writes != 2 || !strings.HasPrefix(lines[2], "games,geofence=g3 created=1i") {
+		t.Fatal("unexpected writes:", writes, lines)
+	}
+	if stats := m.Stats(); stats.Written != 3 {
+		t.Fatal("unexpected stats:", stats)
+	}
+}
+
+func TestMetricsCollectorRetriesFailedWrites(t *testing.T) {
+	db := startFakeInfluxDB(2)
+	defer db.Close()
+	m, err := NewMetricsCollector(db.URL, "catchcatch", "", "",
+		MetricsBufferConfig{Size: 10, BatchSize: 1, FlushInterval: time.Second, MaxRetries: 2, RetryBackoff: time.Millisecond}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Notify("games", nil, Values{"created": 1})
+	m.Close()
+
+	lines, writes := db.written()
+	if writes != 3 || len(lines) != 1 {
+		t.Fatal("expected the point to be written at the third attempt, got:", writes, lines)
+	}
+	if stats := m.Stats(); stats.Written != 1 || stats.Failed != 0 {
+		t.Fatal("unexpected stats:", stats)
+	}
+}
+
+func TestMetricsCollectorDropsWhenFullAndFlushesOnClose(t *testing.T) {
+	db := startFakeInfluxDB(0)
+	db.block = make(chan struct{})
+	defer db.Close()
+	m, err := NewMetricsCollector(db.URL, "catchcatch", "", "",
+		MetricsBufferConfig{Size: 2, BatchSize: 1, FlushInterval: time.Second}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Notify("games", nil, Values{"created": 1})
+	waitFor(t, "the first write to block", func() bool { return len(m.points) == 0 })
+	for i := 0; i < 4; i++ {
+		if err := m.Notify("games", nil, Values{"created": 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := m.Stats(); stats.Dropped != 2 {
+		t.Fatal("expected the points over the buffer size to be dropped, got:", stats)
+	}
+
+	close(db.block)
+	m.Close()
+	if lines, _ := db.written(); len(lines) != 3 {
+		t.Fatal("expected the buffered points to be written on close, got:", lines)
+	}
+	if err := m.Notify("games", nil, Values{"created": 1}); err != ErrMetricsClosed {
+		t.Fatal("expected ErrMetricsClosed, got:", err)
+	}
+}
+
+func TestPrometheusSinkExportsLastValues(t *testing.T) {
+	sink := NewPrometheusSink(nil)
+	sink.Notify("ws_send_queue", Tags{"host": "h1"}, Values{"depth": int64(3), "dropped": Counter(1)})
+	sink.Notify("ws_send_queue", Tags{"host": "h1"}, Values{"depth": int64(5)})
+	sink.Notify("game", nil, Values{"running": true, "name": "ignored"})
+
+	recorder := httptest.NewRecorder()
+	sink.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, line := range []string{
+		"# TYPE catchcatch_ws_send_queue_depth gauge",
+		`catchcatch_ws_send_queue_depth{host="h1"} 5`,
+		"# TYPE catchcatch_ws_send_queue_dropped_total counter",
+		`catchcatch_ws_send_queue_dropped_total{host="h1"} 1`,
+		"catchcatch_game_running 1",
+		"go_goroutines ",
+	} {
+		if !strings.Contains(body, line) {
+			t.Fatalf("expected %q in:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "catchcatch_game_name") {
+		t.Fatal("expected non numeric values to be ignored")
+	}
+}
+
+func TestNewMetricsSinkWithoutBackends(t *testing.T) {
+	sink := NewMetricsSink()
+	if err := sink.Notify("any", nil, Values{"v": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Ping(); err != nil {
+		t.Fatal(err)
+	}
+}
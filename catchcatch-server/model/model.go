@@ -15,13 +15,24 @@ type Feature struct {
 
 // Player payload
 type Player struct {
-	ID  string  `json:"id"`
-	Lon float64 `json:"lon"`
-	Lat float64 `json:"lat"`
+	ID    string  `json:"id"`
+	Lon   float64 `json:"lon"`
+	Lat   float64 `json:"lat"`
+	Name  string  `json:"name,omitempty"`
+	Color string  `json:"color,omitempty"`
 }
 
 func (p Player) String() string {
-	return fmt.Sprintln("id:", p.ID, "lat:", p.Lat, "lon:", p.Lon)
+	return fmt.Sprintln("id:", p.ID, "name:", p.Name, "lat:", p.Lat, "lon:", p.Lon)
+}
+
+// PlayerProfile is the persistent identity of a player across connections
+type PlayerProfile struct {
+	ID string `json:"id"`
+	// TokenHash is the sha256 of the token issued to the player, the token itself is not stored
+	TokenHash string `json:"token_hash"`
+	Name      string `json:"name"`
+	Color     string `json:"color"`
 }
 
 // PlayerList list is an alias to []*Player
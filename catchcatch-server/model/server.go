@@ -0,0 +1,70 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ServerInfo is the server metadata advertised on zeroconf TXT records
+type ServerInfo struct {
+	Name            string `json:"name"`
+	ProtocolVersion uint32 `json:"version"`
+	TLS             bool   `json:"tls"`
+	WSPath          string `json:"path"`
+	Players         int    `json:"players"`
+	Games           int    `json:"games"`
+}
+
+// TXT encodes the info as key=value TXT records
+func (i ServerInfo) TXT() []string {
+	return []string{
+		"name=" + i.Name,
+		"version=" + strconv.FormatUint(uint64(i.ProtocolVersion), 10),
+		"tls=" + strconv.FormatBool(i.TLS),
+		"path=" + i.WSPath,
+		"players=" + strconv.Itoa(i.Players),
+		"games=" + strconv.Itoa(i.Games),
+	}
+}
+
+// URL is the WS address of the server at host:port
+func (i ServerInfo) URL(hostPort string) string {
+	scheme := "ws"
+	if i.TLS {
+		scheme = "wss"
+	}
+	path := i.WSPath
+	if path == "" {
+		path = "/ws"
+	}
+	return scheme + "://" + hostPort + path
+}
+
+// ParseServerInfo decodes TXT records, unknown keys and invalid values are
+// ignored so servers can advertise new records to old clients
+func ParseServerInfo(txt []string) ServerInfo {
+	info := ServerInfo{}
+	for _, record := range txt {
+		kv := strings.SplitN(record, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := kv[0], kv[1]
+		switch key {
+		case "name":
+			info.Name = value
+		case "version":
+			version, _ := strconv.ParseUint(value, 10, 32)
+			info.ProtocolVersion = uint32(version)
+		case "tls":
+			info.TLS, _ = strconv.ParseBool(value)
+		case "path":
+			info.WSPath = value
+		case "players":
+			info.Players, _ = strconv.Atoi(value)
+		case "games":
+			info.Games, _ = strconv.Atoi(value)
+		}
+	}
+	return info
+}
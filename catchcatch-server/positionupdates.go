@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/model"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+// ErrRateLimited happens when a player sends more position updates than allowed
+var ErrRateLimited = errors.New("too many position updates")
+
+// ErrCodeRateLimited is sent on error:rate-limited replies
+const ErrCodeRateLimited = "rate-limited"
+
+// busPlayersUpdated is the bus handler of the updated players, every node routes them to its own connections
+const busPlayersUpdated = "positions:players:updated"
+
+// PositionUpdateConfig limits the players position updates
+type PositionUpdateConfig struct {
+	// MaxPerSecond is the max number of updates a player can send per second, zero disables the limit
+	MaxPerSecond float64
+	// Burst is the number of updates a player can send at once after being idle
+	Burst int
+	// BatchInterval is the time between remote-players:batch broadcasts, zero broadcasts every update
+	BatchInterval time.Duration
+}
+
+// DefaultPositionUpdates allows 2 updates per second and broadcasts them every 500ms
+var DefaultPositionUpdates = PositionUpdateConfig{MaxPerSecond: 2, Burst: 4, BatchInterval: 500 * time.Millisecond}
+
+// PositionUpdateStats are the position updates counters
+type PositionUpdateStats struct {
+	Accepted  int64
+	Throttled int64
+	// Coalesced is the number of updates replaced by a newer one of the same player before broadcast
+	Coalesced int64
+	Batches   int64
+}
+
+// PositionUpdates rate limits players position updates
+// and sends the last position of each player to the interested connections periodically
+type PositionUpdates struct {
+	interest *AreaOfInterest
+	config   PositionUpdateConfig
+	now      func() time.Time
+
+	buckets map[string]*tokenBucket
+	pending map[string]*model.Player
+	stats   PositionUpdateStats
+	sync.Mutex
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// warned is when the player was last told it is throttled
+	warned time.Time
+}
+
+// NewPositionUpdates creates PositionUpdates sending to the connections interested on each player
+func NewPositionUpdates(interest *AreaOfInterest, config PositionUpdateConfig) *PositionUpdates {
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	u := &PositionUpdates{interest: interest, config: config, now: time.Now,
+		buckets: make(map[string]*tokenBucket), pending: make(map[string]*model.Player)}
+	interest.server.HandleBus(busPlayersUpdated, func(m Message) {
+		if batch, ok := m.(*protobuf.PlayerBatch); ok {
+			players := make([]*model.Player, len(batch.Players))
+			for i, p := range batch.Players {
+				players[i] = playerFromMessage(p)
+			}
+			u.sendLocal(players, neighborhoodFromMessage(batch.Neighbors))
+		}
+	})
+	return u
+}
+
+// Allow tells if the player can send one more update
+func (u *PositionUpdates) Allow(playerID string) bool {
+	u.Lock()
+	defer u.Unlock()
+	if u.config.MaxPerSecond <= 0 {
+		u.stats.Accepted++
+		return true
+	}
+	now := u.now()
+	bucket, exists := u.buckets[playerID]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(u.config.Burst), last: now}
+		u.buckets[playerID] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * u.config.MaxPerSecond
+	if max := float64(u.config.Burst); bucket.tokens > max {
+		bucket.tokens = max
+	}
+	bucket.last = now
+	if bucket.tokens < 1 {
+		u.stats.Throttled++
+		return false
+	}
+	bucket.tokens--
+	u.stats.Accepted++
+	return true
+}
+
+// Warn tells if the throttled player must be told about it,
+// it is true at most once per time to refill one update so throttled players aren't flooded with errors
+func (u *PositionUpdates) Warn(playerID string) bool {
+	u.Lock()
+	defer u.Unlock()
+	bucket, exists := u.buckets[playerID]
+	if !exists || u.config.MaxPerSecond <= 0 {
+		return false
+	}
+	now := u.now()
+	refill := time.Duration(float64(time.Second) / u.config.MaxPerSecond)
+	if !bucket.warned.IsZero() && now.Sub(bucket.warned) < refill {
+		return false
+	}
+	bucket.warned = now
+	return true
+}
+
+// Publish sends the player position on the next batch
+func (u *PositionUpdates) Publish(p *model.Player) {
+	player := *p
+	if u.config.BatchInterval <= 0 {
+		u.send([]*model.Player{&player})
+		return
+	}
+	u.Lock()
+	defer u.Unlock()
+	if _, exists := u.pending[p.ID]; exists {
+		u.stats.Coalesced++
+	}
+	u.pending[p.ID] = &player
+}
+
+// Forget the player limits and pending update, used when it leaves
+func (u *PositionUpdates) Forget(playerID string) {
+	u.Lock()
+	defer u.Unlock()
+	delete(u.buckets, playerID)
+	delete(u.pending, playerID)
+}
+
+// Stats returns the position updates counters
+func (u *PositionUpdates) Stats() PositionUpdateStats {
+	u.Lock()
+	defer u.Unlock()
+	return u.stats
+}
+
+// Run broadcasts the pending updates every BatchInterval until ctx is done
+func (u *PositionUpdates) Run(ctx context.Context) {
+	if u.config.BatchInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(u.config.BatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			u.Flush()
+		}
+	}
+}
+
+// Flush sends the pending updates as remote-players:batch
+// connections without the batch capability receive one remote-player:updated per player
+func (u *PositionUpdates) Flush() {
+	u.Lock()
+	if len(u.pending) == 0 {
+		u.Unlock()
+		return
+	}
+	players := make([]*model.Player, 0, len(u.pending))
+	for _, p := range u.pending {
+		players = append(players, p)
+	}
+	u.pending = make(map[string]*model.Player)
+	u.stats.Batches++
+	u.Unlock()
+	u.send(players)
+}
+
+// send the updated players to the connections which see them on every node
+// their neighbors are found once and sent along to the other nodes
+func (u *PositionUpdates) send(players []*model.Player) {
+	neighbors := u.interest.Neighborhood(players)
+	u.sendLocal(players, neighbors)
+	batch := &protobuf.PlayerBatch{EventName: proto.String("remote-players:batch"),
+		Players: make([]*protobuf.Player, len(players)), Neighbors: neighbors.message()}
+	for i, p := range players {
+		batch.Players[i] = playerMessage("remote-player:updated", p)
+	}
+	if err := u.interest.server.PublishToNodes(busPlayersUpdated, batch); err != nil {
+		u.interest.logger.Error("error to publish players", LogEvent, "remote-players:batch", "players", len(players), "error", err)
+	}
+}
+
+// sendLocal sends the updated players to the connections of this node
+// players entering or leaving a connection area are sent as remote-player:new and remote-player:destroy
+func (u *PositionUpdates) sendLocal(players []*model.Player, neighbors Neighborhood) {
+	for _, route := range u.interest.Route(players, neighbors) {
+		c := route.Conn
+		for _, p := range route.Left {
+			c.Emit(playerMessage("remote-player:destroy", p))
+		}
+		for _, p := range route.Joined {
+			c.Emit(playerMessage("remote-player:new", p))
+		}
+		if len(route.Updated) == 0 {
+			continue
+		}
+		if !c.Protocol().Has(CapBatch) || u.config.BatchInterval <= 0 {
+			for _, p := range route.Updated {
+				c.Emit(playerMessage("remote-player:updated", p))
+			}
+			continue
+		}
+		batch := &protobuf.PlayerBatch{EventName: proto.String("remote-players:batch"),
+			Players: make([]*protobuf.Player, 0, len(route.Updated))}
+		for _, p := range route.Updated {
+			batch.Players = append(batch.Players, playerMessage("remote-player:updated", p))
+		}
+		c.Emit(batch)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/model"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+func TestPositionUpdatesRateLimit(t *testing.T) {
+	now := time.Now()
+	u := NewPositionUpdates(NewAreaOfInterest(NewWSServer(nil, SendQueueConfig{}, nil), nil, 0, nil), PositionUpdateConfig{MaxPerSecond: 2, Burst: 2})
+	u.now = func() time.Time { return now }
+
+	if !u.Allow("p1") || !u.Allow("p1") {
+		t.Fatal("expected burst to be allowed")
+	}
+	if u.Allow("p1") {
+		t.Fatal("expected third update to be throttled")
+	}
+	if !u.Allow("p2") {
+		t.Fatal("expected other players not to be throttled")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if !u.Allow("p1") || u.Allow("p1") {
+		t.Fatal("expected one update to be allowed after 500ms")
+	}
+	if stats := u.Stats(); stats.Accepted != 4 || stats.Throttled != 2 {
+		t.Fatal("unexpected stats:", stats)
+	}
+}
+
+func TestPositionUpdatesWarnsThrottledPlayersOncePerRefill(t *testing.T) {
+	now := time.Now()
+	u := NewPositionUpdates(NewAreaOfInterest(NewWSServer(nil, SendQueueConfig{}, nil), nil, 0, nil), PositionUpdateConfig{MaxPerSecond: 2, Burst: 1})
+	u.now = func() time.Time { return now }
+
+	u.Allow("p1")
+	if u.Allow("p1") || !u.Warn("p1") {
+		t.Fatal("expected the first throttled update to be warned")
+	}
+	if u.Allow("p1") || u.Warn("p1") {
+		t.Fatal("expected no warning until the bucket refills")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if !u.Allow("p1") || u.Allow("p1") || !u.Warn("p1") {
+		t.Fatal("expected one warning after the bucket refills")
+	}
+}
+
+func TestPositionUpdatesFlushCoalescesUpdates(t *testing.T) {
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	batchConn, legacyConn := &fakeWSConn{}, &fakeWSConn{}
+	server.Add(batchConn).protocol.Store(NegotiateProtocol(ProtocolLegacy, []string{CapBatch}))
+	server.Add(legacyConn)
+
+	u := NewPositionUpdates(NewAreaOfInterest(server, nil, 0, nil), PositionUpdateConfig{BatchInterval: time.Second})
+	u.Publish(&model.Player{ID: "p1", Lat: 1, Lon: 1})
+	u.Publish(&model.Player{ID: "p1", Lat: 2, Lon: 2})
+	u.Publish(&model.Player{ID: "p2", Lat: 3, Lon: 3})
+	u.Flush()
+
+	if len(batchConn.sent) != 1 {
+		t.Fatal("expected one batch, got:", len(batchConn.sent))
+	}
+	batch := &protobuf.PlayerBatch{}
+	if err := proto.Unmarshal(batchConn.sent[0], batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch.GetEventName() != "remote-players:batch" || len(batch.Players) != 2 {
+		t.Fatal("unexpected batch:", batch)
+	}
+	for _, p := range batch.Players {
+		if p.GetId() == "p1" && p.GetLat() != 2 {
+			t.Fatal("expected last p1 position, got:", p)
+		}
+	}
+
+	if len(legacyConn.sent) != 2 {
+		t.Fatal("expected one remote-player:updated per player, got:", len(legacyConn.sent))
+	}
+	for _, payload := range legacyConn.sent {
+		msg := &protobuf.Player{}
+		if err := proto.Unmarshal(payload, msg); err != nil || msg.GetEventName() != "remote-player:updated" {
+			t.Fatal("unexpected message:", msg, err)
+		}
+	}
+	if stats := u.Stats(); stats.Coalesced != 1 || stats.Batches != 1 {
+		t.Fatal("unexpected stats:", stats)
+	}
+
+	u.Flush()
+	if len(batchConn.sent) != 1 {
+		t.Fatal("expected no batch without updates")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/model"
+	uuid "github.com/satori/go.uuid"
+	redis "gopkg.in/redis.v5"
+)
+
+const (
+	// MaxPlayerNameLength limits the size of display names
+	MaxPlayerNameLength = 32
+	// DefaultPlayerColor is used when the client doesn't send a valid color
+	DefaultPlayerColor = "#3399cc"
+	// ProfileCollection is the Tile38 collection of the player profiles, it is kept on admin:clear
+	ProfileCollection = "profile"
+)
+
+var (
+	// ErrProfileNotFound happens when there is no profile for the requested id
+	ErrProfileNotFound = errors.New("profile not found")
+	// ErrInvalidProfileToken happens when the token presented doesn't match the stored one
+	ErrInvalidProfileToken = errors.New("invalid profile token")
+
+	playerColorRegexp = regexp.MustCompile("^#[0-9a-fA-F]{6}$")
+)
+
+// PlayerProfileStore persists player identities
+type PlayerProfileStore interface {
+	Get(id string) (*model.PlayerProfile, error)
+	GetMany(ids []string) (map[string]*model.PlayerProfile, error)
+	Set(p *model.PlayerProfile) error
+}
+
+// Tile38PlayerProfileStore stores profiles as string objects on Tile38
+type Tile38PlayerProfileStore struct {
+	client *redis.Client
+}
+
+// NewPlayerProfileStore build a PlayerProfileStore
+func NewPlayerProfileStore(client *redis.Client) PlayerProfileStore {
+	return &Tile38PlayerProfileStore{client}
+}
+
+// Get returns the profile with id or ErrProfileNotFound
+func (s *Tile38PlayerProfileStore) Get(id string) (*model.PlayerProfile, error) {
+	cmd := redis.NewStringCmd("GET", ProfileCollection, id)
+	s.client.Process(cmd)
+	return profileFromCmd(cmd)
+}
+
+// GetMany returns the profiles of ids in a single round trip, ids without profile are left out
+func (s *Tile38PlayerProfileStore) GetMany(ids []string) (map[string]*model.PlayerProfile, error) {
+	profiles := make(map[string]*model.PlayerProfile, len(ids))
+	if len(ids) == 0 {
+		return profiles, nil
+	}
+	pipe := s.client.Pipeline()
+	defer pipe.Close()
+	cmds := make([]*redis.StringCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = redis.NewStringCmd("GET", ProfileCollection, id)
+		pipe.Process(cmds[i])
+	}
+	// the result of each command is checked below, missing profiles fail Exec too
+	pipe.Exec()
+	for i, cmd := range cmds {
+		profile, err := profileFromCmd(cmd)
+		if err == ErrProfileNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		profiles[ids[i]] = profile
+	}
+	return profiles, nil
+}
+
+func profileFromCmd(cmd *redis.StringCmd) (*model.PlayerProfile, error) {
+	data, err := cmd.Result()
+	if err == redis.Nil || (err != nil && strings.Contains(err.Error(), "not found")) {
+		return nil, ErrProfileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	profile := &model.PlayerProfile{}
+	if err := json.Unmarshal([]byte(data), profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// Set creates or replaces the profile
+func (s *Tile38PlayerProfileStore) Set(p *model.PlayerProfile) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	cmd := redis.NewStringCmd("SET", ProfileCollection, p.ID, "STRING", string(data))
+	s.client.Process(cmd)
+	return cmd.Err()
+}
+
+// IdentifyPlayer returns the profile for the hello credentials of the connection holding currentID
+// and the token the player must present on its next connections
+// currentID is used when no id is sent and doesn't need a token, it gets a new token unless it presents its own
+// other ids must have a profile and present its token
+func IdentifyPlayer(store PlayerProfileStore, currentID, id, token, name, color string) (*model.PlayerProfile, string, error) {
+	if id == "" {
+		id = currentID
+	}
+	profile, err := store.Get(id)
+	if err == ErrProfileNotFound && id == currentID {
+		profile = &model.PlayerProfile{ID: id}
+	} else if err == ErrProfileNotFound {
+		return nil, "", ErrInvalidProfileToken
+	} else if err != nil {
+		return nil, "", err
+	}
+	if !profileTokenMatches(profile, token) {
+		if id != currentID {
+			return nil, "", ErrInvalidProfileToken
+		}
+		token = newProfileToken()
+		profile.TokenHash = hashProfileToken(token)
+	}
+
+	if name = sanitizePlayerName(name); name != "" {
+		profile.Name = name
+	}
+	if playerColorRegexp.MatchString(color) {
+		profile.Color = color
+	}
+	if profile.Color == "" {
+		profile.Color = DefaultPlayerColor
+	}
+	if err := store.Set(profile); err != nil {
+		return nil, "", err
+	}
+	return profile, token, nil
+}
+
+func profileTokenMatches(profile *model.PlayerProfile, token string) bool {
+	if profile.TokenHash == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(profile.TokenHash), []byte(hashProfileToken(token))) == 1
+}
+
+func hashProfileToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func sanitizePlayerName(name string) string {
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > MaxPlayerNameLength {
+		name = string(runes[:MaxPlayerNameLength])
+	}
+	return name
+}
+
+func newProfileToken() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return uuid.NewV4().String()
+	}
+	return hex.EncodeToString(buf)
+}
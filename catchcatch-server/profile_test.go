@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/model"
+	redis "gopkg.in/redis.v5"
+)
+
+type memoryProfileStore map[string]*model.PlayerProfile
+
+func (s memoryProfileStore) Get(id string) (*model.PlayerProfile, error) {
+	if p, exists := s[id]; exists {
+		copied := *p
+		return &copied, nil
+	}
+	return nil, ErrProfileNotFound
+}
+
+func (s memoryProfileStore) GetMany(ids []string) (map[string]*model.PlayerProfile, error) {
+	profiles := make(map[string]*model.PlayerProfile)
+	for _, id := range ids {
+		if p, err := s.Get(id); err == nil {
+			profiles[id] = p
+		}
+	}
+	return profiles, nil
+}
+
+func (s memoryProfileStore) Set(p *model.PlayerProfile) error {
+	copied := *p
+	s[p.ID] = &copied
+	return nil
+}
+
+func TestIdentifyPlayerCreatesProfileForCurrentID(t *testing.T) {
+	store := memoryProfileStore{}
+	profile, token, err := IdentifyPlayer(store, "conn-1", "", "client-token", "  Ana  ", "not a color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.ID != "conn-1" || profile.Name != "Ana" || profile.Color != DefaultPlayerColor {
+		t.Fatal("unexpected profile:", profile)
+	}
+	if token == "" || token == "client-token" {
+		t.Fatal("expected the server to issue the token, got:", token)
+	}
+	if stored := store["conn-1"]; stored.TokenHash == "" || stored.TokenHash == token {
+		t.Fatal("expected only the token hash to be stored, got:", stored)
+	}
+
+	profile, kept, err := IdentifyPlayer(store, "conn-1", "conn-1", token, "Bia", "#ff0000")
+	if err != nil || profile.Name != "Bia" || profile.Color != "#ff0000" || kept != token {
+		t.Fatal("expected the connection to update its own profile, got:", profile, kept, err)
+	}
+}
+
+func TestIdentifyPlayerRequiresTokenOfOtherIDs(t *testing.T) {
+	store := memoryProfileStore{"p1": {ID: "p1", TokenHash: hashProfileToken("secret"), Name: "Ana"}}
+	if _, _, err := IdentifyPlayer(store, "conn-2", "p1", "", "", ""); err != ErrInvalidProfileToken {
+		t.Fatal("expected the profile token to be required, got:", err)
+	}
+	if _, _, err := IdentifyPlayer(store, "conn-2", "p1", "wrong", "", ""); err != ErrInvalidProfileToken {
+		t.Fatal("expected a wrong token to be refused, got:", err)
+	}
+	if _, _, err := IdentifyPlayer(store, "conn-2", "p1", store["p1"].TokenHash, "", ""); err != ErrInvalidProfileToken {
+		t.Fatal("expected the stored hash not to work as a token, got:", err)
+	}
+	if _, _, err := IdentifyPlayer(store, "conn-2", "conn-1", "", "", ""); err != ErrInvalidProfileToken {
+		t.Fatal("expected unknown ids of other connections to be refused, got:", err)
+	}
+	if _, exists := store["conn-1"]; exists {
+		t.Fatal("expected no profile to be created for other connections")
+	}
+	profile, token, err := IdentifyPlayer(store, "conn-2", "p1", "secret", "", "")
+	if err != nil || profile.ID != "p1" || profile.Name != "Ana" || token != "secret" {
+		t.Fatal("expected the profile owner to be identified, got:", profile, token, err)
+	}
+}
+
+func TestClearKeepsPlayerProfiles(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: startFakeRedis(t)})
+	defer client.Close()
+	client.HSet(ProfileCollection, "p1", "{}")
+	client.HSet("player", "p1", "point")
+	client.HSet("geofences", "g1", "polygon")
+
+	if err := NewPlayerLocationService(client).Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if collections, _ := client.Keys("*").Result(); len(collections) != 1 || collections[0] != ProfileCollection {
+		t.Fatal("expected only the profiles to be kept, got:", collections)
+	}
+}
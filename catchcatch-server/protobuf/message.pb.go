@@ -11,11 +11,23 @@ It has these top-level messages:
 	Simple
 	Feature
 	Player
+	PlayerHello
 	GameInfo
 	GameRank
 	PlayerRank
 	Distance
 	Detection
+	Auth
+	Error
+	AuditRequest
+	AuditEntry
+	AuditPage
+	ListEnd
+	PlayerNeighbors
+	PlayerBatch
+	ProtocolHello
+	ServerShutdown
+	Envelope
 */
 package protobuf
 
@@ -37,6 +49,7 @@ const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 type Simple struct {
 	EventName        *string `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
 	Id               *string `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	RequestId        *string `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -59,11 +72,19 @@ func (m *Simple) GetId() string {
 	return ""
 }
 
+func (m *Simple) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
 type Feature struct {
 	EventName        *string `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
 	Group            *string `protobuf:"bytes,2,req,name=group" json:"group,omitempty"`
 	Id               *string `protobuf:"bytes,3,opt,name=id" json:"id,omitempty"`
 	Coords           *string `protobuf:"bytes,4,opt,name=coords" json:"coords,omitempty"`
+	RequestId        *string `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -100,11 +121,22 @@ func (m *Feature) GetCoords() string {
 	return ""
 }
 
+func (m *Feature) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
 type Player struct {
 	EventName        *string  `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
 	Id               *string  `protobuf:"bytes,2,req,name=id" json:"id,omitempty"`
 	Lon              *float64 `protobuf:"fixed64,3,req,name=lon" json:"lon,omitempty"`
 	Lat              *float64 `protobuf:"fixed64,4,req,name=lat" json:"lat,omitempty"`
+	Name             *string  `protobuf:"bytes,5,opt,name=name" json:"name,omitempty"`
+	Color            *string  `protobuf:"bytes,6,opt,name=color" json:"color,omitempty"`
+	Token            *string  `protobuf:"bytes,7,opt,name=token" json:"token,omitempty"`
+	RequestId        *string  `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
 	XXX_unrecognized []byte   `json:"-"`
 }
 
@@ -141,18 +173,104 @@ func (m *Player) GetLat() float64 {
 	return 0
 }
 
+func (m *Player) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *Player) GetColor() string {
+	if m != nil && m.Color != nil {
+		return *m.Color
+	}
+	return ""
+}
+
+func (m *Player) GetToken() string {
+	if m != nil && m.Token != nil {
+		return *m.Token
+	}
+	return ""
+}
+
+func (m *Player) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+type PlayerHello struct {
+	EventName        *string `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
+	Id               *string `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Token            *string `protobuf:"bytes,3,opt,name=token" json:"token,omitempty"`
+	Name             *string `protobuf:"bytes,4,opt,name=name" json:"name,omitempty"`
+	Color            *string `protobuf:"bytes,5,opt,name=color" json:"color,omitempty"`
+	RequestId        *string `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *PlayerHello) Reset()                    { *m = PlayerHello{} }
+func (m *PlayerHello) String() string            { return proto.CompactTextString(m) }
+func (*PlayerHello) ProtoMessage()               {}
+func (*PlayerHello) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *PlayerHello) GetEventName() string {
+	if m != nil && m.EventName != nil {
+		return *m.EventName
+	}
+	return ""
+}
+
+func (m *PlayerHello) GetId() string {
+	if m != nil && m.Id != nil {
+		return *m.Id
+	}
+	return ""
+}
+
+func (m *PlayerHello) GetToken() string {
+	if m != nil && m.Token != nil {
+		return *m.Token
+	}
+	return ""
+}
+
+func (m *PlayerHello) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *PlayerHello) GetColor() string {
+	if m != nil && m.Color != nil {
+		return *m.Color
+	}
+	return ""
+}
+
+func (m *PlayerHello) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
 type GameInfo struct {
 	EventName        *string `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
 	Id               *string `protobuf:"bytes,2,req,name=id" json:"id,omitempty"`
 	Game             *string `protobuf:"bytes,3,req,name=game" json:"game,omitempty"`
 	Role             *string `protobuf:"bytes,4,req,name=role" json:"role,omitempty"`
+	RequestId        *string `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
 func (m *GameInfo) Reset()                    { *m = GameInfo{} }
 func (m *GameInfo) String() string            { return proto.CompactTextString(m) }
 func (*GameInfo) ProtoMessage()               {}
-func (*GameInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*GameInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
 
 func (m *GameInfo) GetEventName() string {
 	if m != nil && m.EventName != nil {
@@ -182,18 +300,26 @@ func (m *GameInfo) GetRole() string {
 	return ""
 }
 
+func (m *GameInfo) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
 type GameRank struct {
 	EventName        *string       `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
 	Id               *string       `protobuf:"bytes,2,req,name=id" json:"id,omitempty"`
 	Game             *string       `protobuf:"bytes,3,req,name=game" json:"game,omitempty"`
 	PlayersRank      []*PlayerRank `protobuf:"bytes,4,rep,name=players_rank,json=playersRank" json:"players_rank,omitempty"`
+	RequestId        *string       `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
 	XXX_unrecognized []byte        `json:"-"`
 }
 
 func (m *GameRank) Reset()                    { *m = GameRank{} }
 func (m *GameRank) String() string            { return proto.CompactTextString(m) }
 func (*GameRank) ProtoMessage()               {}
-func (*GameRank) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*GameRank) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *GameRank) GetEventName() string {
 	if m != nil && m.EventName != nil {
@@ -223,16 +349,25 @@ func (m *GameRank) GetPlayersRank() []*PlayerRank {
 	return nil
 }
 
+func (m *GameRank) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
 type PlayerRank struct {
 	Player           *string `protobuf:"bytes,1,req,name=player" json:"player,omitempty"`
 	Points           *int32  `protobuf:"varint,2,req,name=points" json:"points,omitempty"`
+	Name             *string `protobuf:"bytes,3,opt,name=name" json:"name,omitempty"`
+	Color            *string `protobuf:"bytes,4,opt,name=color" json:"color,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
 func (m *PlayerRank) Reset()                    { *m = PlayerRank{} }
 func (m *PlayerRank) String() string            { return proto.CompactTextString(m) }
 func (*PlayerRank) ProtoMessage()               {}
-func (*PlayerRank) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*PlayerRank) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 func (m *PlayerRank) GetPlayer() string {
 	if m != nil && m.Player != nil {
@@ -248,17 +383,32 @@ func (m *PlayerRank) GetPoints() int32 {
 	return 0
 }
 
+func (m *PlayerRank) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *PlayerRank) GetColor() string {
+	if m != nil && m.Color != nil {
+		return *m.Color
+	}
+	return ""
+}
+
 type Distance struct {
 	EventName        *string  `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
 	Id               *string  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
 	Dist             *float64 `protobuf:"fixed64,3,req,name=dist" json:"dist,omitempty"`
+	RequestId        *string  `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
 	XXX_unrecognized []byte   `json:"-"`
 }
 
 func (m *Distance) Reset()                    { *m = Distance{} }
 func (m *Distance) String() string            { return proto.CompactTextString(m) }
 func (*Distance) ProtoMessage()               {}
-func (*Distance) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*Distance) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
 
 func (m *Distance) GetEventName() string {
 	if m != nil && m.EventName != nil {
@@ -281,6 +431,13 @@ func (m *Distance) GetDist() float64 {
 	return 0
 }
 
+func (m *Distance) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
 type Detection struct {
 	EventName        *string  `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
 	Id               *string  `protobuf:"bytes,2,req,name=id" json:"id,omitempty"`
@@ -290,13 +447,14 @@ type Detection struct {
 	NearByFeatId     *string  `protobuf:"bytes,6,opt,name=near_by_feat_id,json=nearByFeatId" json:"near_by_feat_id,omitempty"`
 	NearByMeters     *float64 `protobuf:"fixed64,7,opt,name=near_by_meters,json=nearByMeters" json:"near_by_meters,omitempty"`
 	Intersects       *string  `protobuf:"bytes,8,opt,name=intersects" json:"intersects,omitempty"`
+	RequestId        *string  `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
 	XXX_unrecognized []byte   `json:"-"`
 }
 
 func (m *Detection) Reset()                    { *m = Detection{} }
 func (m *Detection) String() string            { return proto.CompactTextString(m) }
 func (*Detection) ProtoMessage()               {}
-func (*Detection) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*Detection) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
 
 func (m *Detection) GetEventName() string {
 	if m != nil && m.EventName != nil {
@@ -354,44 +512,1129 @@ func (m *Detection) GetIntersects() string {
 	return ""
 }
 
+func (m *Detection) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+type Auth struct {
+	EventName        *string `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
+	Token            *string `protobuf:"bytes,2,req,name=token" json:"token,omitempty"`
+	RequestId        *string `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Auth) Reset()                    { *m = Auth{} }
+func (m *Auth) String() string            { return proto.CompactTextString(m) }
+func (*Auth) ProtoMessage()               {}
+func (*Auth) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *Auth) GetEventName() string {
+	if m != nil && m.EventName != nil {
+		return *m.EventName
+	}
+	return ""
+}
+
+func (m *Auth) GetToken() string {
+	if m != nil && m.Token != nil {
+		return *m.Token
+	}
+	return ""
+}
+
+func (m *Auth) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+type Error struct {
+	EventName        *string `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
+	Id               *string `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Message          *string `protobuf:"bytes,3,opt,name=message" json:"message,omitempty"`
+	Code             *string `protobuf:"bytes,4,opt,name=code" json:"code,omitempty"`
+	RequestId        *string `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Error) Reset()                    { *m = Error{} }
+func (m *Error) String() string            { return proto.CompactTextString(m) }
+func (*Error) ProtoMessage()               {}
+func (*Error) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *Error) GetEventName() string {
+	if m != nil && m.EventName != nil {
+		return *m.EventName
+	}
+	return ""
+}
+
+func (m *Error) GetId() string {
+	if m != nil && m.Id != nil {
+		return *m.Id
+	}
+	return ""
+}
+
+func (m *Error) GetMessage() string {
+	if m != nil && m.Message != nil {
+		return *m.Message
+	}
+	return ""
+}
+
+func (m *Error) GetCode() string {
+	if m != nil && m.Code != nil {
+		return *m.Code
+	}
+	return ""
+}
+
+func (m *Error) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+type AuditRequest struct {
+	EventName        *string `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
+	Offset           *int32  `protobuf:"varint,2,opt,name=offset" json:"offset,omitempty"`
+	Limit            *int32  `protobuf:"varint,3,opt,name=limit" json:"limit,omitempty"`
+	RequestId        *string `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *AuditRequest) Reset()                    { *m = AuditRequest{} }
+func (m *AuditRequest) String() string            { return proto.CompactTextString(m) }
+func (*AuditRequest) ProtoMessage()               {}
+func (*AuditRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *AuditRequest) GetEventName() string {
+	if m != nil && m.EventName != nil {
+		return *m.EventName
+	}
+	return ""
+}
+
+func (m *AuditRequest) GetOffset() int32 {
+	if m != nil && m.Offset != nil {
+		return *m.Offset
+	}
+	return 0
+}
+
+func (m *AuditRequest) GetLimit() int32 {
+	if m != nil && m.Limit != nil {
+		return *m.Limit
+	}
+	return 0
+}
+
+func (m *AuditRequest) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+type AuditEntry struct {
+	Time             *int64  `protobuf:"varint,1,req,name=time" json:"time,omitempty"`
+	ConnId           *string `protobuf:"bytes,2,req,name=conn_id,json=connId" json:"conn_id,omitempty"`
+	Subject          *string `protobuf:"bytes,3,opt,name=subject" json:"subject,omitempty"`
+	Role             *string `protobuf:"bytes,4,opt,name=role" json:"role,omitempty"`
+	Event            *string `protobuf:"bytes,5,req,name=event" json:"event,omitempty"`
+	Payload          *string `protobuf:"bytes,6,opt,name=payload" json:"payload,omitempty"`
+	Outcome          *string `protobuf:"bytes,7,req,name=outcome" json:"outcome,omitempty"`
+	Error            *string `protobuf:"bytes,8,opt,name=error" json:"error,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *AuditEntry) Reset()                    { *m = AuditEntry{} }
+func (m *AuditEntry) String() string            { return proto.CompactTextString(m) }
+func (*AuditEntry) ProtoMessage()               {}
+func (*AuditEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *AuditEntry) GetTime() int64 {
+	if m != nil && m.Time != nil {
+		return *m.Time
+	}
+	return 0
+}
+
+func (m *AuditEntry) GetConnId() string {
+	if m != nil && m.ConnId != nil {
+		return *m.ConnId
+	}
+	return ""
+}
+
+func (m *AuditEntry) GetSubject() string {
+	if m != nil && m.Subject != nil {
+		return *m.Subject
+	}
+	return ""
+}
+
+func (m *AuditEntry) GetRole() string {
+	if m != nil && m.Role != nil {
+		return *m.Role
+	}
+	return ""
+}
+
+func (m *AuditEntry) GetEvent() string {
+	if m != nil && m.Event != nil {
+		return *m.Event
+	}
+	return ""
+}
+
+func (m *AuditEntry) GetPayload() string {
+	if m != nil && m.Payload != nil {
+		return *m.Payload
+	}
+	return ""
+}
+
+func (m *AuditEntry) GetOutcome() string {
+	if m != nil && m.Outcome != nil {
+		return *m.Outcome
+	}
+	return ""
+}
+
+func (m *AuditEntry) GetError() string {
+	if m != nil && m.Error != nil {
+		return *m.Error
+	}
+	return ""
+}
+
+type AuditPage struct {
+	EventName        *string       `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
+	Offset           *int32        `protobuf:"varint,2,req,name=offset" json:"offset,omitempty"`
+	Total            *int32        `protobuf:"varint,3,req,name=total" json:"total,omitempty"`
+	Entries          []*AuditEntry `protobuf:"bytes,4,rep,name=entries" json:"entries,omitempty"`
+	RequestId        *string       `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	XXX_unrecognized []byte        `json:"-"`
+}
+
+func (m *AuditPage) Reset()                    { *m = AuditPage{} }
+func (m *AuditPage) String() string            { return proto.CompactTextString(m) }
+func (*AuditPage) ProtoMessage()               {}
+func (*AuditPage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *AuditPage) GetEventName() string {
+	if m != nil && m.EventName != nil {
+		return *m.EventName
+	}
+	return ""
+}
+
+func (m *AuditPage) GetOffset() int32 {
+	if m != nil && m.Offset != nil {
+		return *m.Offset
+	}
+	return 0
+}
+
+func (m *AuditPage) GetTotal() int32 {
+	if m != nil && m.Total != nil {
+		return *m.Total
+	}
+	return 0
+}
+
+func (m *AuditPage) GetEntries() []*AuditEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+func (m *AuditPage) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+type ListEnd struct {
+	EventName        *string `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
+	Count            *int32  `protobuf:"varint,2,req,name=count" json:"count,omitempty"`
+	RequestId        *string `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *ListEnd) Reset()                    { *m = ListEnd{} }
+func (m *ListEnd) String() string            { return proto.CompactTextString(m) }
+func (*ListEnd) ProtoMessage()               {}
+func (*ListEnd) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *ListEnd) GetEventName() string {
+	if m != nil && m.EventName != nil {
+		return *m.EventName
+	}
+	return ""
+}
+
+func (m *ListEnd) GetCount() int32 {
+	if m != nil && m.Count != nil {
+		return *m.Count
+	}
+	return 0
+}
+
+func (m *ListEnd) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+// PlayerNeighbors are the players seen by a player
+type PlayerNeighbors struct {
+	PlayerId         *string   `protobuf:"bytes,1,req,name=player_id,json=playerId" json:"player_id,omitempty"`
+	Neighbors        []*Player `protobuf:"bytes,2,rep,name=neighbors" json:"neighbors,omitempty"`
+	XXX_unrecognized []byte    `json:"-"`
+}
+
+func (m *PlayerNeighbors) Reset()                    { *m = PlayerNeighbors{} }
+func (m *PlayerNeighbors) String() string            { return proto.CompactTextString(m) }
+func (*PlayerNeighbors) ProtoMessage()               {}
+func (*PlayerNeighbors) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *PlayerNeighbors) GetPlayerId() string {
+	if m != nil && m.PlayerId != nil {
+		return *m.PlayerId
+	}
+	return ""
+}
+
+func (m *PlayerNeighbors) GetNeighbors() []*Player {
+	if m != nil {
+		return m.Neighbors
+	}
+	return nil
+}
+
+// PlayerBatch carries the last position of the players updated since the previous batch
+// neighbors are only sent between nodes, so they route the batch without looking for them again
+type PlayerBatch struct {
+	EventName        *string            `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
+	Players          []*Player          `protobuf:"bytes,2,rep,name=players" json:"players,omitempty"`
+	Neighbors        []*PlayerNeighbors `protobuf:"bytes,3,rep,name=neighbors" json:"neighbors,omitempty"`
+	RequestId        *string            `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	XXX_unrecognized []byte             `json:"-"`
+}
+
+func (m *PlayerBatch) Reset()                    { *m = PlayerBatch{} }
+func (m *PlayerBatch) String() string            { return proto.CompactTextString(m) }
+func (*PlayerBatch) ProtoMessage()               {}
+func (*PlayerBatch) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *PlayerBatch) GetEventName() string {
+	if m != nil && m.EventName != nil {
+		return *m.EventName
+	}
+	return ""
+}
+
+func (m *PlayerBatch) GetPlayers() []*Player {
+	if m != nil {
+		return m.Players
+	}
+	return nil
+}
+
+func (m *PlayerBatch) GetNeighbors() []*PlayerNeighbors {
+	if m != nil {
+		return m.Neighbors
+	}
+	return nil
+}
+
+func (m *PlayerBatch) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+// ProtocolHello declares the client protocol version and capabilities on protocol:hello
+// the server replies protocol:welcome with the negotiated ones
+type ProtocolHello struct {
+	EventName        *string  `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
+	Version          *uint32  `protobuf:"varint,2,req,name=version" json:"version,omitempty"`
+	Capabilities     []string `protobuf:"bytes,3,rep,name=capabilities" json:"capabilities,omitempty"`
+	RequestId        *string  `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *ProtocolHello) Reset()                    { *m = ProtocolHello{} }
+func (m *ProtocolHello) String() string            { return proto.CompactTextString(m) }
+func (*ProtocolHello) ProtoMessage()               {}
+func (*ProtocolHello) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *ProtocolHello) GetEventName() string {
+	if m != nil && m.EventName != nil {
+		return *m.EventName
+	}
+	return ""
+}
+
+func (m *ProtocolHello) GetVersion() uint32 {
+	if m != nil && m.Version != nil {
+		return *m.Version
+	}
+	return 0
+}
+
+func (m *ProtocolHello) GetCapabilities() []string {
+	if m != nil {
+		return m.Capabilities
+	}
+	return nil
+}
+
+func (m *ProtocolHello) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+// ServerShutdown is sent on server:shutdown before the server closes the connections
+// clients should reconnect after reconnect_in milliseconds, to another server when reconnect_url is set
+type ServerShutdown struct {
+	EventName        *string `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
+	ReconnectIn      *int64  `protobuf:"varint,2,opt,name=reconnect_in,json=reconnectIn" json:"reconnect_in,omitempty"`
+	ReconnectUrl     *string `protobuf:"bytes,3,opt,name=reconnect_url,json=reconnectUrl" json:"reconnect_url,omitempty"`
+	RequestId        *string `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *ServerShutdown) Reset()                    { *m = ServerShutdown{} }
+func (m *ServerShutdown) String() string            { return proto.CompactTextString(m) }
+func (*ServerShutdown) ProtoMessage()               {}
+func (*ServerShutdown) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *ServerShutdown) GetEventName() string {
+	if m != nil && m.EventName != nil {
+		return *m.EventName
+	}
+	return ""
+}
+
+func (m *ServerShutdown) GetReconnectIn() int64 {
+	if m != nil && m.ReconnectIn != nil {
+		return *m.ReconnectIn
+	}
+	return 0
+}
+
+func (m *ServerShutdown) GetReconnectUrl() string {
+	if m != nil && m.ReconnectUrl != nil {
+		return *m.ReconnectUrl
+	}
+	return ""
+}
+
+func (m *ServerShutdown) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+// Envelope wraps any message with its event, version is the protocol version
+// and must be >= 2, see ProtocolHello
+type Envelope struct {
+	EventName *string `protobuf:"bytes,1,req,name=event_name,json=eventName" json:"event_name,omitempty"`
+	Version   *uint32 `protobuf:"varint,14,opt,name=version" json:"version,omitempty"`
+	RequestId *string `protobuf:"bytes,15,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//	*Envelope_Simple
+	//	*Envelope_Feature
+	//	*Envelope_Player
+	//	*Envelope_PlayerHello
+	//	*Envelope_GameInfo
+	//	*Envelope_GameRank
+	//	*Envelope_Distance
+	//	*Envelope_Detection
+	//	*Envelope_Auth
+	//	*Envelope_Error
+	//	*Envelope_AuditRequest
+	//	*Envelope_AuditPage
+	//	*Envelope_ListEnd
+	//	*Envelope_ProtocolHello
+	//	*Envelope_PlayerBatch
+	//	*Envelope_ServerShutdown
+	Payload          isEnvelope_Payload `protobuf_oneof:"payload"`
+	XXX_unrecognized []byte             `json:"-"`
+}
+
+func (m *Envelope) Reset()                    { *m = Envelope{} }
+func (m *Envelope) String() string            { return proto.CompactTextString(m) }
+func (*Envelope) ProtoMessage()               {}
+func (*Envelope) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+type isEnvelope_Payload interface{ isEnvelope_Payload() }
+
+type Envelope_Simple struct {
+	Simple *Simple `protobuf:"bytes,20,opt,name=simple,oneof"`
+}
+type Envelope_Feature struct {
+	Feature *Feature `protobuf:"bytes,21,opt,name=feature,oneof"`
+}
+type Envelope_Player struct {
+	Player *Player `protobuf:"bytes,22,opt,name=player,oneof"`
+}
+type Envelope_PlayerHello struct {
+	PlayerHello *PlayerHello `protobuf:"bytes,23,opt,name=player_hello,json=playerHello,oneof"`
+}
+type Envelope_GameInfo struct {
+	GameInfo *GameInfo `protobuf:"bytes,24,opt,name=game_info,json=gameInfo,oneof"`
+}
+type Envelope_GameRank struct {
+	GameRank *GameRank `protobuf:"bytes,25,opt,name=game_rank,json=gameRank,oneof"`
+}
+type Envelope_Distance struct {
+	Distance *Distance `protobuf:"bytes,26,opt,name=distance,oneof"`
+}
+type Envelope_Detection struct {
+	Detection *Detection `protobuf:"bytes,27,opt,name=detection,oneof"`
+}
+type Envelope_Auth struct {
+	Auth *Auth `protobuf:"bytes,28,opt,name=auth,oneof"`
+}
+type Envelope_Error struct {
+	Error *Error `protobuf:"bytes,29,opt,name=error,oneof"`
+}
+type Envelope_AuditRequest struct {
+	AuditRequest *AuditRequest `protobuf:"bytes,30,opt,name=audit_request,json=auditRequest,oneof"`
+}
+type Envelope_AuditPage struct {
+	AuditPage *AuditPage `protobuf:"bytes,31,opt,name=audit_page,json=auditPage,oneof"`
+}
+type Envelope_ListEnd struct {
+	ListEnd *ListEnd `protobuf:"bytes,32,opt,name=list_end,json=listEnd,oneof"`
+}
+type Envelope_ProtocolHello struct {
+	ProtocolHello *ProtocolHello `protobuf:"bytes,33,opt,name=protocol_hello,json=protocolHello,oneof"`
+}
+type Envelope_PlayerBatch struct {
+	PlayerBatch *PlayerBatch `protobuf:"bytes,34,opt,name=player_batch,json=playerBatch,oneof"`
+}
+type Envelope_ServerShutdown struct {
+	ServerShutdown *ServerShutdown `protobuf:"bytes,35,opt,name=server_shutdown,json=serverShutdown,oneof"`
+}
+
+func (*Envelope_Simple) isEnvelope_Payload()         {}
+func (*Envelope_Feature) isEnvelope_Payload()        {}
+func (*Envelope_Player) isEnvelope_Payload()         {}
+func (*Envelope_PlayerHello) isEnvelope_Payload()    {}
+func (*Envelope_GameInfo) isEnvelope_Payload()       {}
+func (*Envelope_GameRank) isEnvelope_Payload()       {}
+func (*Envelope_Distance) isEnvelope_Payload()       {}
+func (*Envelope_Detection) isEnvelope_Payload()      {}
+func (*Envelope_Auth) isEnvelope_Payload()           {}
+func (*Envelope_Error) isEnvelope_Payload()          {}
+func (*Envelope_AuditRequest) isEnvelope_Payload()   {}
+func (*Envelope_AuditPage) isEnvelope_Payload()      {}
+func (*Envelope_ListEnd) isEnvelope_Payload()        {}
+func (*Envelope_ProtocolHello) isEnvelope_Payload()  {}
+func (*Envelope_PlayerBatch) isEnvelope_Payload()    {}
+func (*Envelope_ServerShutdown) isEnvelope_Payload() {}
+
+func (m *Envelope) GetPayload() isEnvelope_Payload {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *Envelope) GetEventName() string {
+	if m != nil && m.EventName != nil {
+		return *m.EventName
+	}
+	return ""
+}
+
+func (m *Envelope) GetVersion() uint32 {
+	if m != nil && m.Version != nil {
+		return *m.Version
+	}
+	return 0
+}
+
+func (m *Envelope) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+func (m *Envelope) GetSimple() *Simple {
+	if x, ok := m.GetPayload().(*Envelope_Simple); ok {
+		return x.Simple
+	}
+	return nil
+}
+
+func (m *Envelope) GetFeature() *Feature {
+	if x, ok := m.GetPayload().(*Envelope_Feature); ok {
+		return x.Feature
+	}
+	return nil
+}
+
+func (m *Envelope) GetPlayer() *Player {
+	if x, ok := m.GetPayload().(*Envelope_Player); ok {
+		return x.Player
+	}
+	return nil
+}
+
+func (m *Envelope) GetPlayerHello() *PlayerHello {
+	if x, ok := m.GetPayload().(*Envelope_PlayerHello); ok {
+		return x.PlayerHello
+	}
+	return nil
+}
+
+func (m *Envelope) GetGameInfo() *GameInfo {
+	if x, ok := m.GetPayload().(*Envelope_GameInfo); ok {
+		return x.GameInfo
+	}
+	return nil
+}
+
+func (m *Envelope) GetGameRank() *GameRank {
+	if x, ok := m.GetPayload().(*Envelope_GameRank); ok {
+		return x.GameRank
+	}
+	return nil
+}
+
+func (m *Envelope) GetDistance() *Distance {
+	if x, ok := m.GetPayload().(*Envelope_Distance); ok {
+		return x.Distance
+	}
+	return nil
+}
+
+func (m *Envelope) GetDetection() *Detection {
+	if x, ok := m.GetPayload().(*Envelope_Detection); ok {
+		return x.Detection
+	}
+	return nil
+}
+
+func (m *Envelope) GetAuth() *Auth {
+	if x, ok := m.GetPayload().(*Envelope_Auth); ok {
+		return x.Auth
+	}
+	return nil
+}
+
+func (m *Envelope) GetError() *Error {
+	if x, ok := m.GetPayload().(*Envelope_Error); ok {
+		return x.Error
+	}
+	return nil
+}
+
+func (m *Envelope) GetAuditRequest() *AuditRequest {
+	if x, ok := m.GetPayload().(*Envelope_AuditRequest); ok {
+		return x.AuditRequest
+	}
+	return nil
+}
+
+func (m *Envelope) GetAuditPage() *AuditPage {
+	if x, ok := m.GetPayload().(*Envelope_AuditPage); ok {
+		return x.AuditPage
+	}
+	return nil
+}
+
+func (m *Envelope) GetListEnd() *ListEnd {
+	if x, ok := m.GetPayload().(*Envelope_ListEnd); ok {
+		return x.ListEnd
+	}
+	return nil
+}
+
+func (m *Envelope) GetProtocolHello() *ProtocolHello {
+	if x, ok := m.GetPayload().(*Envelope_ProtocolHello); ok {
+		return x.ProtocolHello
+	}
+	return nil
+}
+
+func (m *Envelope) GetPlayerBatch() *PlayerBatch {
+	if x, ok := m.GetPayload().(*Envelope_PlayerBatch); ok {
+		return x.PlayerBatch
+	}
+	return nil
+}
+
+func (m *Envelope) GetServerShutdown() *ServerShutdown {
+	if x, ok := m.GetPayload().(*Envelope_ServerShutdown); ok {
+		return x.ServerShutdown
+	}
+	return nil
+}
+
+// XXX_OneofFuncs is for the internal use of the proto package.
+func (*Envelope) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
+	return _Envelope_OneofMarshaler, _Envelope_OneofUnmarshaler, _Envelope_OneofSizer, []interface{}{
+		(*Envelope_Simple)(nil),
+		(*Envelope_Feature)(nil),
+		(*Envelope_Player)(nil),
+		(*Envelope_PlayerHello)(nil),
+		(*Envelope_GameInfo)(nil),
+		(*Envelope_GameRank)(nil),
+		(*Envelope_Distance)(nil),
+		(*Envelope_Detection)(nil),
+		(*Envelope_Auth)(nil),
+		(*Envelope_Error)(nil),
+		(*Envelope_AuditRequest)(nil),
+		(*Envelope_AuditPage)(nil),
+		(*Envelope_ListEnd)(nil),
+		(*Envelope_ProtocolHello)(nil),
+		(*Envelope_PlayerBatch)(nil),
+		(*Envelope_ServerShutdown)(nil),
+	}
+}
+
+func _Envelope_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
+	m := msg.(*Envelope)
+	// payload
+	switch x := m.Payload.(type) {
+	case *Envelope_Simple:
+		b.EncodeVarint(20<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Simple); err != nil {
+			return err
+		}
+	case *Envelope_Feature:
+		b.EncodeVarint(21<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Feature); err != nil {
+			return err
+		}
+	case *Envelope_Player:
+		b.EncodeVarint(22<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Player); err != nil {
+			return err
+		}
+	case *Envelope_PlayerHello:
+		b.EncodeVarint(23<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.PlayerHello); err != nil {
+			return err
+		}
+	case *Envelope_GameInfo:
+		b.EncodeVarint(24<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.GameInfo); err != nil {
+			return err
+		}
+	case *Envelope_GameRank:
+		b.EncodeVarint(25<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.GameRank); err != nil {
+			return err
+		}
+	case *Envelope_Distance:
+		b.EncodeVarint(26<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Distance); err != nil {
+			return err
+		}
+	case *Envelope_Detection:
+		b.EncodeVarint(27<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Detection); err != nil {
+			return err
+		}
+	case *Envelope_Auth:
+		b.EncodeVarint(28<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Auth); err != nil {
+			return err
+		}
+	case *Envelope_Error:
+		b.EncodeVarint(29<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Error); err != nil {
+			return err
+		}
+	case *Envelope_AuditRequest:
+		b.EncodeVarint(30<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.AuditRequest); err != nil {
+			return err
+		}
+	case *Envelope_AuditPage:
+		b.EncodeVarint(31<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.AuditPage); err != nil {
+			return err
+		}
+	case *Envelope_ListEnd:
+		b.EncodeVarint(32<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.ListEnd); err != nil {
+			return err
+		}
+	case *Envelope_ProtocolHello:
+		b.EncodeVarint(33<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.ProtocolHello); err != nil {
+			return err
+		}
+	case *Envelope_PlayerBatch:
+		b.EncodeVarint(34<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.PlayerBatch); err != nil {
+			return err
+		}
+	case *Envelope_ServerShutdown:
+		b.EncodeVarint(35<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.ServerShutdown); err != nil {
+			return err
+		}
+	case nil:
+	default:
+		return fmt.Errorf("Envelope.Payload has unexpected type %T", x)
+	}
+	return nil
+}
+
+func _Envelope_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
+	m := msg.(*Envelope)
+	switch tag {
+	case 20: // payload.simple
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Simple)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_Simple{msg}
+		return true, err
+	case 21: // payload.feature
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Feature)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_Feature{msg}
+		return true, err
+	case 22: // payload.player
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Player)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_Player{msg}
+		return true, err
+	case 23: // payload.player_hello
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(PlayerHello)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_PlayerHello{msg}
+		return true, err
+	case 24: // payload.game_info
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(GameInfo)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_GameInfo{msg}
+		return true, err
+	case 25: // payload.game_rank
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(GameRank)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_GameRank{msg}
+		return true, err
+	case 26: // payload.distance
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Distance)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_Distance{msg}
+		return true, err
+	case 27: // payload.detection
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Detection)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_Detection{msg}
+		return true, err
+	case 28: // payload.auth
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Auth)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_Auth{msg}
+		return true, err
+	case 29: // payload.error
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Error)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_Error{msg}
+		return true, err
+	case 30: // payload.audit_request
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(AuditRequest)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_AuditRequest{msg}
+		return true, err
+	case 31: // payload.audit_page
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(AuditPage)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_AuditPage{msg}
+		return true, err
+	case 32: // payload.list_end
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(ListEnd)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_ListEnd{msg}
+		return true, err
+	case 33: // payload.protocol_hello
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(ProtocolHello)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_ProtocolHello{msg}
+		return true, err
+	case 34: // payload.player_batch
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(PlayerBatch)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_PlayerBatch{msg}
+		return true, err
+	case 35: // payload.server_shutdown
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(ServerShutdown)
+		err := b.DecodeMessage(msg)
+		m.Payload = &Envelope_ServerShutdown{msg}
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+func _Envelope_OneofSizer(msg proto.Message) (n int) {
+	m := msg.(*Envelope)
+	// payload
+	switch x := m.Payload.(type) {
+	case *Envelope_Simple:
+		s := proto.Size(x.Simple)
+		n += proto.SizeVarint(20<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_Feature:
+		s := proto.Size(x.Feature)
+		n += proto.SizeVarint(21<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_Player:
+		s := proto.Size(x.Player)
+		n += proto.SizeVarint(22<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_PlayerHello:
+		s := proto.Size(x.PlayerHello)
+		n += proto.SizeVarint(23<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_GameInfo:
+		s := proto.Size(x.GameInfo)
+		n += proto.SizeVarint(24<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_GameRank:
+		s := proto.Size(x.GameRank)
+		n += proto.SizeVarint(25<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_Distance:
+		s := proto.Size(x.Distance)
+		n += proto.SizeVarint(26<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_Detection:
+		s := proto.Size(x.Detection)
+		n += proto.SizeVarint(27<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_Auth:
+		s := proto.Size(x.Auth)
+		n += proto.SizeVarint(28<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_Error:
+		s := proto.Size(x.Error)
+		n += proto.SizeVarint(29<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_AuditRequest:
+		s := proto.Size(x.AuditRequest)
+		n += proto.SizeVarint(30<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_AuditPage:
+		s := proto.Size(x.AuditPage)
+		n += proto.SizeVarint(31<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_ListEnd:
+		s := proto.Size(x.ListEnd)
+		n += proto.SizeVarint(32<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_ProtocolHello:
+		s := proto.Size(x.ProtocolHello)
+		n += proto.SizeVarint(33<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_PlayerBatch:
+		s := proto.Size(x.PlayerBatch)
+		n += proto.SizeVarint(34<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *Envelope_ServerShutdown:
+		s := proto.Size(x.ServerShutdown)
+		n += proto.SizeVarint(35<<3 | proto.WireBytes)
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case nil:
+	default:
+		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
+	}
+	return n
+}
+
 func init() {
 	proto.RegisterType((*Simple)(nil), "protobuf.Simple")
 	proto.RegisterType((*Feature)(nil), "protobuf.Feature")
 	proto.RegisterType((*Player)(nil), "protobuf.Player")
+	proto.RegisterType((*PlayerHello)(nil), "protobuf.PlayerHello")
 	proto.RegisterType((*GameInfo)(nil), "protobuf.GameInfo")
 	proto.RegisterType((*GameRank)(nil), "protobuf.GameRank")
 	proto.RegisterType((*PlayerRank)(nil), "protobuf.PlayerRank")
 	proto.RegisterType((*Distance)(nil), "protobuf.Distance")
 	proto.RegisterType((*Detection)(nil), "protobuf.Detection")
+	proto.RegisterType((*Auth)(nil), "protobuf.Auth")
+	proto.RegisterType((*Error)(nil), "protobuf.Error")
+	proto.RegisterType((*AuditRequest)(nil), "protobuf.AuditRequest")
+	proto.RegisterType((*AuditEntry)(nil), "protobuf.AuditEntry")
+	proto.RegisterType((*AuditPage)(nil), "protobuf.AuditPage")
+	proto.RegisterType((*ListEnd)(nil), "protobuf.ListEnd")
+	proto.RegisterType((*PlayerNeighbors)(nil), "protobuf.PlayerNeighbors")
+	proto.RegisterType((*PlayerBatch)(nil), "protobuf.PlayerBatch")
+	proto.RegisterType((*ProtocolHello)(nil), "protobuf.ProtocolHello")
+	proto.RegisterType((*ServerShutdown)(nil), "protobuf.ServerShutdown")
+	proto.RegisterType((*Envelope)(nil), "protobuf.Envelope")
 }
 
 func init() { proto.RegisterFile("protobuf/message.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 398 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x92, 0xcf, 0x8a, 0xd4, 0x40,
-	0x10, 0xc6, 0xc9, 0x9f, 0xc9, 0x24, 0xb5, 0xcb, 0x2a, 0x61, 0x59, 0xfb, 0xa2, 0x84, 0x46, 0x21,
-	0xa7, 0x11, 0xbc, 0xec, 0xc5, 0x93, 0x2c, 0x2b, 0x7b, 0x58, 0x91, 0x78, 0xf4, 0x10, 0x7a, 0x93,
-	0xca, 0xd0, 0x6c, 0xd2, 0x1d, 0xba, 0x7b, 0x84, 0x79, 0x01, 0x9f, 0xd4, 0x07, 0x91, 0xae, 0x74,
-	0x18, 0x6f, 0x3a, 0xe0, 0xad, 0xbe, 0x5f, 0x57, 0xbe, 0xaa, 0x54, 0x15, 0xdc, 0xcc, 0x46, 0x3b,
-	0xfd, 0x74, 0x18, 0xde, 0x4f, 0x68, 0xad, 0xd8, 0xe3, 0x8e, 0x40, 0x99, 0xaf, 0x9c, 0xdf, 0x42,
-	0xf6, 0x4d, 0x4e, 0xf3, 0x88, 0xe5, 0x6b, 0x00, 0xfc, 0x81, 0xca, 0xb5, 0x4a, 0x4c, 0xc8, 0xa2,
-	0x2a, 0xae, 0x8b, 0xa6, 0x20, 0xf2, 0x45, 0x4c, 0x58, 0x5e, 0x41, 0x2c, 0x7b, 0x16, 0x57, 0x51,
-	0x5d, 0x34, 0xb1, 0xec, 0xf9, 0x00, 0xdb, 0x7b, 0x14, 0xee, 0x60, 0xfe, 0xfa, 0xe5, 0x35, 0x6c,
-	0xf6, 0x46, 0x1f, 0x66, 0x16, 0xd3, 0xcb, 0x22, 0x82, 0x5f, 0xb2, 0xfa, 0x95, 0x37, 0x90, 0x75,
-	0x5a, 0x9b, 0xde, 0xb2, 0x94, 0x58, 0x50, 0xfc, 0x3b, 0x64, 0x5f, 0x47, 0x71, 0x44, 0xf3, 0xaf,
-	0x0d, 0xc6, 0xc1, 0xf0, 0x25, 0x24, 0xa3, 0x56, 0x2c, 0xa9, 0xe2, 0x3a, 0x6a, 0x7c, 0x48, 0x44,
-	0x38, 0x96, 0x06, 0x22, 0x1c, 0x17, 0x90, 0x7f, 0x16, 0x13, 0x3e, 0xa8, 0x41, 0x9f, 0x6b, 0x5f,
-	0x42, 0xba, 0xf7, 0x89, 0x09, 0x11, 0x8a, 0x3d, 0x33, 0x7a, 0x44, 0xaa, 0x50, 0x34, 0x14, 0xf3,
-	0x9f, 0xd1, 0x52, 0xa3, 0x11, 0xea, 0xf9, 0x7f, 0xd4, 0xb8, 0x85, 0xcb, 0x99, 0xe6, 0x61, 0x5b,
-	0x23, 0xd4, 0x33, 0x4b, 0xab, 0xa4, 0xbe, 0xf8, 0x70, 0xbd, 0x5b, 0x37, 0xba, 0x5b, 0xa6, 0xe5,
-	0xcb, 0x35, 0x17, 0x21, 0xd3, 0x0b, 0xfe, 0x11, 0xe0, 0xf4, 0xe4, 0xc7, 0xbd, 0x3c, 0x86, 0x2e,
-	0x82, 0x22, 0xae, 0xa5, 0x72, 0x96, 0xda, 0xd8, 0x34, 0x41, 0xf1, 0x47, 0xc8, 0xef, 0xa4, 0x75,
-	0x42, 0x75, 0xe7, 0x5e, 0x8a, 0xff, 0x8b, 0x5e, 0x5a, 0x17, 0x36, 0x41, 0x31, 0xff, 0x15, 0x41,
-	0x71, 0x87, 0x0e, 0x3b, 0x27, 0xb5, 0x3a, 0x77, 0x2c, 0xaf, 0x60, 0x3b, 0xa0, 0x70, 0x2d, 0xdd,
-	0x0f, 0x35, 0xef, 0xe5, 0x43, 0x7f, 0x5a, 0x70, 0x14, 0x16, 0xbc, 0x1e, 0xc1, 0x26, 0x10, 0xad,
-	0xca, 0x77, 0xf0, 0x42, 0xa1, 0x30, 0xed, 0xd3, 0xb1, 0x5d, 0x4d, 0x32, 0x6a, 0xf5, 0xd2, 0xe3,
-	0x4f, 0xc7, 0xfb, 0xc5, 0xea, 0x2d, 0x5c, 0xad, 0x69, 0x13, 0x3a, 0x34, 0x96, 0x6d, 0xc9, 0x23,
-	0x64, 0x3d, 0x12, 0x2b, 0xdf, 0x00, 0x48, 0xe5, 0x23, 0xec, 0x9c, 0x65, 0x39, 0xf9, 0xfc, 0x41,
-	0x7e, 0x07, 0x00, 0x00, 0xff, 0xff, 0xd1, 0xd1, 0x12, 0x1a, 0x80, 0x03, 0x00, 0x00,
+	// 1239 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0x4b, 0x6f, 0x1c, 0xc5,
+	0x13, 0xdf, 0xd9, 0xf7, 0x94, 0xed, 0x75, 0xfe, 0xfd, 0x77, 0x9c, 0x0e, 0x21, 0x61, 0xb3, 0x09,
+	0xc2, 0x8a, 0x84, 0x79, 0x4a, 0x91, 0x90, 0x90, 0x48, 0x88, 0xc3, 0x1a, 0x41, 0x14, 0x75, 0x04,
+	0x07, 0x24, 0x18, 0x8d, 0x67, 0x7a, 0xed, 0x21, 0xb3, 0xdd, 0x4b, 0x4f, 0x8f, 0x91, 0x39, 0x21,
+	0x21, 0x0e, 0x88, 0x3b, 0x17, 0xce, 0x88, 0x2b, 0x07, 0x4e, 0x7c, 0x01, 0xbe, 0x16, 0xea, 0xea,
+	0xee, 0xd9, 0xf1, 0x83, 0x8c, 0x57, 0xe2, 0xd6, 0x55, 0x5d, 0xaf, 0xae, 0xc7, 0xaf, 0x0b, 0xb6,
+	0x17, 0x4a, 0x6a, 0x79, 0x50, 0xce, 0xde, 0x98, 0xf3, 0xa2, 0x88, 0x0f, 0xf9, 0x2e, 0x32, 0xc8,
+	0xd0, 0xf3, 0x27, 0x9f, 0x43, 0xff, 0x59, 0x36, 0x5f, 0xe4, 0x9c, 0xdc, 0x04, 0xe0, 0xc7, 0x5c,
+	0xe8, 0x48, 0xc4, 0x73, 0x4e, 0x83, 0x71, 0x7b, 0x27, 0x64, 0x21, 0x72, 0x9e, 0xc4, 0x73, 0x4e,
+	0x46, 0xd0, 0xce, 0x52, 0xda, 0x1e, 0x07, 0x3b, 0x21, 0x6b, 0x67, 0xa9, 0x11, 0x57, 0xfc, 0x9b,
+	0x92, 0x17, 0x3a, 0xca, 0x52, 0xba, 0x89, 0xfc, 0xd0, 0x71, 0xf6, 0xd3, 0xc9, 0x8f, 0x01, 0x0c,
+	0x1e, 0xf3, 0x58, 0x97, 0xaa, 0xd1, 0xf2, 0x16, 0xf4, 0x0e, 0x95, 0x2c, 0x17, 0xb4, 0x8d, 0x37,
+	0x96, 0x70, 0xfe, 0x3a, 0x95, 0xbf, 0x6d, 0xe8, 0x27, 0x52, 0xaa, 0xb4, 0xa0, 0x5d, 0xe4, 0x39,
+	0xaa, 0x29, 0x8e, 0xbf, 0x02, 0xe8, 0x3f, 0xcd, 0xe3, 0x13, 0xae, 0x2e, 0xfb, 0xc0, 0xb6, 0x73,
+	0x78, 0x05, 0x3a, 0xb9, 0x14, 0xb4, 0x33, 0x6e, 0xef, 0x04, 0xcc, 0x1c, 0x91, 0x13, 0x6b, 0xda,
+	0x75, 0x9c, 0x58, 0x13, 0x02, 0x5d, 0x34, 0xd6, 0x43, 0xb7, 0x5d, 0xe1, 0x9e, 0x93, 0xc8, 0x5c,
+	0x2a, 0xda, 0x47, 0xa6, 0x25, 0x0c, 0x57, 0xcb, 0xe7, 0x5c, 0xd0, 0x81, 0xe5, 0x22, 0xd1, 0x14,
+	0xfc, 0xaf, 0x01, 0xac, 0xd9, 0xe0, 0xa7, 0x3c, 0xcf, 0xe5, 0xaa, 0x25, 0xaa, 0x7c, 0x76, 0xea,
+	0x3e, 0x7d, 0xcc, 0xdd, 0x8b, 0x62, 0xee, 0xd5, 0x63, 0x6e, 0x88, 0xee, 0xfb, 0x00, 0x86, 0x1f,
+	0xc5, 0x73, 0xbe, 0x2f, 0x66, 0x72, 0xd5, 0xe4, 0x12, 0xe8, 0x1e, 0x1a, 0xc1, 0x0e, 0x72, 0xf0,
+	0x6c, 0x78, 0x4a, 0xe6, 0x1c, 0xf3, 0x1b, 0x32, 0x3c, 0x37, 0x85, 0xf0, 0x9b, 0x0b, 0x81, 0xc5,
+	0xe2, 0xf9, 0x7f, 0x11, 0xc2, 0x7d, 0x58, 0x5f, 0x60, 0xbe, 0x8b, 0x48, 0xc5, 0xe2, 0x39, 0xed,
+	0x8e, 0x3b, 0x3b, 0x6b, 0x6f, 0x6f, 0xed, 0xfa, 0x71, 0xd9, 0xb5, 0xd5, 0x30, 0xee, 0xd8, 0x9a,
+	0x93, 0xf4, 0xbe, 0x5f, 0x14, 0xe7, 0x0c, 0x60, 0xa9, 0x69, 0x5a, 0xd9, 0xea, 0xba, 0x20, 0x1d,
+	0x85, 0x7c, 0x99, 0x09, 0x5d, 0x60, 0x94, 0x3d, 0xe6, 0xa8, 0xaa, 0x62, 0x9d, 0x8b, 0x2a, 0xd6,
+	0xad, 0x55, 0x6c, 0x92, 0xc3, 0xf0, 0x51, 0x56, 0xe8, 0x58, 0x24, 0x2b, 0xcf, 0x33, 0x81, 0x6e,
+	0x9a, 0x15, 0xda, 0xf5, 0x3b, 0x9e, 0x9b, 0x5e, 0xf5, 0x43, 0x1b, 0xc2, 0x47, 0x5c, 0xf3, 0x44,
+	0x67, 0x52, 0xac, 0x9a, 0xfe, 0x6b, 0x30, 0x98, 0xf1, 0x18, 0x0d, 0xdb, 0x0a, 0xf4, 0x0d, 0xb9,
+	0x9f, 0x2e, 0xa7, 0x2c, 0xf0, 0x53, 0xe6, 0x26, 0xb1, 0xe7, 0x38, 0x52, 0x90, 0x57, 0x61, 0x53,
+	0xf0, 0x58, 0x45, 0x07, 0x27, 0x91, 0x37, 0x62, 0xa7, 0x6d, 0xdd, 0xb0, 0x1f, 0x9e, 0x3c, 0xb6,
+	0xa6, 0xee, 0xc2, 0xc8, 0x8b, 0xcd, 0xb9, 0xe6, 0xaa, 0xc0, 0xe9, 0x0b, 0xbc, 0xd4, 0xa7, 0xc8,
+	0x23, 0xb7, 0x00, 0x32, 0x61, 0x4e, 0x3c, 0xd1, 0x05, 0x1d, 0xa2, 0x9d, 0x1a, 0xa7, 0x29, 0x0b,
+	0x5f, 0x40, 0xf7, 0x41, 0xa9, 0x8f, 0x2e, 0x81, 0x72, 0x76, 0x18, 0x1d, 0xca, 0x5d, 0x0a, 0x00,
+	0x7e, 0x0a, 0xa0, 0xb7, 0xa7, 0x94, 0x54, 0xab, 0x56, 0x93, 0xc2, 0xc0, 0x21, 0xbe, 0xeb, 0x1a,
+	0x4f, 0x9a, 0x3a, 0x27, 0x32, 0xad, 0xc6, 0xdf, 0x9c, 0x1b, 0xa2, 0xf8, 0xb8, 0x3b, 0xec, 0x5d,
+	0xe9, 0x4f, 0xbe, 0x83, 0xf5, 0x07, 0x65, 0x9a, 0x69, 0x66, 0xf9, 0x4d, 0x11, 0x6d, 0x43, 0x5f,
+	0xce, 0x66, 0x05, 0xd7, 0x18, 0x55, 0x8f, 0x39, 0xca, 0xe4, 0x21, 0xcf, 0xe6, 0x99, 0xc6, 0xb8,
+	0x7a, 0xcc, 0x12, 0x4d, 0x79, 0xf8, 0x3b, 0x00, 0x40, 0xe7, 0x7b, 0x42, 0xab, 0x13, 0xf3, 0x06,
+	0x9d, 0x39, 0xa7, 0x1d, 0x86, 0x67, 0xd3, 0x4f, 0x89, 0x14, 0x22, 0xaa, 0x9a, 0xac, 0x6f, 0xc8,
+	0x7d, 0x4c, 0x45, 0x51, 0x1e, 0x7c, 0xcd, 0x13, 0xed, 0x53, 0xe1, 0xc8, 0x1a, 0xe0, 0x04, 0x15,
+	0xe0, 0x6c, 0x41, 0x0f, 0xdf, 0x40, 0x7b, 0xb6, 0x4c, 0x48, 0x18, 0x1b, 0x8b, 0xf8, 0x24, 0x97,
+	0xb1, 0xef, 0x33, 0x4f, 0x9a, 0x1b, 0x59, 0xea, 0x44, 0xce, 0x39, 0x1d, 0xa0, 0x86, 0x27, 0xd1,
+	0x92, 0x29, 0x9d, 0xeb, 0x28, 0x4b, 0x4c, 0x7e, 0x0f, 0x20, 0xc4, 0x97, 0x3c, 0x35, 0xc5, 0x58,
+	0x21, 0x87, 0xed, 0xd3, 0x39, 0xd4, 0x52, 0xc7, 0x39, 0x4e, 0x4e, 0x8f, 0x59, 0x82, 0xec, 0xc2,
+	0x80, 0x0b, 0xad, 0x32, 0x5e, 0x9c, 0xc7, 0xad, 0x65, 0xf2, 0x98, 0x17, 0x6a, 0xca, 0xf9, 0x97,
+	0x30, 0xf8, 0x24, 0x2b, 0xf4, 0x9e, 0x48, 0x2f, 0xd1, 0xda, 0x89, 0x2c, 0x85, 0x8f, 0xd2, 0x12,
+	0x4d, 0xe6, 0xbf, 0x82, 0x4d, 0x0b, 0x89, 0x4f, 0x78, 0x76, 0x78, 0x74, 0x20, 0x55, 0x41, 0x6e,
+	0x40, 0x68, 0x91, 0xd0, 0x28, 0x58, 0x2f, 0x43, 0xcb, 0xd8, 0x4f, 0xc9, 0x2e, 0x84, 0xc2, 0x4b,
+	0xd2, 0x36, 0xbe, 0xef, 0xca, 0x39, 0x5c, 0x5e, 0x8a, 0x4c, 0xfe, 0xa8, 0xfe, 0xce, 0x87, 0xb1,
+	0x4e, 0x1a, 0xc7, 0xf3, 0x1e, 0x0c, 0x1c, 0x9e, 0xff, 0xab, 0x71, 0x2f, 0x40, 0xee, 0xd7, 0x43,
+	0xe9, 0xa0, 0xf4, 0xf5, 0xb3, 0xd2, 0xd5, 0xab, 0x6a, 0x31, 0x35, 0xa5, 0xe4, 0xe7, 0x00, 0x36,
+	0x9e, 0x1a, 0x33, 0x89, 0xcc, 0x2f, 0xf5, 0xe1, 0x53, 0x18, 0x1c, 0x73, 0x55, 0x64, 0xd2, 0xa2,
+	0xca, 0x06, 0xf3, 0x24, 0x99, 0xc0, 0x7a, 0x12, 0x2f, 0xe2, 0x83, 0x2c, 0xcf, 0x74, 0xc6, 0x6d,
+	0x94, 0x21, 0x3b, 0xc5, 0x6b, 0x8a, 0xe6, 0x97, 0x00, 0x46, 0xcf, 0xb8, 0x3a, 0xe6, 0xea, 0xd9,
+	0x51, 0xa9, 0x53, 0xf9, 0x6d, 0x23, 0xc4, 0xdf, 0x86, 0x75, 0xc5, 0xcd, 0xd4, 0xf1, 0x44, 0x47,
+	0x99, 0xc0, 0xc1, 0xef, 0xb0, 0xb5, 0x8a, 0xb7, 0x2f, 0xc8, 0x1d, 0xd8, 0x58, 0x8a, 0x94, 0x2a,
+	0x77, 0x23, 0xb9, 0xd4, 0xfb, 0x4c, 0xe5, 0x4d, 0x81, 0xfd, 0x39, 0x80, 0xe1, 0x9e, 0x38, 0xe6,
+	0xb9, 0x5c, 0xf0, 0x15, 0x32, 0x34, 0x1a, 0x07, 0xf5, 0x0c, 0xbd, 0xd8, 0x09, 0xb9, 0x07, 0xfd,
+	0x02, 0xf7, 0x62, 0xba, 0x35, 0x0e, 0x4e, 0xb7, 0x83, 0xdd, 0x97, 0xa7, 0x2d, 0xe6, 0x24, 0xc8,
+	0xeb, 0xf6, 0x2b, 0x2b, 0x15, 0xa7, 0x57, 0x51, 0xf8, 0x7f, 0x4b, 0x61, 0xb7, 0x03, 0x4f, 0x5b,
+	0xcc, 0xcb, 0x18, 0xd3, 0xee, 0xfb, 0xdf, 0x3e, 0x6b, 0xda, 0x2d, 0x7b, 0xad, 0x6a, 0x25, 0x78,
+	0xcf, 0x2f, 0x24, 0xd1, 0x91, 0x69, 0x08, 0x7a, 0x0d, 0x35, 0xae, 0x9e, 0xd3, 0x30, 0x97, 0xd3,
+	0x96, 0xdf, 0x49, 0x90, 0x24, 0x6f, 0x41, 0x68, 0x96, 0x9a, 0x28, 0x13, 0x33, 0x49, 0x29, 0x2a,
+	0x92, 0xa5, 0xa2, 0xdf, 0xdc, 0xa6, 0x2d, 0x36, 0x3c, 0x74, 0xe7, 0x4a, 0x05, 0x97, 0x9f, 0xeb,
+	0x17, 0xa9, 0x98, 0x05, 0xc6, 0xab, 0x98, 0x33, 0x79, 0x13, 0x86, 0xa9, 0x5b, 0x39, 0xe8, 0x4b,
+	0x67, 0x35, 0xfc, 0x32, 0x62, 0x34, 0xbc, 0x14, 0x79, 0x07, 0xc2, 0xd4, 0x6f, 0x0d, 0xf4, 0x06,
+	0xaa, 0xfc, 0xbf, 0xa6, 0xe2, 0xaf, 0xa6, 0x2d, 0xb6, 0x94, 0x23, 0x77, 0xa1, 0x1b, 0x97, 0xfa,
+	0x88, 0xbe, 0x8c, 0xf2, 0xa3, 0x3a, 0xb2, 0xe9, 0xa3, 0x69, 0x8b, 0xe1, 0x2d, 0x79, 0xcd, 0x63,
+	0xee, 0x4d, 0x14, 0xdb, 0x5c, 0x8a, 0xe1, 0x2f, 0x3a, 0x6d, 0x39, 0x18, 0x26, 0xef, 0xc3, 0x46,
+	0x6c, 0x20, 0x31, 0x72, 0x15, 0xa7, 0xb7, 0x50, 0x61, 0xfb, 0x0c, 0x62, 0xba, 0xbf, 0x6e, 0xda,
+	0x62, 0xeb, 0x71, 0x8d, 0x26, 0xef, 0x02, 0x58, 0xf5, 0x85, 0xf9, 0x61, 0x5f, 0x39, 0xfb, 0x86,
+	0x0a, 0xe0, 0xcd, 0x1b, 0x62, 0x4f, 0x90, 0x5d, 0x18, 0xe6, 0x59, 0xa1, 0x23, 0x2e, 0x52, 0x3a,
+	0x3e, 0xdb, 0x28, 0x0e, 0x6b, 0x4d, 0xa3, 0xe4, 0xf6, 0x48, 0x3e, 0x80, 0xd1, 0xc2, 0xc1, 0x81,
+	0x2b, 0xff, 0x6d, 0xd4, 0xba, 0x56, 0x2b, 0x7f, 0x1d, 0x2e, 0xa6, 0x2d, 0xb6, 0xb1, 0xa8, 0x33,
+	0x6a, 0xed, 0x73, 0x60, 0x40, 0x90, 0x4e, 0x2e, 0x6e, 0x1f, 0x44, 0xc8, 0x65, 0xfb, 0x20, 0x49,
+	0x3e, 0x84, 0xcd, 0x02, 0xc7, 0x3f, 0x2a, 0xdc, 0xfc, 0xd3, 0x3b, 0xa8, 0x4e, 0x6b, 0xa3, 0x70,
+	0x0a, 0x1f, 0xa6, 0x2d, 0x36, 0x2a, 0x4e, 0x71, 0x1e, 0x86, 0xd5, 0xc7, 0xf9, 0xcf, 0x00, 0x59,
+	0x8b, 0x1e, 0xa5, 0x8c, 0x0e, 0x00, 0x00,
 }
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+// Protocol versions
+const (
+	// ProtocolLegacy is spoken by clients which never send protocol:hello
+	// messages are sent without envelope and with the legacy event names
+	ProtocolLegacy uint32 = 1
+	// ProtocolV2 sends every message on an Envelope and uses the v2 event names
+	ProtocolV2 uint32 = 2
+	// CurrentProtocolVersion is the newest version supported by this server
+	CurrentProtocolVersion = ProtocolV2
+)
+
+// Capabilities negotiated on protocol:hello
+const (
+	// CapErrors clients receive error:<code> replies
+	CapErrors = "errors"
+	// CapListEnd clients receive <event>:end after list replies
+	CapListEnd = "list-end"
+	// CapBatch clients receive remote-players:batch instead of remote-player:updated
+	CapBatch = "batch"
+)
+
+// ServerCapabilities are the capabilities this server can offer
+var ServerCapabilities = []string{CapErrors, CapListEnd, CapBatch}
+
+// v2EventNames maps the legacy event names renamed on ProtocolV2
+var v2EventNames = map[string]string{
+	"player:request-games":   "game:request-around",
+	"player:request-remotes": "remote-player:request-list",
+	"remote-player:destroy":  "remote-player:left",
+}
+
+var legacyEventNames = func() map[string]string {
+	names := make(map[string]string, len(v2EventNames))
+	for legacy, v2 := range v2EventNames {
+		names[v2] = legacy
+	}
+	return names
+}()
+
+// Protocol is the version and the capabilities negotiated with a connection
+// the server handlers always use the legacy event names, Protocol adapts them to the client
+type Protocol struct {
+	Version      uint32
+	Capabilities map[string]bool
+}
+
+// LegacyProtocol is the protocol of connections which didn't negotiate one
+func LegacyProtocol() *Protocol {
+	return &Protocol{Version: ProtocolLegacy, Capabilities: map[string]bool{}}
+}
+
+// NegotiateProtocol picks the client version limited to the current one
+// and the capabilities supported by both sides
+func NegotiateProtocol(version uint32, capabilities []string) *Protocol {
+	p := LegacyProtocol()
+	if version > CurrentProtocolVersion {
+		version = CurrentProtocolVersion
+	}
+	if version > ProtocolLegacy {
+		p.Version = version
+	}
+	for _, c := range capabilities {
+		for _, supported := range ServerCapabilities {
+			if c == supported {
+				p.Capabilities[c] = true
+			}
+		}
+	}
+	return p
+}
+
+// Has the capability been negotiated
+func (p *Protocol) Has(capability string) bool {
+	return p.Capabilities[capability]
+}
+
+// CapabilityList returns the negotiated capabilities sorted by name
+func (p *Protocol) CapabilityList() []string {
+	list := make([]string, 0, len(p.Capabilities))
+	for c := range p.Capabilities {
+		list = append(list, c)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Envelope tells if messages are framed on envelopes
+func (p *Protocol) Envelope() bool {
+	return p.Version >= ProtocolV2
+}
+
+// InboundEvent translates the client event name to the server one
+func (p *Protocol) InboundEvent(event string) string {
+	if p.Version >= ProtocolV2 {
+		if legacy, exists := legacyEventNames[event]; exists {
+			return legacy
+		}
+	}
+	return event
+}
+
+// OutboundEvent translates the server event name to the client one
+// it returns false when the client can't handle the event
+func (p *Protocol) OutboundEvent(event string) (string, bool) {
+	if strings.HasPrefix(event, "error:") && !p.Has(CapErrors) {
+		return "", false
+	}
+	if strings.HasSuffix(event, ":end") && !p.Has(CapListEnd) {
+		return "", false
+	}
+	if event == "remote-players:batch" && !p.Has(CapBatch) {
+		return "", false
+	}
+	if p.Version >= ProtocolV2 {
+		if v2, exists := v2EventNames[event]; exists {
+			return v2, true
+		}
+	}
+	return event, true
+}
+
+// Adapt returns message as the client expects it or nil when it must not be sent
+func (p *Protocol) Adapt(message Message) proto.Message {
+	event, supported := p.OutboundEvent(message.GetEventName())
+	if !supported {
+		return nil
+	}
+	if p.Version >= ProtocolV2 {
+		message = renameEvents(message, event)
+	}
+	if !p.Envelope() {
+		return message
+	}
+	env, err := NewEnvelope(p.Version, message)
+	if err != nil {
+		return message
+	}
+	return env
+}
+
+// renameEvents sets the v2 event names on message and on error ids
+// messages are shared by broadcasts, so they are copied before changed
+func renameEvents(message Message, event string) Message {
+	errMsg, isError := message.(*protobuf.Error)
+	_, renamedID := v2EventNames[errMsg.GetId()]
+	if event == message.GetEventName() && !(isError && renamedID) {
+		return message
+	}
+	renamed := proto.Clone(message).(Message)
+	field := reflect.ValueOf(renamed).Elem().FieldByName("EventName")
+	if field.IsValid() && field.CanSet() {
+		field.Set(reflect.ValueOf(proto.String(event)))
+	}
+	if isError && renamedID {
+		renamed.(*protobuf.Error).Id = proto.String(v2EventNames[errMsg.GetId()])
+	}
+	return renamed
+}
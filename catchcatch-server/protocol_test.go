@@ -0,0 +1,169 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+type protocolTestClient struct {
+	name         string
+	codec        string
+	version      uint32
+	capabilities []string
+	hello        bool
+}
+
+var protocolTestClients = []protocolTestClient{
+	{name: "legacy protobuf", codec: "protobuf"},
+	{name: "legacy json", codec: "json"},
+	{name: "v1 with capabilities", codec: "protobuf", hello: true, version: ProtocolLegacy,
+		capabilities: []string{CapErrors, CapListEnd}},
+	{name: "v2 protobuf", codec: "protobuf", hello: true, version: ProtocolV2,
+		capabilities: []string{CapErrors, CapListEnd}},
+	{name: "v2 json", codec: "json", hello: true, version: ProtocolV2,
+		capabilities: []string{CapErrors, CapListEnd}},
+	{name: "future version", codec: "protobuf", hello: true, version: 99},
+}
+
+func (tc protocolTestClient) connect(t *testing.T) (*WSConnListener, *fakeWSConn) {
+	conn := &fakeWSConn{request: httptest.NewRequest("GET", "/ws?codec="+tc.codec, nil)}
+	c := NewWSServer(nil, SendQueueConfig{}, nil).Add(conn)
+	if tc.hello {
+		tc.send(t, c, conn, &protobuf.ProtocolHello{EventName: proto.String("protocol:hello"),
+			Version: proto.Uint32(tc.version), Capabilities: tc.capabilities})
+		if event := tc.received(t, c, conn); len(event) != 1 || event[0] != "protocol:welcome" {
+			t.Fatal(tc.name, "expected protocol:welcome, got:", event)
+		}
+	}
+	return c, conn
+}
+
+func (tc protocolTestClient) send(t *testing.T, c *WSConnListener, conn *fakeWSConn, message Message) {
+	var wire proto.Message = message
+	if tc.version >= ProtocolV2 && message.GetEventName() != "protocol:hello" {
+		env, err := NewEnvelope(ProtocolV2, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wire = env
+	}
+	payload, err := c.Codec().Marshal(wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.frames = append(conn.frames, payload)
+	if err := c.readMessage(); err != nil {
+		t.Fatal(tc.name, err)
+	}
+}
+
+// protocolTestReplies are the message types of the events sent to the clients
+var protocolTestReplies = map[string]func() proto.Message{
+	"protocol:welcome":      func() proto.Message { return &protobuf.ProtocolHello{} },
+	"player:registered":     func() proto.Message { return &protobuf.Player{} },
+	"game:started":          func() proto.Message { return &protobuf.GameInfo{} },
+	"remote-player:destroy": func() proto.Message { return &protobuf.Player{} },
+	"remote-player:left":    func() proto.Message { return &protobuf.Player{} },
+	"game:loose":            func() proto.Message { return &protobuf.Simple{} },
+	"game:around:end":       func() proto.Message { return &protobuf.ListEnd{} },
+	"error:internal":        func() proto.Message { return &protobuf.Error{} },
+}
+
+func (tc protocolTestClient) reply(t *testing.T, event string) proto.Message {
+	newReply, known := protocolTestReplies[event]
+	if !known {
+		t.Fatal(tc.name, "unexpected event:", event)
+	}
+	return newReply()
+}
+
+// received returns the event names sent to the client since the last call
+// each reply is decoded into the message type of its event
+func (tc protocolTestClient) received(t *testing.T, c *WSConnListener, conn *fakeWSConn) []string {
+	events := make([]string, 0)
+	for _, payload := range conn.sent {
+		// the event name is read as the server reads it from the clients
+		env := &protobuf.Envelope{}
+		if err := c.Codec().Unmarshal(payload, env); err != nil {
+			t.Fatal(tc.name, err)
+		}
+		msg := tc.reply(t, env.GetEventName())
+		if tc.version >= ProtocolV2 {
+			if env.GetVersion() != ProtocolV2 || reflect.TypeOf(EnvelopePayload(env)) != reflect.TypeOf(msg) {
+				t.Fatalf("%s expected envelope with %T, got: %v", tc.name, msg, env)
+			}
+		} else if err := c.Codec().Unmarshal(payload, msg); err != nil {
+			t.Fatal(tc.name, env.GetEventName(), err)
+		}
+		events = append(events, env.GetEventName())
+	}
+	conn.sent = nil
+	return events
+}
+
+func TestProtocolMatrixServerEvents(t *testing.T) {
+	player := &protobuf.Player{EventName: proto.String("player:registered"), Id: proto.String("p1"),
+		Lat: proto.Float64(1), Lon: proto.Float64(2)}
+	messages := []Message{
+		player,
+		&protobuf.GameInfo{EventName: proto.String("game:started"), Id: proto.String("g1"), Game: proto.String("g1"),
+			Role: proto.String("hunter")},
+		&protobuf.Player{EventName: proto.String("remote-player:destroy"), Id: proto.String("p2"),
+			Lat: proto.Float64(1), Lon: proto.Float64(2)},
+		&protobuf.Simple{EventName: proto.String("game:loose"), Id: proto.String("g1")},
+		listEndMessage("game:around", 0, nil),
+		&protobuf.Error{EventName: proto.String("error:internal"), Id: proto.String("player:request-games")},
+	}
+	expected := map[string][]string{
+		"legacy protobuf":      {"player:registered", "game:started", "remote-player:destroy", "game:loose"},
+		"legacy json":          {"player:registered", "game:started", "remote-player:destroy", "game:loose"},
+		"v1 with capabilities": {"player:registered", "game:started", "remote-player:destroy", "game:loose", "game:around:end", "error:internal"},
+		"v2 protobuf":          {"player:registered", "game:started", "remote-player:left", "game:loose", "game:around:end", "error:internal"},
+		"v2 json":              {"player:registered", "game:started", "remote-player:left", "game:loose", "game:around:end", "error:internal"},
+		"future version":       {"player:registered", "game:started", "remote-player:left", "game:loose"},
+	}
+
+	for _, tc := range protocolTestClients {
+		c, conn := tc.connect(t)
+		for _, m := range messages {
+			if err := c.Emit(m); err != nil {
+				t.Fatal(tc.name, err)
+			}
+		}
+		events := tc.received(t, c, conn)
+		if len(events) != len(expected[tc.name]) {
+			t.Fatal(tc.name, "expected", expected[tc.name], "got", events)
+		}
+		for i := range events {
+			if events[i] != expected[tc.name][i] {
+				t.Fatal(tc.name, "expected", expected[tc.name], "got", events)
+			}
+		}
+	}
+	if player.GetEventName() != "player:registered" {
+		t.Fatal("shared message must not be changed:", player)
+	}
+}
+
+func TestProtocolMatrixClientEvents(t *testing.T) {
+	for _, tc := range protocolTestClients {
+		c, conn := tc.connect(t)
+		event := "player:request-games"
+		if tc.version >= ProtocolV2 {
+			event = "game:request-around"
+		}
+
+		called := false
+		HandleEvent(c, "player:request-games", func(*Request, *protobuf.Simple) {
+			called = true
+		})
+		tc.send(t, c, conn, &protobuf.Simple{EventName: proto.String(event)})
+		if !called {
+			t.Fatal(tc.name, "expected player:request-games callback to be called by", event)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrConnNotFound happens when addressing a connection which is not on this server
+var ErrConnNotFound = errors.New("connection not found")
+
+// RoomAdmin receives the admin feeds, admins and observers join it when they connect
+const RoomAdmin = "admin"
+
+// GameRoom is the room of the players of a running game
+func GameRoom(gameID string) string {
+	return "game:" + gameID
+}
+
+// GeofenceRoom is the room of the players inside a geofence
+func GeofenceRoom(geofenceID string) string {
+	return "geofence:" + geofenceID
+}
+
+type roomMembers map[*WSConnListener]bool
+
+// rooms are named groups of connections
+// connections are stored by reference so they keep their rooms when renamed
+type rooms struct {
+	members map[string]roomMembers
+	sync.RWMutex
+}
+
+func newRooms() *rooms {
+	return &rooms{members: make(map[string]roomMembers)}
+}
+
+func (r *rooms) join(room string, c *WSConnListener) {
+	r.Lock()
+	defer r.Unlock()
+	members, exists := r.members[room]
+	if !exists {
+		members = make(roomMembers)
+		r.members[room] = members
+	}
+	members[c] = true
+}
+
+func (r *rooms) leave(room string, c *WSConnListener) {
+	r.Lock()
+	defer r.Unlock()
+	if members, exists := r.members[room]; exists {
+		delete(members, c)
+		if len(members) == 0 {
+			delete(r.members, room)
+		}
+	}
+}
+
+func (r *rooms) leaveAll(c *WSConnListener) {
+	r.Lock()
+	defer r.Unlock()
+	for room, members := range r.members {
+		delete(members, c)
+		if len(members) == 0 {
+			delete(r.members, room)
+		}
+	}
+}
+
+func (r *rooms) close(room string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.members, room)
+}
+
+func (r *rooms) list(room string) []*WSConnListener {
+	r.RLock()
+	defer r.RUnlock()
+	list := make([]*WSConnListener, 0, len(r.members[room]))
+	for c := range r.members[room] {
+		list = append(list, c)
+	}
+	return list
+}
+
+// Join adds the connection id to room, connections of other nodes join their node's room
+func (wss *WSServer) Join(room, id string) error {
+	return wss.roomOp(room, id, RoomOpJoin, wss.rooms.join)
+}
+
+// Leave removes the connection id from room
+func (wss *WSServer) Leave(room, id string) error {
+	return wss.roomOp(room, id, RoomOpLeave, wss.rooms.leave)
+}
+
+func (wss *WSServer) roomOp(room, id string, op RoomOp, local func(string, *WSConnListener)) error {
+	if c := wss.Get(id); c != nil {
+		local(room, c)
+		return nil
+	}
+	node, err := wss.lookup(id)
+	if err != nil {
+		return err
+	}
+	return wss.publishBus(node, &BusMessage{To: []string{id}, Room: room, RoomOp: op}, nil)
+}
+
+// CloseRoom removes every connection from room on every node
+func (wss *WSServer) CloseRoom(room string) {
+	wss.rooms.close(room)
+	if wss.bus == nil {
+		return
+	}
+	if err := wss.publishBus("", &BusMessage{Room: room, RoomOp: RoomOpClose}, nil); err != nil {
+		wss.logger.Error("error to close room on other nodes", "room", room, "error", err)
+	}
+}
+
+// RoomMembers returns the ids of the connections of this node in room
+func (wss *WSServer) RoomMembers(room string) []string {
+	members := wss.rooms.list(room)
+	ids := make([]string, len(members))
+	for i, c := range members {
+		ids[i] = c.ID()
+	}
+	return ids
+}
+
+// BroadcastToRoom sends message to the connections in room on every node
+// it returns the last error but doesn't stop on failures
+func (wss *WSServer) BroadcastToRoom(room string, message Message) error {
+	lastErr := wss.broadcastRoomLocal(room, message)
+	if wss.bus != nil {
+		if err := wss.publishBus("", &BusMessage{Room: room}, message); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (wss *WSServer) broadcastRoomLocal(room string, message Message) error {
+	var lastErr error
+	for _, c := range wss.rooms.list(room) {
+		if err := c.Emit(message); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
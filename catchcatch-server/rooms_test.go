@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+func TestRoomsBroadcastAndCleanup(t *testing.T) {
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	player, admin, outsider := &fakeWSConn{}, &fakeWSConn{}, &fakeWSConn{}
+	playerConn := server.Add(player)
+	adminConn := server.Add(admin)
+	server.Add(outsider)
+
+	server.Join(GameRoom("g1"), playerConn.ID())
+	server.Join(GameRoom("g1"), adminConn.ID())
+	server.Join(RoomAdmin, adminConn.ID())
+	if err := server.Join(RoomAdmin, "unknown"); err != ErrConnNotFound {
+		t.Fatal("expected ErrConnNotFound, got:", err)
+	}
+
+	server.Rename(playerConn, "p1")
+	server.BroadcastToRoom(GameRoom("g1"), &protobuf.Simple{EventName: proto.String("game:finish")})
+	server.BroadcastToRoom(RoomAdmin, &protobuf.Simple{EventName: proto.String("admin:feature:added")})
+	if len(player.sent) != 1 || len(admin.sent) != 2 || len(outsider.sent) != 0 {
+		t.Fatal("unexpected messages:", len(player.sent), len(admin.sent), len(outsider.sent))
+	}
+
+	server.Remove("p1")
+	if members := server.RoomMembers(GameRoom("g1")); len(members) != 1 || members[0] != adminConn.ID() {
+		t.Fatal("expected removed connection to leave its rooms, got:", members)
+	}
+	server.CloseRoom(GameRoom("g1"))
+	if members := server.RoomMembers(GameRoom("g1")); len(members) != 0 {
+		t.Fatal("expected closed room to be empty, got:", members)
+	}
+}
+
+func TestRoomsLeaveAndClose(t *testing.T) {
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	first, second := &fakeWSConn{}, &fakeWSConn{}
+	firstConn, secondConn := server.Add(first), server.Add(second)
+	server.Join(GameRoom("g1"), firstConn.ID())
+	server.Join(GameRoom("g1"), secondConn.ID())
+	server.Join(GameRoom("g2"), secondConn.ID())
+
+	if err := server.Leave(GameRoom("g1"), firstConn.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Leave(GameRoom("g1"), "unknown"); err != ErrConnNotFound {
+		t.Fatal("expected ErrConnNotFound, got:", err)
+	}
+	server.BroadcastToRoom(GameRoom("g1"), &protobuf.Simple{EventName: proto.String("game:finish")})
+	if len(first.sent) != 0 || len(second.sent) != 1 {
+		t.Fatal("expected the connection to leave the room:", len(first.sent), len(second.sent))
+	}
+
+	server.CloseRoom(GameRoom("g1"))
+	server.BroadcastToRoom(GameRoom("g1"), &protobuf.Simple{EventName: proto.String("game:finish")})
+	if len(second.sent) != 1 {
+		t.Fatal("expected the closed room to send nothing, got:", len(second.sent))
+	}
+	if members := server.RoomMembers(GameRoom("g2")); len(members) != 1 || members[0] != secondConn.ID() {
+		t.Fatal("expected other rooms to be kept, got:", members)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrSlowConsumer happens when a connection send queue is full and nothing can be dropped
+	ErrSlowConsumer = errors.New("send queue full: slow consumer")
+	// ErrSendQueueClosed happens when sending to a closed connection
+	ErrSendQueueClosed = errors.New("send queue closed")
+	// ErrInvalidSendPolicy happens when parsing an unknown SendPolicy
+	ErrInvalidSendPolicy = errors.New("invalid send queue policy")
+)
+
+// SendPolicy is what happens when a connection send queue is full
+type SendPolicy string
+
+const (
+	// DropOldestPosition drops the oldest queued position update
+	// slow consumers are disconnected when there is no position update to drop
+	DropOldestPosition SendPolicy = "drop-oldest-position"
+	// DisconnectSlow disconnects slow consumers as soon as their queue is full
+	DisconnectSlow SendPolicy = "disconnect"
+)
+
+// ParseSendPolicy validates send policy names
+func ParseSendPolicy(policy string) (SendPolicy, error) {
+	switch p := SendPolicy(policy); p {
+	case DropOldestPosition, DisconnectSlow:
+		return p, nil
+	}
+	return "", ErrInvalidSendPolicy
+}
+
+// positionEvents are frequent updates which are replaced by the next ones
+var positionEvents = map[string]bool{
+	"player:updated":        true,
+	"remote-player:updated": true,
+	"remote-players:batch":  true,
+}
+
+// SendQueueConfig configures the connections send queues
+type SendQueueConfig struct {
+	// Size is the max number of queued messages, zero sends synchronously
+	Size   int
+	Policy SendPolicy
+}
+
+// DefaultSendQueue keeps 256 messages per connection
+var DefaultSendQueue = SendQueueConfig{Size: 256, Policy: DropOldestPosition}
+
+// SendQueueStats are the send queue counters of all connections of a WSServer
+type SendQueueStats struct {
+	// Depth is the number of messages waiting to be sent
+	Depth int64 `json:"depth"`
+	// Dropped is the number of position updates dropped
+	Dropped int64 `json:"dropped"`
+	// Disconnected is the number of slow consumers disconnected
+	Disconnected int64 `json:"disconnected"`
+}
+
+type queuedMessage struct {
+	event   string
+	payload []byte
+	text    bool
+}
+
+type sendQueue struct {
+	size   int
+	policy SendPolicy
+	stats  *SendQueueStats
+
+	items  []queuedMessage
+	ready  chan struct{}
+	closed bool
+	sync.Mutex
+}
+
+func newSendQueue(config SendQueueConfig, stats *SendQueueStats) *sendQueue {
+	return &sendQueue{size: config.Size, policy: config.Policy, stats: stats,
+		items: make([]queuedMessage, 0, config.Size), ready: make(chan struct{}, 1)}
+}
+
+func (q *sendQueue) push(item queuedMessage) error {
+	q.Lock()
+	defer q.Unlock()
+	if q.closed {
+		return ErrSendQueueClosed
+	}
+	if len(q.items) >= q.size {
+		if q.policy != DropOldestPosition || !q.dropOldestPosition(item) {
+			atomic.AddInt64(&q.stats.Disconnected, 1)
+			return ErrSlowConsumer
+		}
+		if len(q.items) >= q.size {
+			// item was the position update dropped
+			return nil
+		}
+	}
+	q.items = append(q.items, item)
+	atomic.AddInt64(&q.stats.Depth, 1)
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// dropOldestPosition removes the oldest queued position update
+// or tells item must be dropped when it is the oldest one
+func (q *sendQueue) dropOldestPosition(item queuedMessage) bool {
+	for i, queued := range q.items {
+		if positionEvents[queued.event] {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			atomic.AddInt64(&q.stats.Depth, -1)
+			atomic.AddInt64(&q.stats.Dropped, 1)
+			return true
+		}
+	}
+	if positionEvents[item.event] {
+		atomic.AddInt64(&q.stats.Dropped, 1)
+		return true
+	}
+	return false
+}
+
+// pop waits for the next message, it returns false when the queue is closed and empty
+func (q *sendQueue) pop() (queuedMessage, bool) {
+	for {
+		q.Lock()
+		if len(q.items) > 0 {
+			item := q.items[0]
+			q.items[0] = queuedMessage{}
+			q.items = q.items[1:]
+			atomic.AddInt64(&q.stats.Depth, -1)
+			q.Unlock()
+			return item, true
+		}
+		closed := q.closed
+		q.Unlock()
+		if closed {
+			return queuedMessage{}, false
+		}
+		<-q.ready
+	}
+}
+
+// close stops accepting messages, the queued ones are still sent
+func (q *sendQueue) close() {
+	q.Lock()
+	defer q.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	close(q.ready)
+}
+
+// discard drops the messages which were not sent
+func (q *sendQueue) discard() {
+	q.Lock()
+	defer q.Unlock()
+	atomic.AddInt64(&q.stats.Depth, -int64(len(q.items)))
+	q.items = nil
+}
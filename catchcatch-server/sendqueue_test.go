@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestSendQueueDropsOldestPosition(t *testing.T) {
+	stats := &SendQueueStats{}
+	q := newSendQueue(SendQueueConfig{Size: 2, Policy: DropOldestPosition}, stats)
+	q.push(queuedMessage{event: "remote-player:updated", payload: []byte("p1")})
+	q.push(queuedMessage{event: "game:started"})
+	if err := q.push(queuedMessage{event: "remote-player:updated", payload: []byte("p2")}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := q.pop()
+	second, _ := q.pop()
+	if first.event != "game:started" || string(second.payload) != "p2" {
+		t.Fatal("expected oldest position update to be dropped, got:", first, second)
+	}
+	if stats.Dropped != 1 || stats.Depth != 0 {
+		t.Fatal("unexpected stats:", stats)
+	}
+}
+
+func TestSendQueueDisconnectsSlowConsumers(t *testing.T) {
+	stats := &SendQueueStats{}
+	q := newSendQueue(SendQueueConfig{Size: 1, Policy: DropOldestPosition}, stats)
+	q.push(queuedMessage{event: "game:started"})
+	if err := q.push(queuedMessage{event: "remote-player:updated"}); err != nil {
+		t.Fatal("expected new position update to be dropped, got:", err)
+	}
+	if err := q.push(queuedMessage{event: "game:loose"}); err != ErrSlowConsumer {
+		t.Fatal("expected ErrSlowConsumer, got:", err)
+	}
+
+	q = newSendQueue(SendQueueConfig{Size: 1, Policy: DisconnectSlow}, stats)
+	q.push(queuedMessage{event: "remote-player:updated"})
+	if err := q.push(queuedMessage{event: "remote-player:updated"}); err != ErrSlowConsumer {
+		t.Fatal("expected ErrSlowConsumer, got:", err)
+	}
+	if stats.Disconnected != 2 || stats.Dropped != 1 {
+		t.Fatal("unexpected stats:", stats)
+	}
+}
+
+func TestSendQueueSendsQueuedMessagesOnClose(t *testing.T) {
+	q := newSendQueue(SendQueueConfig{Size: 2, Policy: DisconnectSlow}, &SendQueueStats{})
+	q.push(queuedMessage{event: "error:unauthenticated"})
+	q.close()
+	if err := q.push(queuedMessage{event: "game:started"}); err != ErrSendQueueClosed {
+		t.Fatal("expected ErrSendQueueClosed, got:", err)
+	}
+	if item, ok := q.pop(); !ok || item.event != "error:unauthenticated" {
+		t.Fatal("expected queued message, got:", item, ok)
+	}
+	if _, ok := q.pop(); ok {
+		t.Fatal("expected closed queue")
+	}
+}
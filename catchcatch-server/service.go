@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+
 	geo "github.com/kellydunn/golang-geo"
 	"github.com/perenecabuto/CatchCatch/catchcatch-server/model"
 	gjson "github.com/tidwall/gjson"
@@ -13,12 +15,18 @@ type PlayerLocationService interface {
 	Update(p *model.Player) error
 	Remove(p *model.Player) error
 	Players() (model.PlayerList, error)
+	PlayersAround(point *geo.Point, meters float64) (model.PlayerList, error)
+	PlayersWithin(f *model.Feature) (model.PlayerList, error)
 
 	AddFeature(group, id, geojson string) (*model.Feature, error)
 	Features(group string) ([]*model.Feature, error)
 	FeaturesAround(group string, point *geo.Point) ([]*model.Feature, error)
+	FeaturesAt(group string, point *geo.Point) ([]*model.Feature, error)
 
-	Clear()
+	// Clear removes the players and features, the player profiles are kept
+	Clear() error
+	// Ping checks if the service is reachable
+	Ping() error
 }
 
 // Tile38PlayerLocationService manages player locations
@@ -56,6 +64,30 @@ func (s *Tile38PlayerLocationService) Players() (model.PlayerList, error) {
 	if err != nil {
 		return nil, err
 	}
+	return playersFromFeatures(features), nil
+}
+
+// PlayersAround return the players within meters of point
+func (s *Tile38PlayerLocationService) PlayersAround(point *geo.Point, meters float64) (model.PlayerList, error) {
+	cmd := redis.NewSliceCmd("NEARBY", "player", "POINT", point.Lat(), point.Lng(), meters)
+	features, err := featuresFromSliceCmd(s.client, "player", cmd)
+	if err != nil {
+		return nil, err
+	}
+	return playersFromFeatures(features), nil
+}
+
+// PlayersWithin return the players inside the feature area
+func (s *Tile38PlayerLocationService) PlayersWithin(f *model.Feature) (model.PlayerList, error) {
+	cmd := redis.NewSliceCmd("WITHIN", "player", "GET", f.Group, f.ID)
+	features, err := featuresFromSliceCmd(s.client, "player", cmd)
+	if err != nil {
+		return nil, err
+	}
+	return playersFromFeatures(features), nil
+}
+
+func playersFromFeatures(features []*model.Feature) model.PlayerList {
 	list := make(model.PlayerList, len(features))
 	for i, f := range features {
 		coords := gjson.Get(f.Coordinates, "coordinates").Array()
@@ -64,7 +96,7 @@ func (s *Tile38PlayerLocationService) Players() (model.PlayerList, error) {
 		}
 		list[i] = &model.Player{ID: f.ID, Lat: coords[1].Float(), Lon: coords[0].Float()}
 	}
-	return list, nil
+	return list
 }
 
 // AddFeature persist features
@@ -90,9 +122,33 @@ func (s *Tile38PlayerLocationService) FeaturesAround(group string, point *geo.Po
 	return featuresFromSliceCmd(s.client, group, cmd)
 }
 
-// Clear the database
-func (s *Tile38PlayerLocationService) Clear() {
-	s.client.FlushDb()
+// FeaturesAt return the features of group which contain point
+func (s *Tile38PlayerLocationService) FeaturesAt(group string, point *geo.Point) ([]*model.Feature, error) {
+	geojson := fmt.Sprintf(`{"type":"Point","coordinates":[%f,%f]}`, point.Lng(), point.Lat())
+	cmd := redis.NewSliceCmd("INTERSECTS", group, "OBJECT", geojson)
+	return featuresFromSliceCmd(s.client, group, cmd)
+}
+
+// Clear drops every collection but the player profiles
+func (s *Tile38PlayerLocationService) Clear() error {
+	collections, err := s.client.Keys("*").Result()
+	if err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		if collection == ProfileCollection {
+			continue
+		}
+		if err := s.client.Process(redis.NewCmd("DROP", collection)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping implements PlayerLocationService.Ping
+func (s *Tile38PlayerLocationService) Ping() error {
+	return s.client.Ping().Err()
 }
 
 func featuresFromSliceCmd(client *redis.Client, group string, cmd *redis.SliceCmd) ([]*model.Feature, error) {
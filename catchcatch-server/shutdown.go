@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+// ErrServerShuttingDown happens when connecting to a server which is shutting down
+var ErrServerShuttingDown = errors.New("server shutting down")
+
+// DefaultShutdownTimeout is the max time to finish games and drain connections on exit
+const DefaultShutdownTimeout = 10 * time.Second
+
+// shutdownPollInterval is the time between checks of what is left to drain
+const shutdownPollInterval = 50 * time.Millisecond
+
+// ShutdownHint tells the clients how to reconnect on server:shutdown
+type ShutdownHint struct {
+	// ReconnectIn is the time clients should wait before reconnecting
+	ReconnectIn time.Duration
+	// ReconnectURL is the server clients should reconnect to, empty means the same one
+	ReconnectURL string
+}
+
+// DefaultShutdownHint asks clients to reconnect to the same address after 5s
+var DefaultShutdownHint = ShutdownHint{ReconnectIn: 5 * time.Second}
+
+// Draining tells if the server stopped accepting connections
+func (wss *WSServer) Draining() bool {
+	return atomic.LoadInt32(&wss.draining) == 1
+}
+
+// StopAccepting refuses new connections, the current ones keep working
+func (wss *WSServer) StopAccepting() {
+	atomic.StoreInt32(&wss.draining, 1)
+}
+
+// Shutdown sends server:shutdown to the connections and closes them
+// queued messages are still sent, it waits until every connection is gone or ctx is done
+func (wss *WSServer) Shutdown(ctx context.Context, hint ShutdownHint) error {
+	wss.StopAccepting()
+	msg := &protobuf.ServerShutdown{EventName: proto.String("server:shutdown"),
+		ReconnectIn: proto.Int64(int64(hint.ReconnectIn / time.Millisecond))}
+	if hint.ReconnectURL != "" {
+		msg.ReconnectUrl = proto.String(hint.ReconnectURL)
+	}
+	wss.broadcastLocal(msg)
+	wss.CloseAll()
+	return waitUntil(ctx, func() bool {
+		return len(wss.connections.Load().(connectionGroup)) == 0
+	})
+}
+
+// Shutdown stops starting games and finishes the running ones so players get their rank
+// it waits until every game is finished or ctx is done
+func (gw *GameWatcher) Shutdown(ctx context.Context) error {
+	gw.Lock()
+	gw.closed = true
+	games := make([]*GameContext, 0, len(gw.games))
+	for _, watched := range gw.games {
+		games = append(games, watched)
+	}
+	gw.Unlock()
+
+	for _, watched := range games {
+		if watched.game.Running() {
+			gw.logger.Info("finishing game on shutdown", LogGameID, watched.game.ID)
+			watched.game.Stop()
+		} else {
+			watched.cancel()
+		}
+	}
+	return waitUntil(ctx, func() bool {
+		gw.Lock()
+		defer gw.Unlock()
+		return len(gw.games) == 0
+	})
+}
+
+func waitUntil(ctx context.Context, done func() bool) error {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+// blockingWSConn reads nothing until it is closed
+type blockingWSConn struct {
+	fakeWSConn
+	closed chan struct{}
+	once   sync.Once
+	sync.Mutex
+}
+
+func (c *blockingWSConn) Read(buf *[]byte) (int, error) {
+	<-c.closed
+	return 0, io.EOF
+}
+
+func (c *blockingWSConn) Send(payload []byte) error {
+	c.Lock()
+	defer c.Unlock()
+	return c.fakeWSConn.Send(payload)
+}
+
+func (c *blockingWSConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+type stubWSDriver struct {
+	conns chan *blockingWSConn
+}
+
+func (d stubWSDriver) Handler(ctx context.Context, onConnect func(context.Context, WSConnection)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn := &blockingWSConn{fakeWSConn: fakeWSConn{request: r}, closed: make(chan struct{})}
+		d.conns <- conn
+		onConnect(ctx, conn)
+	})
+}
+
+func TestWSServerShutdownNotifiesAndDrainsConnections(t *testing.T) {
+	driver := stubWSDriver{conns: make(chan *blockingWSConn, 1)}
+	server := NewWSServer(driver, SendQueueConfig{}, nil)
+	handler := server.Listen(context.Background())
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ws", nil))
+	conn := <-driver.conns
+	waitFor(t, "the connection to be added", func() bool { return len(server.connections.Load().(connectionGroup)) == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx, ShutdownHint{ReconnectIn: 3 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	conn.Lock()
+	defer conn.Unlock()
+	msg := &protobuf.ServerShutdown{}
+	if len(conn.sent) != 1 || proto.Unmarshal(conn.sent[0], msg) != nil || msg.GetReconnectIn() != 3000 {
+		t.Fatal("expected server:shutdown with reconnect hint, got:", msg)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/ws", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatal("expected new connections to be refused, got:", recorder.Code)
+	}
+}
+
+func TestGameWatcherShutdownStopsWatchingGames(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gw := NewGameWatcher(idleEventStream{}, NewWSServer(nil, SendQueueConfig{}, nil), nil,
+		GameLease{Locks: NewMemoryLock(), Owner: "a", TTL: time.Second}, DefaultGameRules, nil, nil)
+	go gw.watchGame(ctx, "g1")
+	waitFor(t, "g1 to be watched", func() bool { return gw.watching("g1") })
+
+	shutdownCtx, done := context.WithTimeout(ctx, time.Second)
+	defer done()
+	if err := gw.Shutdown(shutdownCtx); err != nil {
+		t.Fatal(err)
+	}
+	if gw.watchGame(ctx, "g2"); gw.watching("g2") {
+		t.Fatal("expected no new game after shutdown")
+	}
+}
+
+func TestGameWatcherShutdownFinishesJustStartedGames(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rules := DefaultGameRules
+	rules.MinPlayers = 10
+	gw := NewGameWatcher(idleEventStream{}, NewWSServer(nil, SendQueueConfig{}, nil), memoryProfileStore{},
+		GameLease{Locks: NewMemoryLock(), Owner: "a", TTL: time.Minute}, rules, nil, nil)
+	go gw.watchGame(ctx, "g1")
+	waitFor(t, "g1 to be watched", func() bool { return gw.watching("g1") })
+	gw.Lock()
+	game := gw.games["g1"].game
+	gw.Unlock()
+	game.SetPlayer("p1", -46.63, -23.55)
+	game.SetPlayer("p2", -46.63, -23.55)
+	if err := game.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	shutdownCtx, done := context.WithTimeout(ctx, time.Second)
+	defer done()
+	if err := gw.Shutdown(shutdownCtx); err != nil {
+		t.Fatal("expected the started game to finish on shutdown, got:", err)
+	}
+}
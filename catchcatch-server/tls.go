@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrOriginNotAllowed happens when a WS upgrade comes from an origin not allowed
+var ErrOriginNotAllowed = errors.New("origin not allowed")
+
+// CertReloader serves a TLS certificate and reloads it when its files change
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+	logger   *slog.Logger
+	sync.RWMutex
+}
+
+// NewCertReloader loads the certificate from certFile and keyFile
+func NewCertReloader(certFile, keyFile string, logger *slog.Logger) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, logger: loggerOrDefault(logger)}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cert, nil
+}
+
+// Reload loads the certificate when the files changed since the last load
+// the current certificate is kept when the new one is invalid
+func (r *CertReloader) Reload() (bool, error) {
+	modTimes, err := fileModTimes(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+	r.RLock()
+	changed := modTimes != r.modTimes
+	r.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+	r.Lock()
+	r.cert, r.modTimes = &cert, modTimes
+	r.Unlock()
+	return true, nil
+}
+
+// Watch reloads the certificate every interval until ctx is done
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				r.logger.Error("error to reload tls certificate, keeping the current one", "cert", r.certFile, "error", err)
+			} else if reloaded {
+				r.logger.Info("tls certificate reloaded", "cert", r.certFile)
+			}
+		}
+	}
+}
+
+func fileModTimes(files ...string) (modTimes [2]time.Time, err error) {
+	for i, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// RedirectToHTTPS redirects every request to the same address on the https port
+func RedirectToHTTPS(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		}
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+	})
+}
+
+// OriginChecker tells if a WS upgrade request can be accepted by its Origin header
+type OriginChecker func(r *http.Request) bool
+
+// AllowedOrigins accepts upgrades from the server host, from any of origins and
+// without Origin, as sent by non browser clients like the mobile app
+// "*" accepts any origin
+func AllowedOrigins(origins []string) OriginChecker {
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[strings.ToLower(strings.TrimRight(o, "/"))] = true
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || allowed["*"] || allowed[strings.ToLower(origin)] {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+// ParseOrigins splits a comma separated list of origins like https://catchcatch.example.com
+func ParseOrigins(list string) ([]string, error) {
+	origins := make([]string, 0)
+	for _, o := range strings.Split(list, ",") {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+		if o != "*" {
+			if u, err := url.Parse(o); err != nil || u.Scheme == "" || u.Host == "" {
+				return nil, errors.New("invalid origin " + strconv.Quote(o) + ", eg: https://catchcatch.example.com")
+			}
+		}
+		origins = append(origins, o)
+	}
+	return origins, nil
+}
+
+func checkOriginWrapper(checkOrigin OriginChecker, h http.Handler) http.Handler {
+	if checkOrigin == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !checkOrigin(r) {
+			http.Error(w, ErrOriginNotAllowed.Error(), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
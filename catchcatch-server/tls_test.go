@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeSelfSignedCert(t *testing.T, dir, commonName string, modTime time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: commonName},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour), DNSNames: []string{commonName}}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der, modTime)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER, modTime)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte, modTime time.Time) {
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func certCommonName(t *testing.T, r *CertReloader) string {
+	cert, _ := r.GetCertificate(nil)
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestCertReloaderReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Minute)
+	certFile, keyFile := writeSelfSignedCert(t, dir, "old.catchcatch.test", start)
+	r, err := NewCertReloader(certFile, keyFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := r.Reload(); reloaded || err != nil {
+		t.Fatal("expected unchanged files not to be reloaded, got:", reloaded, err)
+	}
+
+	writeSelfSignedCert(t, dir, "new.catchcatch.test", start.Add(time.Second))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for certCommonName(t, r) != "new.catchcatch.test" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the changed certificate to be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	invalid := start.Add(2 * time.Second)
+	writePEM(t, keyFile, "EC PRIVATE KEY", []byte("broken"), invalid)
+	if _, err := r.Reload(); err == nil {
+		t.Fatal("expected an invalid key to fail")
+	}
+	if name := certCommonName(t, r); name != "new.catchcatch.test" {
+		t.Fatal("expected the current certificate to be kept, got:", name)
+	}
+}
+
+func TestNewCertReloaderMissingFiles(t *testing.T) {
+	if _, err := NewCertReloader("missing.pem", "missing-key.pem", nil); err == nil {
+		t.Fatal("expected missing files to fail")
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	cases := []struct {
+		port     int
+		url      string
+		expected string
+	}{
+		{443, "http://catchcatch.test/ws?codec=json", "https://catchcatch.test/ws?codec=json"},
+		{8443, "http://catchcatch.test:8080/", "https://catchcatch.test:8443/"},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		RedirectToHTTPS(c.port).ServeHTTP(recorder, httptest.NewRequest("GET", c.url, nil))
+		if recorder.Code != http.StatusMovedPermanently || recorder.Header().Get("Location") != c.expected {
+			t.Errorf("expected %s to redirect to %s, got: %d %s", c.url, c.expected, recorder.Code, recorder.Header().Get("Location"))
+		}
+	}
+}
+
+func TestAllowedOrigins(t *testing.T) {
+	origins, err := ParseOrigins(" https://admin.catchcatch.test/, ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := AllowedOrigins(origins)
+	cases := map[string]bool{
+		"":                              true,
+		"https://catchcatch.test":       true,
+		"https://ADMIN.catchcatch.test": true,
+		"https://evil.test":             false,
+		"http://catchcatch.test.evil":   false,
+	}
+	for origin, expected := range cases {
+		r := httptest.NewRequest("GET", "https://catchcatch.test/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if got := check(r); got != expected {
+			t.Errorf("origin %q: expected %v, got %v", origin, expected, got)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "https://catchcatch.test/ws", nil)
+	r.Header.Set("Origin", "https://evil.test")
+	if !AllowedOrigins([]string{"*"})(r) {
+		t.Fatal("expected * to allow any origin")
+	}
+	if _, err := ParseOrigins("catchcatch.test"); err == nil {
+		t.Fatal("expected origins without scheme to be invalid")
+	}
+}
+
+func TestWSDriversRefuseOrigins(t *testing.T) {
+	check := AllowedOrigins(nil)
+	drivers := map[string]WSDriver{
+		"gobwas": NewGobwasWSDriver(DefaultWSHeartbeat, check, nil),
+		"xnet":   NewXNetWSDriver(DefaultWSHeartbeat, check, nil),
+	}
+	for name, driver := range drivers {
+		h := driver.Handler(context.Background(), func(context.Context, WSConnection) {
+			t.Errorf("%s: expected the connection to be refused", name)
+		})
+		r := httptest.NewRequest("GET", "http://catchcatch.test/ws", nil)
+		r.Header.Set("Origin", "https://evil.test")
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, r)
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got: %d", name, recorder.Code)
+		}
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
 	"sync/atomic"
@@ -18,7 +18,9 @@ import (
 type WSConnection interface {
 	Read(*[]byte) (int, error)
 	Send(payload []byte) error
+	SendText(payload []byte) error
 	Close() error
+	Request() *http.Request
 }
 
 // WSDriver is an interface for WS communication
@@ -30,22 +32,36 @@ type WSDriver interface {
 type WSConnListener struct {
 	WSConnection
 
-	ID             string
+	id             atomic.Value
+	Role           ConnRole
+	Subject        string
+	codec          Codec
+	protocol       atomic.Value
 	eventCallbacks map[string]evtCallback
 	onDisconnected func()
-	stop           context.CancelFunc
+	closed         chan struct{}
+	closeOnce      sync.Once
+	queue          *sendQueue
+	logger         *slog.Logger
 
 	buffer []byte
 }
 
-type evtCallback func([]byte)
+type evtCallback func(*Request)
+
+// ID is the session id of the connection, which is also its player id
+// it changes when the connection is renamed by Rename
+func (c *WSConnListener) ID() string {
+	return c.id.Load().(string)
+}
 
 func (c *WSConnListener) listen(ctx context.Context) error {
-	ctx, c.stop = context.WithCancel(ctx)
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
+		case <-c.closed:
+			return nil
 		default:
 			if err := c.readMessage(); err != nil {
 				return err
@@ -54,7 +70,8 @@ func (c *WSConnListener) listen(ctx context.Context) error {
 	}
 }
 
-// On this connection event trigger callback with its message
+// On this connection event trigger callback with its request
+// use HandleEvent to receive the decoded message
 func (c *WSConnListener) On(event string, callback evtCallback) {
 	c.eventCallbacks[event] = callback
 }
@@ -73,20 +90,108 @@ type Message interface {
 }
 
 // Emit send payload on eventX to socket id
+// the message is adapted to the connection protocol, events the client can't handle are skipped
 func (c *WSConnListener) Emit(message Message) error {
-	payload, err := proto.Marshal(message)
+	wire := c.Protocol().Adapt(message)
+	if wire == nil {
+		return nil
+	}
+	payload, err := c.codec.Marshal(wire)
 	if err != nil {
 		return err
 	}
+	item := queuedMessage{event: message.GetEventName(), payload: payload, text: c.codec.Text()}
+	if c.queue == nil {
+		return c.write(item)
+	}
+	err = c.queue.push(item)
+	if err == ErrSlowConsumer {
+		c.logger.Warn("disconnecting slow consumer", LogConnID, c.ID(), LogEvent, item.event)
+		// the reader fails and removes the connection as any other disconnection
+		c.WSConnection.Close()
+	}
+	return err
+}
+
+func (c *WSConnListener) write(item queuedMessage) error {
+	if item.text {
+		return c.SendText(item.payload)
+	}
+	return c.Send(item.payload)
+}
+
+// writeLoop sends the queued messages until the queue is closed
+func (c *WSConnListener) writeLoop() {
+	defer c.WSConnection.Close()
+	defer c.queue.discard()
+	defer c.queue.close()
+	for {
+		item, ok := c.queue.pop()
+		if !ok {
+			return
+		}
+		if err := c.write(item); err != nil {
+			c.logger.Info("write error", LogConnID, c.ID(), LogEvent, item.event, "error", err)
+			return
+		}
+	}
+}
+
+// Unmarshal decodes an event payload received by this connection
+func (c *WSConnListener) Unmarshal(payload []byte, message proto.Message) error {
+	return c.codec.Unmarshal(payload, message)
+}
+
+// Protocol returns the protocol negotiated for this connection
+func (c *WSConnListener) Protocol() *Protocol {
+	return c.protocol.Load().(*Protocol)
+}
+
+func (c *WSConnListener) onProtocolHello(req *Request, msg *protobuf.ProtocolHello) {
+	protocol := NegotiateProtocol(msg.GetVersion(), msg.GetCapabilities())
+	c.protocol.Store(protocol)
+	c.Emit(&protobuf.ProtocolHello{EventName: proto.String("protocol:welcome"),
+		Version: proto.Uint32(protocol.Version), Capabilities: protocol.CapabilityList(), RequestId: req.ReplyID()})
+}
+
+// Codec returns the codec negotiated for this connection
+func (c *WSConnListener) Codec() Codec {
+	return c.codec
+}
+
+// Error codes sent on error:<code> events
+const (
+	ErrCodeInvalidMessage  = "invalid-message"
+	ErrCodeUnknownEvent    = "unknown-event"
+	ErrCodeUnauthenticated = "unauthenticated"
+	ErrCodeUnauthorized    = "unauthorized"
+	ErrCodeNotFound        = "not-found"
+	ErrCodeConflict        = "conflict"
+	ErrCodeInternal        = "internal"
+)
 
-	return c.Send(payload)
+// EmitError replies a failed event request with an error:<code> event
+func (c *WSConnListener) EmitError(event, requestID, code string, err error) error {
+	msg := &protobuf.Error{EventName: proto.String("error:" + code),
+		Id: proto.String(event), Code: proto.String(code), Message: proto.String(err.Error())}
+	if requestID != "" {
+		msg.RequestId = proto.String(requestID)
+	}
+	return c.Emit(msg)
 }
 
 // Close WS connection and stop listening
+// queued messages are sent before the connection is closed, closing it again does nothing
 func (c *WSConnListener) Close() {
-	c.stop()
-	c.WSConnection.Close()
-	go c.onDisconnected()
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		if c.queue != nil {
+			c.queue.close()
+		} else {
+			c.WSConnection.Close()
+		}
+		go c.onDisconnected()
+	})
 }
 
 func (c *WSConnListener) readMessage() error {
@@ -97,28 +202,60 @@ func (c *WSConnListener) readMessage() error {
 	if length == 0 {
 		return nil
 	}
-	msg := &protobuf.Simple{}
-	if err := proto.Unmarshal(c.buffer[:length], msg); err != nil {
-		return fmt.Errorf("readMessage(unmarshall): %s %s", err.Error(), c.buffer[:length])
+	payload := c.buffer[:length]
+	env := &protobuf.Envelope{}
+	if err := c.codec.Unmarshal(payload, env); err != nil {
+		return c.EmitError(env.GetEventName(), env.GetRequestId(), ErrCodeInvalidMessage,
+			fmt.Errorf("readMessage(unmarshall): %s", err.Error()))
+	}
+	if env.GetEventName() == "" {
+		c.logger.Info("message without event name", LogConnID, c.ID())
+		return c.EmitError("", env.GetRequestId(), ErrCodeInvalidMessage, errors.New("invalid payload: missing event name"))
 	}
-	if len(msg.String()) == 0 {
-		log.Println("message error:", msg)
-		return fmt.Errorf("Invalid payload: %s", c.buffer)
+	protocol := c.Protocol()
+	if protocol.Version == ProtocolLegacy && env.GetVersion() > ProtocolLegacy {
+		// envelopes sent before protocol:hello negotiate their version without capabilities
+		protocol = NegotiateProtocol(env.GetVersion(), nil)
+		c.protocol.Store(protocol)
 	}
-	cb, exists := c.eventCallbacks[msg.GetEventName()]
+	req := &Request{EventName: protocol.InboundEvent(env.GetEventName()), RequestID: env.GetRequestId(), raw: payload}
+	if env.GetVersion() > 0 {
+		req.Payload = EnvelopePayload(env)
+	}
+	c.logger.Debug("event received", LogConnID, c.ID(), LogEvent, req.EventName)
+	cb, exists := c.eventCallbacks[req.EventName]
 	if !exists {
-		return fmt.Errorf("No callback found for: %v", msg)
+		return c.EmitError(req.EventName, req.RequestID, ErrCodeUnknownEvent, fmt.Errorf("no callback found for: %s", req.EventName))
 	}
-	return withRecover(func() error {
-		cb(c.buffer)
+	err = withRecover(func() error {
+		cb(req)
 		return nil
 	})
+	if err != nil {
+		return c.EmitError(req.EventName, req.RequestID, ErrCodeInternal, err)
+	}
+	return nil
 }
 
+// ErrConnIDInUse happens when renaming a connection to the id of another connection
+var ErrConnIDInUse = errors.New("connection id in use")
+
 // WSServer manage WS connections
 type WSServer struct {
 	handler     WSDriver
 	onConnected func(c *WSConnListener)
+	queue       SendQueueConfig
+	queueStats  SendQueueStats
+	connStats   ConnectionStats
+	rooms       *rooms
+	logger      *slog.Logger
+
+	node      string
+	bus       MessageBus
+	directory ConnDirectory
+	// busHandlers are the node handlers by name
+	busHandlers sync.Map
+	draining    int32
 
 	connections atomic.Value
 	sync.Mutex
@@ -127,13 +264,16 @@ type WSServer struct {
 type connectionGroup map[string]*WSConnListener
 
 // NewWSServer create a new WSServer
-func NewWSServer(handler WSDriver) *WSServer {
-	wss := &WSServer{handler: handler, onConnected: func(c *WSConnListener) {}}
+// every connection gets a send queue configured by queue
+func NewWSServer(handler WSDriver, queue SendQueueConfig, logger *slog.Logger) *WSServer {
+	wss := &WSServer{handler: handler, queue: queue, rooms: newRooms(), logger: loggerOrDefault(logger)}
+	wss.onConnected = func(c *WSConnListener) { wss.Accept(c) }
 	wss.connections.Store(make(connectionGroup))
 	return wss
 }
 
 // OnConnected register event callback to new connections
+// fn must Accept the connection, eg: once it is authenticated
 func (wss *WSServer) OnConnected(fn func(c *WSConnListener)) {
 	if fn != nil {
 		wss.onConnected = fn
@@ -141,18 +281,28 @@ func (wss *WSServer) OnConnected(fn func(c *WSConnListener)) {
 }
 
 // Listen to WS connections
+// new connections are refused with 503 once the server is shutting down
 func (wss *WSServer) Listen(ctx context.Context) http.Handler {
-	return wss.handler.Handler(ctx, func(ctx context.Context, c WSConnection) {
-		conn := wss.Add(c)
+	handler := wss.handler.Handler(ctx, func(ctx context.Context, c WSConnection) {
+		conn := wss.newConn(c)
+		atomic.AddInt64(&wss.connStats.Opened, 1)
+		defer atomic.AddInt64(&wss.connStats.Closed, 1)
 		err := withRecover(func() error {
 			wss.onConnected(conn)
-			defer wss.Remove(conn.ID)
+			defer wss.remove(conn)
 			return conn.listen(ctx)
 		})
 		if err != nil {
-			log.Println("WSServer: read error", err)
+			wss.logger.Info("read error", LogConnID, conn.ID(), "error", err)
 		}
 	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wss.Draining() {
+			http.Error(w, ErrServerShuttingDown.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
 }
 
 // Get Conn by session id
@@ -161,71 +311,226 @@ func (wss *WSServer) Get(id string) *WSConnListener {
 	return connections[id]
 }
 
-// Add Conn for session id
+// Add Conn for session id and Accept it
 func (wss *WSServer) Add(c WSConnection) *WSConnListener {
-	id := uuid.NewV4().String()
-	conn := &WSConnListener{c, id, make(map[string]evtCallback), func() {}, func() {}, make([]byte, 512)}
+	conn := wss.newConn(c)
+	wss.Accept(conn)
+	return conn
+}
+
+// newConn wraps c, it doesn't receive server messages until it is accepted
+func (wss *WSServer) newConn(c WSConnection) *WSConnListener {
+	conn := &WSConnListener{WSConnection: c, Role: RolePlayer, codec: SelectCodec(c.Request()),
+		eventCallbacks: make(map[string]evtCallback), onDisconnected: func() {}, closed: make(chan struct{}),
+		buffer: make([]byte, 512), logger: wss.logger}
+	conn.id.Store(uuid.NewV4().String())
+	conn.protocol.Store(LegacyProtocol())
+	if wss.queue.Size > 0 {
+		conn.queue = newSendQueue(wss.queue, &wss.queueStats)
+		go conn.writeLoop()
+	}
+	HandleEvent(conn, "protocol:hello", conn.onProtocolHello)
+	return conn
+}
+
+// Accept makes conn reachable by its id, broadcasts, rooms and ForEach
+// it fails with ErrServerShuttingDown once the server is draining
+func (wss *WSServer) Accept(conn *WSConnListener) error {
+	if wss.Draining() {
+		return ErrServerShuttingDown
+	}
 	wss.getConnectionsForChange(func(connections connectionGroup) {
-		connections[conn.ID] = conn
+		connections[conn.ID()] = conn
 	})
-	return conn
+	wss.register(conn.ID())
+	return nil
 }
 
 func (wss *WSServer) getConnectionsForChange(fn func(connectionGroup)) {
 	wss.Lock()
 	connections := wss.connections.Load().(connectionGroup)
-	fn(connections)
-	newGroup := make(connectionGroup)
+	newGroup := make(connectionGroup, len(connections))
 	for k, v := range connections {
 		newGroup[k] = v
 	}
+	fn(newGroup)
 	wss.connections.Store(newGroup)
 	wss.Unlock()
 }
 
+// Rename changes the conn id to a new session id
+// it fails with ErrConnIDInUse when another connection of any node has this id
+func (wss *WSServer) Rename(c *WSConnListener, id string) error {
+	oldID := c.ID()
+	if oldID == id {
+		return nil
+	}
+	if _, err := wss.lookup(id); err == nil {
+		return ErrConnIDInUse
+	}
+	inUse := false
+	wss.getConnectionsForChange(func(connections connectionGroup) {
+		if _, exists := connections[id]; exists {
+			inUse = true
+			return
+		}
+		if current, exists := connections[oldID]; exists && current == c {
+			delete(connections, oldID)
+		}
+		c.id.Store(id)
+		connections[id] = c
+	})
+	if inUse {
+		return ErrConnIDInUse
+	}
+	wss.unregister(oldID)
+	wss.register(id)
+	return nil
+}
+
 // Remove Conn by session id
 func (wss *WSServer) Remove(id string) {
 	if c := wss.Get(id); c != nil {
-		c.Close()
-		wss.getConnectionsForChange(func(connections connectionGroup) {
-			delete(connections, id)
-		})
+		wss.remove(c)
 	}
 }
 
+// Disconnect closes the connection id on the node holding it
+func (wss *WSServer) Disconnect(id string) error {
+	if c := wss.Get(id); c != nil {
+		wss.remove(c)
+		return nil
+	}
+	node, err := wss.lookup(id)
+	if err != nil {
+		return err
+	}
+	return wss.publishBus(node, &BusMessage{To: []string{id}, ConnOp: ConnOpDisconnect}, nil)
+}
+
+func (wss *WSServer) remove(c *WSConnListener) {
+	wss.rooms.leaveAll(c)
+	removed := false
+	wss.getConnectionsForChange(func(connections connectionGroup) {
+		if current, exists := connections[c.ID()]; exists && current == c {
+			delete(connections, c.ID())
+			removed = true
+		}
+	})
+	if removed {
+		wss.unregister(c.ID())
+	}
+	c.Close()
+}
+
 // Emit send payload on eventX to socket id
+// connections held by other nodes are reached through the cluster bus
 func (wss *WSServer) Emit(id string, message Message) error {
 	if conn := wss.Get(id); conn != nil {
 		conn.Emit(message)
 		return nil
 	}
-	return errors.New("connection not found")
+	node, err := wss.lookup(id)
+	if err != nil {
+		return err
+	}
+	return wss.publish(node, []string{id}, message)
 }
 
 // BroadcastTo ids event message
 func (wss *WSServer) BroadcastTo(ids []string, message Message) {
+	remote := make(map[string][]string)
 	for _, id := range ids {
-		if err := wss.Emit(id, message); err != nil {
-			log.Println("error to emit ", message, message, err)
+		if conn := wss.Get(id); conn != nil {
+			conn.Emit(message)
+			continue
+		}
+		node, err := wss.lookup(id)
+		if err != nil {
+			wss.logger.Info("error to emit", LogConnID, id, LogEvent, message.GetEventName(), "error", err)
+			continue
+		}
+		remote[node] = append(remote[node], id)
+	}
+	for node, ids := range remote {
+		if err := wss.publish(node, ids, message); err != nil {
+			wss.logger.Info("error to emit", "node", node, LogEvent, message.GetEventName(), "error", err)
 		}
 	}
 }
 
-// Broadcast event message to all connections
+// Broadcast event message to all connections of every node
+// it returns the last error but doesn't stop on failures
 func (wss *WSServer) Broadcast(message Message) error {
+	lastErr := wss.broadcastLocal(message)
+	if wss.bus != nil {
+		if err := wss.publish("", nil, message); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (wss *WSServer) broadcastLocal(message Message) error {
+	var lastErr error
 	connections := wss.connections.Load().(connectionGroup)
-	for id := range connections {
-		if err := wss.Emit(id, message); err != nil {
-			return err
+	for _, c := range connections {
+		if err := c.Emit(message); err != nil {
+			lastErr = err
 		}
 	}
-	return nil
+	return lastErr
 }
 
-// CloseAll Conn
+// ForEach calls fn with every connection of this node
+func (wss *WSServer) ForEach(fn func(c *WSConnListener)) {
+	connections := wss.connections.Load().(connectionGroup)
+	for _, c := range connections {
+		fn(c)
+	}
+}
+
+// SendQueueStats returns the send queue counters of all connections
+func (wss *WSServer) SendQueueStats() SendQueueStats {
+	return SendQueueStats{
+		Depth:        atomic.LoadInt64(&wss.queueStats.Depth),
+		Dropped:      atomic.LoadInt64(&wss.queueStats.Dropped),
+		Disconnected: atomic.LoadInt64(&wss.queueStats.Disconnected),
+	}
+}
+
+// ConnectionStats counts the connections accepted by Listen
+type ConnectionStats struct {
+	Opened int64 `json:"opened"`
+	Closed int64 `json:"closed"`
+}
+
+// Open is the number of connections still open
+func (s ConnectionStats) Open() int64 {
+	return s.Opened - s.Closed
+}
+
+// ConnectionStats returns the connection counters
+func (wss *WSServer) ConnectionStats() ConnectionStats {
+	return ConnectionStats{
+		Opened: atomic.LoadInt64(&wss.connStats.Opened),
+		Closed: atomic.LoadInt64(&wss.connStats.Closed),
+	}
+}
+
+// CloseAll closes the connections of this node
 func (wss *WSServer) CloseAll() {
 	connections := wss.connections.Load().(connectionGroup)
 	for _, c := range connections {
 		c.Close()
 	}
 }
+
+// DisconnectAll closes the connections of every node
+func (wss *WSServer) DisconnectAll() error {
+	wss.CloseAll()
+	if wss.bus == nil {
+		return nil
+	}
+	return wss.publishBus("", &BusMessage{ConnOp: ConnOpDisconnect}, nil)
+}
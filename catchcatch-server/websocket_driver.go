@@ -1,84 +1,177 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws/wsutil"
 	"golang.org/x/net/websocket"
 )
 
+// WSMaxMessageSize is the max size of a received WS frame
+const WSMaxMessageSize = 1024 * 1024
+
+// WSHeartbeat configures how drivers keep connections alive
+type WSHeartbeat struct {
+	// PingInterval is the time between server pings, zero disables pings
+	PingInterval time.Duration
+	// ReadTimeout closes connections which send nothing, pongs included, for this long
+	ReadTimeout time.Duration
+	// WriteTimeout is the max time to write to a connection
+	WriteTimeout time.Duration
+}
+
+// DefaultWSHeartbeat pings every 15s and reaps connections silent for 45s
+var DefaultWSHeartbeat = WSHeartbeat{PingInterval: 15 * time.Second,
+	ReadTimeout: 45 * time.Second, WriteTimeout: 10 * time.Second}
+
 // GobwasWSDriver is a WSDriver implementation based on gobwas/ws
-type GobwasWSDriver struct{}
+type GobwasWSDriver struct {
+	heartbeat   WSHeartbeat
+	checkOrigin OriginChecker
+	logger      *slog.Logger
+}
 
 // NewGobwasWSDriver creates a gobwas/ws WSDriver
-func NewGobwasWSDriver() WSDriver {
-	return new(GobwasWSDriver)
+// upgrades failing checkOrigin are refused, nil accepts any origin
+func NewGobwasWSDriver(heartbeat WSHeartbeat, checkOrigin OriginChecker, logger *slog.Logger) WSDriver {
+	return &GobwasWSDriver{heartbeat, checkOrigin, loggerOrDefault(logger)}
 }
 
 // Handler implements WSDriver.Handler
 func (d GobwasWSDriver) Handler(ctx context.Context, onConnect func(context.Context, WSConnection)) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		c, _, _, err := ws.UpgradeHTTP(r, w, nil)
+	return checkOriginWrapper(d.checkOrigin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := ws.HTTPUpgrader{Protocol: func(p string) bool {
+			return SelectSubprotocol([]string{p}) != ""
+		}}
+		c, _, _, err := upgrader.Upgrade(r, &heartbeatResponseWriter{w, d.heartbeat}, nil)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
+			return
 		}
-		ctx := r.WithContext(ctx).Context()
-		conn := &GobwasWSConn{Conn: c}
+		ctx, cancel := context.WithCancel(r.WithContext(ctx).Context())
+		defer cancel()
+		conn := &GobwasWSConn{Conn: c, request: r}
+		go keepAlive(ctx, conn, d.heartbeat.PingInterval, d.logger)
 		onConnect(ctx, conn)
-	})
+	}))
 }
 
 // GobwasWSConn wraps gobwas/ws connections
 type GobwasWSConn struct {
 	net.Conn
+	request *http.Request
+	// writes are serialized, frames are written as header and payload
+	writeLock sync.Mutex
+}
+
+// Request implements WSConnection.Request
+func (c *GobwasWSConn) Request() *http.Request {
+	return c.request
 }
 
 // Send implements WSConnection.Send
-func (c GobwasWSConn) Send(payload []byte) error {
-	return wsutil.WriteServerBinary(c, payload)
+func (c *GobwasWSConn) Send(payload []byte) error {
+	return c.write(ws.OpBinary, payload)
+}
+
+// SendText implements WSConnection.SendText
+func (c *GobwasWSConn) SendText(payload []byte) error {
+	return c.write(ws.OpText, payload)
+}
+
+// Ping sends a ping frame, the client pong extends the read deadline
+func (c *GobwasWSConn) Ping() error {
+	return c.write(ws.OpPing, nil)
+}
+
+func (c *GobwasWSConn) write(op ws.OpCode, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return wsutil.WriteServerMessage(c.Conn, op, payload)
 }
 
 // Read implements WSConnection.Read
-func (c GobwasWSConn) Read(buff *[]byte) (int, error) {
-	header, err := ws.ReadHeader(c.Conn)
-	if err != nil {
-		return 0, fmt.Errorf("readMessage(header): %s", err.Error())
-	}
-	if header.OpCode == ws.OpClose {
-		return 0, fmt.Errorf("readMessage(closed)")
-	}
-	length := int(header.Length)
-	if _, err := io.ReadAtLeast(c.Conn, *buff, length); err != nil {
-		return 0, fmt.Errorf("readMessage(body): %s", err.Error())
-	}
-	if header.Masked {
-		ws.Cipher(*buff, header.Mask, 0)
+// control frames are handled here, only data frames are returned
+func (c *GobwasWSConn) Read(buff *[]byte) (int, error) {
+	for {
+		header, err := ws.ReadHeader(c.Conn)
+		if err != nil {
+			return 0, fmt.Errorf("readMessage(header): %s", err.Error())
+		}
+		if header.OpCode == ws.OpClose {
+			return 0, fmt.Errorf("readMessage(closed)")
+		}
+		if header.Length > WSMaxMessageSize {
+			return 0, fmt.Errorf("readMessage(body): message too large: %d", header.Length)
+		}
+		length := int(header.Length)
+		if length > len(*buff) {
+			*buff = make([]byte, length)
+		}
+		payload := (*buff)[:length]
+		if _, err := io.ReadFull(c.Conn, payload); err != nil {
+			return 0, fmt.Errorf("readMessage(body): %s", err.Error())
+		}
+		if header.Masked {
+			ws.Cipher(payload, header.Mask, 0)
+		}
+		switch header.OpCode {
+		case ws.OpPing:
+			if err := c.write(ws.OpPong, payload); err != nil {
+				return 0, fmt.Errorf("readMessage(pong): %s", err.Error())
+			}
+			continue
+		case ws.OpPong:
+			continue
+		}
+		return length, nil
 	}
-	return length, nil
 }
 
 // XNetWSDriver is a WSDriver implementation based on x/net/websocket
-type XNetWSDriver struct{}
+type XNetWSDriver struct {
+	heartbeat   WSHeartbeat
+	checkOrigin OriginChecker
+	logger      *slog.Logger
+}
 
 // NewXNetWSDriver creates a x/net/websocket WSDriver
-func NewXNetWSDriver() WSDriver {
-	return &XNetWSDriver{}
+// upgrades failing checkOrigin are refused, nil accepts any origin
+func NewXNetWSDriver(heartbeat WSHeartbeat, checkOrigin OriginChecker, logger *slog.Logger) WSDriver {
+	return &XNetWSDriver{heartbeat, checkOrigin, loggerOrDefault(logger)}
 }
 
 // Handler implements WSDriver.Handler
 func (d XNetWSDriver) Handler(ctx context.Context, onConnect func(context.Context, WSConnection)) http.Handler {
-	return websocket.Server{
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			config.Protocol = nil
+			if protocol := SelectSubprotocol(requestedSubprotocols(r)); protocol != "" {
+				config.Protocol = []string{protocol}
+			}
+			return nil
+		},
 		Handler: func(c *websocket.Conn) {
+			ctx, cancel := context.WithCancel(c.Request().WithContext(ctx).Context())
+			defer cancel()
 			conn := &XNetWSConn{Conn: c}
-			ctx := c.Request().WithContext(ctx).Context()
+			go keepAlive(ctx, conn, d.heartbeat.PingInterval, d.logger)
 			onConnect(ctx, conn)
 		},
 	}
+	return checkOriginWrapper(d.checkOrigin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.ServeHTTP(&heartbeatResponseWriter{w, d.heartbeat}, r)
+	}))
 }
 
 // XNetWSConn wraps x/net/websocket connections
@@ -96,3 +189,93 @@ func (c XNetWSConn) Read(buff *[]byte) (int, error) {
 func (c XNetWSConn) Send(payload []byte) error {
 	return websocket.Message.Send(c.Conn, payload)
 }
+
+// SendText implements WSConnection.SendText
+func (c XNetWSConn) SendText(payload []byte) error {
+	return websocket.Message.Send(c.Conn, string(payload))
+}
+
+var xnetPing = websocket.Codec{Marshal: func(interface{}) ([]byte, byte, error) {
+	return nil, websocket.PingFrame, nil
+}}
+
+// Ping sends a ping frame, x/net/websocket consumes the pong but it extends the read deadline
+func (c XNetWSConn) Ping() error {
+	return xnetPing.Send(c.Conn, nil)
+}
+
+// keepAlive pings c every interval until ctx is done
+// connections which can't be pinged are closed and the reader ends the normal disconnection
+func keepAlive(ctx context.Context, c interface {
+	Ping() error
+	Close() error
+}, interval time.Duration, logger *slog.Logger) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Ping(); err != nil {
+				logger.Info("error to ping connection", "error", err)
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+// heartbeatResponseWriter hijacks connections with the heartbeat deadlines
+type heartbeatResponseWriter struct {
+	http.ResponseWriter
+	heartbeat WSHeartbeat
+}
+
+// Hijack implements http.Hijacker
+func (w *heartbeatResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer is not a http.Hijacker")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	dc := &deadlineConn{Conn: conn, heartbeat: w.heartbeat}
+	if n := rw.Reader.Buffered(); n > 0 {
+		pending, _ := rw.Reader.Peek(n)
+		dc.pending = append([]byte(nil), pending...)
+	}
+	return dc, bufio.NewReadWriter(bufio.NewReader(dc), bufio.NewWriter(dc)), nil
+}
+
+// deadlineConn extends the read and write deadlines on every read and write
+// so any frame received, pongs included, keeps the connection alive
+type deadlineConn struct {
+	net.Conn
+	heartbeat WSHeartbeat
+	pending   []byte
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	if len(c.pending) > 0 {
+		n := copy(p, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+	if c.heartbeat.ReadTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.heartbeat.ReadTimeout))
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *deadlineConn) Write(p []byte) (int, error) {
+	if c.heartbeat.WriteTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.heartbeat.WriteTimeout))
+	}
+	return c.Conn.Write(p)
+}
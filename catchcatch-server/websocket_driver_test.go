@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"golang.org/x/net/websocket"
+)
+
+type driverServer struct {
+	*httptest.Server
+	received chan string
+	reaped   chan error
+}
+
+func newDriverServer(t *testing.T, driver WSDriver) *driverServer {
+	s := &driverServer{received: make(chan string, 10), reaped: make(chan error, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Server = httptest.NewServer(driver.Handler(ctx, func(ctx context.Context, c WSConnection) {
+		buf := make([]byte, 512)
+		for {
+			n, err := c.Read(&buf)
+			if err != nil {
+				s.reaped <- err
+				return
+			}
+			s.received <- string(buf[:n])
+		}
+	}))
+	t.Cleanup(func() {
+		cancel()
+		s.Close()
+	})
+	return s
+}
+
+func (s *driverServer) wsURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"
+}
+
+// dialers connect a client which answers pings while it reads
+var dialers = map[string]struct {
+	driver func(WSHeartbeat) WSDriver
+	dial   func(t *testing.T, url string) (read func() error, close func())
+}{
+	"gobwas": {
+		driver: func(h WSHeartbeat) WSDriver { return NewGobwasWSDriver(h, nil, nil) },
+		dial: func(t *testing.T, url string) (func() error, func()) {
+			conn, _, _, err := ws.Dial(context.Background(), url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return func() error {
+				_, _, err := wsutil.ReadServerData(conn)
+				return err
+			}, func() { conn.Close() }
+		},
+	},
+	"xnet": {
+		driver: func(h WSHeartbeat) WSDriver { return NewXNetWSDriver(h, nil, nil) },
+		dial: func(t *testing.T, url string) (func() error, func()) {
+			conn, err := websocket.Dial(url, "", "http://localhost/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return func() error {
+				var msg []byte
+				return websocket.Message.Receive(conn, &msg)
+			}, func() { conn.Close() }
+		},
+	},
+}
+
+func TestWSDriversReapSilentClients(t *testing.T) {
+	heartbeat := WSHeartbeat{ReadTimeout: 50 * time.Millisecond}
+	for name, d := range dialers {
+		s := newDriverServer(t, d.driver(heartbeat))
+		_, closeClient := d.dial(t, s.wsURL())
+		select {
+		case <-s.reaped:
+		case <-time.After(time.Second):
+			t.Errorf("%s: expected the silent client to be reaped after the read timeout", name)
+		}
+		closeClient()
+	}
+}
+
+func TestWSDriversKeepPongingClients(t *testing.T) {
+	heartbeat := WSHeartbeat{PingInterval: 20 * time.Millisecond, ReadTimeout: 80 * time.Millisecond, WriteTimeout: time.Second}
+	for name, d := range dialers {
+		s := newDriverServer(t, d.driver(heartbeat))
+		read, closeClient := d.dial(t, s.wsURL())
+		go func() {
+			for read() == nil {
+			}
+		}()
+		select {
+		case err := <-s.reaped:
+			t.Errorf("%s: expected the ponging client to be kept, got: %v", name, err)
+		case <-time.After(300 * time.Millisecond):
+		}
+		closeClient()
+	}
+}
+
+func TestGobwasDriverReadsFramesSentWithTheHandshake(t *testing.T) {
+	s := newDriverServer(t, NewGobwasWSDriver(WSHeartbeat{ReadTimeout: time.Second}, nil, nil))
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the frame is buffered by the http server together with the upgrade request
+	request := "GET /ws HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	frame := &strings.Builder{}
+	if err := wsutil.WriteClientMessage(frame, ws.OpBinary, []byte("early")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(conn, request+frame.String()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-s.received:
+		if msg != "early" {
+			t.Fatal("unexpected message:", msg)
+		}
+	case err := <-s.reaped:
+		t.Fatal("expected the pending frame to be read, got:", err)
+	case <-time.After(time.Second):
+		t.Fatal("expected the pending frame to be read")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/protobuf"
+)
+
+func TestRenameMovesTheConnection(t *testing.T) {
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	c := server.Add(&fakeWSConn{})
+	oldID := c.ID()
+	server.Join(RoomAdmin, oldID)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_ = c.ID()
+		}
+	}()
+	if err := server.Rename(c, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if c.ID() != "p1" || server.Get("p1") != c || server.Get(oldID) != nil {
+		t.Fatal("expected the connection to be found only by its new id, got:", c.ID())
+	}
+	if members := server.RoomMembers(RoomAdmin); len(members) != 1 || members[0] != "p1" {
+		t.Fatal("expected the rooms to follow the new id, got:", members)
+	}
+}
+
+func TestRenameRefusesIDsInUse(t *testing.T) {
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	owner, intruder := server.Add(&fakeWSConn{}), server.Add(&fakeWSConn{})
+	intruderID := intruder.ID()
+	if err := server.Rename(intruder, owner.ID()); err != ErrConnIDInUse {
+		t.Fatal("expected ErrConnIDInUse, got:", err)
+	}
+	if server.Get(owner.ID()) != owner || intruder.ID() != intruderID || server.Get(intruderID) != intruder {
+		t.Fatal("expected both connections to keep their ids")
+	}
+	select {
+	case <-owner.closed:
+		t.Fatal("expected the owner connection not to be closed")
+	default:
+	}
+
+	directory := NewLocalConnDirectory()
+	directory.Register("p2", "node-b")
+	server.node, server.directory = "node-a", directory
+	if err := server.Rename(intruder, "p2"); err != ErrConnIDInUse {
+		t.Fatal("expected ids held by other nodes to be refused, got:", err)
+	}
+}
+
+func TestUnauthenticatedConnectionsReceiveNothing(t *testing.T) {
+	driver := stubWSDriver{conns: make(chan *blockingWSConn, 1)}
+	server := NewWSServer(driver, SendQueueConfig{}, nil)
+	NewEventHandler(server, nil, nil, nil, NewHMACAuthenticator("secret"), nil, nil, nil, nil)
+	go server.Listen(context.Background()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ws", nil))
+	conn := <-driver.conns
+	defer conn.Close()
+	waitFor(t, "the connection to be opened", func() bool { return server.ConnectionStats().Open() == 1 })
+
+	server.Broadcast(&protobuf.Simple{EventName: proto.String("remote-player:updated"), Id: proto.String("p1")})
+	server.ForEach(func(c *WSConnListener) {
+		t.Error("expected pending connections not to be listed, got:", c.ID())
+	})
+	time.Sleep(10 * time.Millisecond)
+	conn.Lock()
+	defer conn.Unlock()
+	if len(conn.sent) != 0 {
+		t.Fatal("expected no message before authentication, got:", len(conn.sent))
+	}
+}
+
+func readReplies(t *testing.T, c *WSConnListener, conn *fakeWSConn) []*protobuf.Envelope {
+	for len(conn.frames) > 0 {
+		if err := c.readMessage(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replies := make([]*protobuf.Envelope, 0, len(conn.sent))
+	for _, payload := range conn.sent {
+		reply := &protobuf.Envelope{}
+		if err := proto.Unmarshal(payload, reply); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, reply)
+	}
+	return replies
+}
+
+func TestFailedRequestsAreRepliedWithTheirRequestID(t *testing.T) {
+	hello := &protobuf.ProtocolHello{EventName: proto.String("protocol:hello"),
+		Version: proto.Uint32(CurrentProtocolVersion), Capabilities: []string{CapErrors}}
+	unknown, _ := NewEnvelope(CurrentProtocolVersion, &protobuf.Simple{EventName: proto.String("player:dance")})
+	unknown.RequestId = proto.String("r1")
+	failing, _ := NewEnvelope(CurrentProtocolVersion, &protobuf.Simple{EventName: proto.String("admin:clear")})
+	failing.RequestId = proto.String("r2")
+	c, conn := newTestListener(hello, unknown, failing)
+	HandleEvent(c, "admin:clear", func(*Request, *protobuf.Simple) {
+		panic("tile38 down")
+	})
+
+	replies := readReplies(t, c, conn)
+	if len(replies) != 3 {
+		t.Fatal("expected a reply for each request, got:", replies)
+	}
+	expected := []struct{ event, requestID, failed string }{
+		{"error:" + ErrCodeUnknownEvent, "r1", "player:dance"},
+		{"error:" + ErrCodeInternal, "r2", "admin:clear"},
+	}
+	for i, e := range expected {
+		reply := replies[i+1]
+		if reply.GetEventName() != e.event || reply.GetRequestId() != e.requestID ||
+			reply.GetError().GetId() != e.failed || reply.GetError().GetRequestId() != e.requestID {
+			t.Errorf("expected %s for %s, got: %v", e.event, e.requestID, reply)
+		}
+	}
+}
+
+func TestLegacyErrorsCarryRequestID(t *testing.T) {
+	c, conn := newTestListener()
+	c.protocol.Store(NegotiateProtocol(ProtocolLegacy, []string{CapErrors}))
+	c.EmitError("player:update", "r3", ErrCodeUnauthorized, ErrUnauthorized)
+	reply := &protobuf.Error{}
+	if len(conn.sent) != 1 || proto.Unmarshal(conn.sent[0], reply) != nil {
+		t.Fatal("expected a legacy error, got:", conn.sent)
+	}
+	if reply.GetEventName() != "error:"+ErrCodeUnauthorized || reply.GetCode() != ErrCodeUnauthorized || reply.GetRequestId() != "r3" {
+		t.Fatal("unexpected error:", reply)
+	}
+}
+
+func TestListEndMarksTheEndOfReplies(t *testing.T) {
+	c, conn := newTestListener()
+	c.protocol.Store(NegotiateProtocol(CurrentProtocolVersion, []string{CapListEnd}))
+	c.Emit(listEndMessage("game:around", 2, proto.String("r4")))
+	replies := readReplies(t, c, conn)
+	if len(replies) != 1 || replies[0].GetEventName() != "game:around:end" || replies[0].GetRequestId() != "r4" ||
+		replies[0].GetListEnd().GetCount() != 2 {
+		t.Fatal("unexpected list end:", replies)
+	}
+
+	legacy, legacyConn := newTestListener()
+	legacy.Emit(listEndMessage("game:around", 2, nil))
+	if len(legacyConn.sent) != 0 {
+		t.Fatal("expected clients without list-end not to receive it")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/model"
+)
+
+// ZeroconfService is the service type the server is advertised as
+const ZeroconfService = "_catchcatch._tcp"
+
+// TXTPublisher updates the TXT records of an advertised service, eg: zeroconf.Server
+type TXTPublisher interface {
+	SetText(txt []string)
+}
+
+// ZeroconfAdvertiser keeps the advertised server info up to date
+type ZeroconfAdvertiser struct {
+	publisher TXTPublisher
+	info      model.ServerInfo
+	server    *WSServer
+	games     *GameWatcher
+}
+
+// NewZeroconfAdvertiser creates a ZeroconfAdvertiser, the counts of info are replaced
+// by the open connections of server and the games watched by games
+func NewZeroconfAdvertiser(publisher TXTPublisher, info model.ServerInfo, server *WSServer, games *GameWatcher) *ZeroconfAdvertiser {
+	return &ZeroconfAdvertiser{publisher, info, server, games}
+}
+
+// Info returns the server info with the current counts
+func (a *ZeroconfAdvertiser) Info() model.ServerInfo {
+	info := a.info
+	info.Players = int(a.server.ConnectionStats().Open())
+	info.Games = len(a.games.Games())
+	return info
+}
+
+// Run publishes the server info every interval until ctx is done
+func (a *ZeroconfAdvertiser) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := a.Info()
+	a.publisher.SetText(last.TXT())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if info := a.Info(); info != last {
+				a.publisher.SetText(info.TXT())
+				last = info
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/perenecabuto/CatchCatch/catchcatch-server/model"
+)
+
+type recordingPublisher struct {
+	published [][]string
+	sync.Mutex
+}
+
+func (p *recordingPublisher) SetText(txt []string) {
+	p.Lock()
+	defer p.Unlock()
+	p.published = append(p.published, txt)
+}
+
+func (p *recordingPublisher) last() (model.ServerInfo, int) {
+	p.Lock()
+	defer p.Unlock()
+	if len(p.published) == 0 {
+		return model.ServerInfo{}, 0
+	}
+	return model.ParseServerInfo(p.published[len(p.published)-1]), len(p.published)
+}
+
+func TestServerInfoTXT(t *testing.T) {
+	info := model.ServerInfo{Name: "Park=North", ProtocolVersion: CurrentProtocolVersion, TLS: true, WSPath: "/ws", Players: 7, Games: 2}
+	if parsed := model.ParseServerInfo(info.TXT()); !reflect.DeepEqual(parsed, info) {
+		t.Fatal("expected the TXT records to be parsed back, got:", parsed)
+	}
+	if url := info.URL("10.0.0.2:5000"); url != "wss://10.0.0.2:5000/ws" {
+		t.Fatal("unexpected url:", url)
+	}
+	parsed := model.ParseServerInfo([]string{"name=old", "players=many", "region=br", "broken"})
+	if !reflect.DeepEqual(parsed, model.ServerInfo{Name: "old"}) {
+		t.Fatal("expected unknown and invalid records to be ignored, got:", parsed)
+	}
+}
+
+func TestZeroconfAdvertiserPublishesCounts(t *testing.T) {
+	server := NewWSServer(nil, SendQueueConfig{}, nil)
+	games := NewGameWatcher(idleEventStream{}, server, nil, GameLease{Locks: NewMemoryLock(), Owner: "a", TTL: time.Second}, DefaultGameRules, nil, nil)
+	publisher := &recordingPublisher{}
+	info := model.ServerInfo{Name: "CatchCatch", ProtocolVersion: CurrentProtocolVersion, WSPath: "/ws"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewZeroconfAdvertiser(publisher, info, server, games).Run(ctx, 10*time.Millisecond)
+
+	atomic.AddInt64(&server.connStats.Opened, 3)
+	atomic.AddInt64(&server.connStats.Closed, 1)
+	deadline := time.Now().Add(time.Second)
+	for {
+		published, _ := publisher.last()
+		if published.Players == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the open connections to be published, got:", published)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, count := publisher.last()
+	time.Sleep(50 * time.Millisecond)
+	if published, again := publisher.last(); again != count || published.Name != "CatchCatch" {
+		t.Fatal("expected unchanged info not to be published again, got:", again-count, published)
+	}
+}
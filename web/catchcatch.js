@@ -44,7 +44,7 @@ var messages = {
 }
 
 function init() {
-    let socket = new WSS(location.href.replace("http", "ws") + "ws", false);
+    let socket = new WSS(wsAddress(), false);
     let source = new ol.source.Vector({ wrapX: false });
     let raster = new ol.layer.Tile({ source: new ol.source.OSM() });
     let vector = new ol.layer.Vector({ source: source });
@@ -75,6 +75,8 @@ function init() {
     controller.bindDrawGroupButton("geofences", map, "Polygon");
     controller.bindDrawGroupButton("checkpoint", map, "Point");
     document.getElementById("reset").addEventListener("click", controller.reset);
+    document.getElementById("audit").addEventListener("click", function () { controller.requestAudit(0); });
+    document.getElementById("audit-older").addEventListener("click", controller.requestOlderAudit);
 
     controller.bindPosition();
 
@@ -101,11 +103,16 @@ function init() {
     socket.on('disconnect', evtHandler.onDisconnected)
 
     socket.on('remote-player:updated', evtHandler.onRemotePlayerUpdated)
+    socket.on('remote-players:batch', evtHandler.onRemotePlayersBatch)
     socket.on('remote-player:new', evtHandler.onRemotePlayerNew);
     socket.on("remote-player:destroy", evtHandler.onRemotePlayerDestroy);
 
     socket.on("admin:feature:added", evtHandler.onFeatureAdded);
     socket.on("admin:feature:checkpoint", evtHandler.onFeatureCheckpoint)
+    socket.on("admin:audit:page", evtHandler.onAuditPage);
+    ["unauthorized", "invalid-message", "unknown-event", "not-found", "internal", "rate-limited"].forEach(function (code) {
+        socket.on("error:" + code, evtHandler.onError);
+    });
 }
 
 
@@ -148,7 +155,7 @@ let Player = function (x, y) {
     function connect(registeredFn, disconnectedFn) {
         registeredCallback = registeredFn;
         disconnectedCallback = disconnectedFn;
-        socket = new WSS(location.href.replace("http", "ws") + "ws");
+        socket = new WSS(wsAddress());
         socket.on('player:registered', onPlayerRegistered)
         socket.on('player:updated', onPlayerUpdated)
 
@@ -245,6 +252,30 @@ let AdminController = function (socket, sourceLayer, view) {
         socket.emit(messages.Simple.encode({eventName: 'admin:clear'}).finish());
     };
 
+    let auditOffset = 0;
+    function requestAudit(offset) {
+        socket.emit(messages.AuditRequest.encode({eventName: "admin:audit:request", offset: offset}).finish());
+    }
+    this.requestAudit = requestAudit;
+
+    this.requestOlderAudit = function () {
+        requestAudit(auditOffset);
+    };
+
+    this.showAuditPage = function (page) {
+        let el = document.getElementById("audit-log");
+        let pre = el.getElementsByTagName("pre")[0];
+        if (page.offset == 0) pre.innerText = "";
+        page.entries.forEach(function (e) {
+            let who = e.subject || e.connId;
+            pre.innerText += new Date(e.time.toNumber ? e.time.toNumber() : e.time).toISOString() +
+                " " + who + "(" + e.role + ") " + e.event + " " + e.outcome + (e.error ? ": " + e.error : "") + "\n";
+        });
+        auditOffset = page.offset + page.entries.length;
+        document.getElementById("audit-older").style.display = auditOffset < page.total ? "inline" : "none";
+        el.style.display = "block";
+    };
+
     this.disconnectPlayer = function (playerId) {
         console.log("admin:disconnect", playerId);
         socket.emit(messages.Simple.encode({eventName: 'admin:disconnect', id: playerId}).finish());
@@ -418,6 +449,12 @@ let EventHandler = function (controller) {
         let p = messages.Player.decode(msg);
         controller.updatePlayer(p);
     };
+    this.onRemotePlayersBatch = function (msg) {
+        let batch = messages.PlayerBatch.decode(msg);
+        batch.players.forEach(function (p) {
+            controller.updatePlayer(p);
+        });
+    };
     this.onRemotePlayerNew = function (msg) {
         let p = messages.Player.decode(msg);
         controller.updatePlayer(p)
@@ -432,6 +469,16 @@ let EventHandler = function (controller) {
         controller.addFeature(feat.id, feat.group, geojson);
     };
 
+    this.onAuditPage = function (msg) {
+        controller.showAuditPage(messages.AuditPage.decode(msg));
+    };
+
+    this.onError = function (msg) {
+        let err = messages.Error.decode(msg);
+        log(err.eventName + ": " + err.id + " " + err.message);
+        console.error(err);
+    };
+
     this.onFeatureCheckpoint = function (msg) {
         var detection = messages.Detection.decode(msg);
         var circleID = detection.nearByFeatId + "-" + detection.featId;
@@ -439,9 +486,16 @@ let EventHandler = function (controller) {
     }
 };
 
+function wsAddress() {
+    let address = location.origin.replace("http", "ws") + location.pathname + "ws";
+    let token = new URLSearchParams(location.search).get("token");
+    return token ? address + "?token=" + encodeURIComponent(token) : address;
+}
+
 function WSS(address, reconnect) {
     let eventCallbacks = {}
     let ws;
+    let shutdown;
 
     this.on = function (event, callback) {
         eventCallbacks[event] = callback;
@@ -455,6 +509,9 @@ function WSS(address, reconnect) {
     }
 
     function onOpen(event) {
+        // keep the legacy framing but opt in to error replies and list end markers
+        ws.send(messages.ProtocolHello.encode({
+            eventName: "protocol:hello", version: 1, capabilities: ["errors", "list-end", "batch"]}).finish());
         triggerEvent('connect')
     }
 
@@ -462,6 +519,9 @@ function WSS(address, reconnect) {
         let payload = new Uint8Array(event.data);
         try {
             let evt = messages.Simple.decode(payload);
+            if (evt.eventName == "server:shutdown") {
+                shutdown = messages.ServerShutdown.decode(payload);
+            }
             triggerEvent(evt.eventName, payload);
         } catch(e) {
             console.error(e)
@@ -470,6 +530,14 @@ function WSS(address, reconnect) {
 
     function onClose() {
         triggerEvent('disconnect');
+        if (shutdown) {
+            // spread the reconnections of the clients of the server shutting down
+            let delay = Number(shutdown.reconnectIn) * (1 + Math.random());
+            address = shutdown.reconnectUrl || address;
+            shutdown = undefined;
+            setTimeout(init, delay);
+            return;
+        }
         if (!reconnect) return;
         ws.onclose();
     }